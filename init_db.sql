@@ -190,7 +190,12 @@ CREATE TABLE IF NOT EXISTS kv_7
     sv9  Int64 DEFAULT 0,
     sv10 Int64 DEFAULT 0
 ) ENGINE = MergeTree()
-ORDER BY (data_time, id);
+ORDER BY (data_time, id)
+-- 开启非复制表的写入去重，配合insert_deduplication_token保证回放批次不重复
+SETTINGS non_replicated_deduplication_window = 1000;
+
+-- 已存在的kv_7表不会执行上面的CREATE，这里补上去重设置（见migrations/001_kv7_deduplication_window.sql）
+ALTER TABLE kv_7 MODIFY SETTING non_replicated_deduplication_window = 1000;
 
 -- 创建插入一些测试数据
 INSERT INTO kv_7 (data_time, write_time, time_hour, id, time, platform, category, action, os, user_id, app_id, version, d1, level)
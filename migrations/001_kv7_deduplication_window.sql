@@ -0,0 +1,4 @@
+-- 为已存在的kv_7表开启非复制表的写入去重
+-- 异步写入队列以批次ID作为insert_deduplication_token回放落盘批次，
+-- 没有这个设置时令牌不起作用，回放会写入重复的行
+ALTER TABLE test_db.kv_7 MODIFY SETTING non_replicated_deduplication_window = 1000;
@@ -0,0 +1,147 @@
+// seed 生成可复现的kv_7测试数据，写入ClickHouse或NDJSON/SQL文件
+//
+// 用法:
+//
+//	go run ./cmd/seed -count 100000 -out clickhouse
+//	go run ./cmd/seed -count 2000 -out data/fixtures.ndjson
+//	go run ./cmd/seed -count 500 -base-time 2024-01-01T00:00:00Z -out data/sample_data.sql
+//
+// 相同的seed、count、base-time、users和bursts生成相同的数据。NDJSON文件可直接作为DATA_FIXTURE_FILE使用，
+// SQL文件可通过clickhouse-client或HTTP接口导入。写入ClickHouse时使用CLICKHOUSE_*环境变量中的连接配置
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"server/database"
+	"server/models"
+)
+
+func main() {
+	config := models.DefaultFixtureConfig(models.DefaultFixtureBaseTime)
+
+	out := flag.String("out", "", "输出位置：clickhouse，或以.ndjson/.jsonl/.sql结尾的文件路径，-表示标准输出(NDJSON)")
+	format := flag.String("format", "", "文件格式ndjson或sql，默认按文件扩展名判断")
+	baseTime := flag.String("base-time", config.BaseTime.Format(time.RFC3339), "数据的截止时间(RFC3339)，now表示当前整点")
+	batchSize := flag.Int("batch", 5000, "写入ClickHouse或SQL文件时每批的记录数")
+	flag.Int64Var(&config.Seed, "seed", config.Seed, "随机种子")
+	flag.IntVar(&config.Count, "count", config.Count, "生成的记录数")
+	flag.IntVar(&config.Users, "users", config.Users, "用户数")
+	flag.IntVar(&config.ErrorBursts, "bursts", config.ErrorBursts, "错误突增次数")
+	flag.Parse()
+
+	if *out == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if config.Count <= 0 || *batchSize <= 0 {
+		log.Fatal("count和batch必须大于0")
+	}
+
+	t, err := parseBaseTime(*baseTime)
+	if err != nil {
+		log.Fatal(err)
+	}
+	config.BaseTime = t
+
+	start := time.Now()
+	records := models.GenerateFixtures(config)
+	log.Printf("生成了 %d 条数据，种子 %d，截止时间 %s，耗时 %v",
+		len(records), config.Seed, config.BaseTime.Format(time.RFC3339), time.Since(start))
+
+	if *out == "clickhouse" {
+		err = seedClickHouse(records, *batchSize)
+	} else {
+		err = writeFile(*out, *format, records, *batchSize)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parseBaseTime 解析截止时间，now表示当前整点
+func parseBaseTime(value string) (time.Time, error) {
+	if value == "now" {
+		return time.Now().UTC().Truncate(time.Hour), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("base-time格式错误，应为RFC3339: %w", err)
+	}
+	return t, nil
+}
+
+// seedClickHouse 分批写入ClickHouse
+func seedClickHouse(records []models.KV7Record, batchSize int) error {
+	db, err := database.InitClickHouse()
+	if err != nil {
+		return fmt.Errorf("无法连接ClickHouse: %w", err)
+	}
+	defer db.Close()
+
+	store := models.NewClickHouseStore()
+	for i := 0; i < len(records); i += batchSize {
+		batch := records[i:min(i+batchSize, len(records))]
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		err := store.Insert(ctx, batch)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("写入第%d条起的批次失败: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// writeFile 写入NDJSON或SQL文件
+func writeFile(path, format string, records []models.KV7Record, batchSize int) error {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".sql":
+			format = "sql"
+		case ".ndjson", ".jsonl", ".json", "":
+			format = "ndjson"
+		default:
+			return fmt.Errorf("无法从扩展名判断文件格式，请指定-format")
+		}
+	}
+
+	var write func(f *os.File) error
+	switch format {
+	case "ndjson":
+		write = func(f *os.File) error { return writeNDJSON(f, records) }
+	case "sql":
+		write = func(f *os.File) error { return writeSQL(f, records, batchSize) }
+	default:
+		return fmt.Errorf("不支持的文件格式 %q", format)
+	}
+
+	if path == "-" {
+		return write(os.Stdout)
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("创建目录失败: %w", err)
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %w", err)
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	log.Printf("已写入 %s", path)
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"server/models"
+)
+
+// writeNDJSON 每行写入一条记录，格式与DATA_FIXTURE_FILE和/api/ingest/ndjson相同
+func writeNDJSON(w io.Writer, records []models.KV7Record) error {
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+	encoder.SetEscapeHTML(false)
+	for i := range records {
+		if err := encoder.Encode(&records[i]); err != nil {
+			return fmt.Errorf("写入第%d条记录失败: %w", i, err)
+		}
+	}
+	return bw.Flush()
+}
+
+// writeSQL 写入INSERT语句，每batchSize条记录一条语句，包含kv_7的全部列
+func writeSQL(w io.Writer, records []models.KV7Record, batchSize int) error {
+	bw := bufio.NewWriter(w)
+	columns := models.KV7Columns()
+
+	fmt.Fprintf(bw, "-- 由cmd/seed生成的kv_7测试数据，共%d条\n", len(records))
+	fmt.Fprintln(bw, "-- 使用以下命令导入数据（需要将地址替换为实际值）：")
+	fmt.Fprintln(bw, "-- cat sample_data.sql | clickhouse-client --multiquery")
+	fmt.Fprintln(bw, "-- cat sample_data.sql | curl 'http://localhost:8123/?user=default' --data-binary @-")
+
+	for start := 0; start < len(records); start += batchSize {
+		end := min(start+batchSize, len(records))
+		fmt.Fprintf(bw, "\nINSERT INTO test_db.kv_7 (%s) VALUES\n", strings.Join(columns, ", "))
+		for i := start; i < end; i++ {
+			values := records[i].Values()
+			literals := make([]string, len(values))
+			for j, v := range values {
+				literals[j] = sqlLiteral(v)
+			}
+			separator := ","
+			if i == end-1 {
+				separator = ";"
+			}
+			fmt.Fprintf(bw, "(%s)%s\n", strings.Join(literals, ", "), separator)
+		}
+	}
+	return bw.Flush()
+}
+
+// sqlLiteral 将列值格式化为ClickHouse的字面量
+func sqlLiteral(v interface{}) string {
+	switch x := v.(type) {
+	case time.Time:
+		return "'" + x.UTC().Format("2006-01-02 15:04:05") + "'"
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case int32:
+		return strconv.FormatInt(int64(x), 10)
+	case string:
+		return quoteSQLString(x)
+	}
+	return quoteSQLString(fmt.Sprint(v))
+}
+
+// quoteSQLString 按ClickHouse的规则转义单引号和反斜杠
+func quoteSQLString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return "'" + s + "'"
+}
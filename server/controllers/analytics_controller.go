@@ -8,25 +8,22 @@ import (
 
 	"server/database"
 	"server/models"
+	"server/rowstream"
 	"server/utils"
 )
 
 // AnalyticsController 处理数据分析相关请求
-type AnalyticsController struct{}
+type AnalyticsController struct {
+	store models.LogStore
+}
 
-// NewAnalyticsController 创建一个新的分析控制器
-func NewAnalyticsController() *AnalyticsController {
-	return &AnalyticsController{}
+// NewAnalyticsController 创建一个新的分析控制器，统计基于store中的日志
+func NewAnalyticsController(store models.LogStore) *AnalyticsController {
+	return &AnalyticsController{store: store}
 }
 
 // GetRecentData 获取最近的数据记录
 func (c *AnalyticsController) GetRecentData(w http.ResponseWriter, r *http.Request) {
-	// 只允许GET请求
-	if r.Method != http.MethodGet {
-		utils.RespondWithError(w, http.StatusMethodNotAllowed, "只允许GET请求")
-		return
-	}
-
 	// 检查是否已授权
 	_, err := utils.GetUserFromRequest(r)
 	if err != nil {
@@ -35,31 +32,15 @@ func (c *AnalyticsController) GetRecentData(w http.ResponseWriter, r *http.Reque
 	}
 
 	// 解析请求参数
-	startTimeStr := r.URL.Query().Get("start_time")
-	endTimeStr := r.URL.Query().Get("end_time")
 	category := r.URL.Query().Get("category")
 	action := r.URL.Query().Get("action")
 	platform := r.URL.Query().Get("platform")
 	limitStr := r.URL.Query().Get("limit")
 
-	// 处理时间参数
+	// 处理时间参数，未指定时不限制
 	var startTime, endTime time.Time
-	if startTimeStr != "" {
-		var err error
-		startTime, err = time.Parse(time.RFC3339, startTimeStr)
-		if err != nil {
-			utils.RespondWithError(w, http.StatusBadRequest, "开始时间格式无效")
-			return
-		}
-	}
-
-	if endTimeStr != "" {
-		var err error
-		endTime, err = time.Parse(time.RFC3339, endTimeStr)
-		if err != nil {
-			utils.RespondWithError(w, http.StatusBadRequest, "结束时间格式无效")
-			return
-		}
+	if !parseTimeRange(w, r, &startTime, &endTime) {
+		return
 	}
 
 	// 处理限制参数
@@ -73,29 +54,19 @@ func (c *AnalyticsController) GetRecentData(w http.ResponseWriter, r *http.Reque
 		}
 	}
 
-	// 从ClickHouse获取数据
-	records, err := models.GetRecentRecords(startTime, endTime, category, action, platform, limit)
+	// 从日志存储获取数据
+	records, err := models.GetRecentRecords(r.Context(), c.store, startTime, endTime, category, action, platform, limit)
 	if err != nil {
-		log.Printf("获取ClickHouse数据失败: %v", err)
-		utils.RespondWithError(w, http.StatusInternalServerError, "获取数据失败")
+		respondDataError(w, "获取数据失败", err)
 		return
 	}
 
 	// 返回结果
-	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"success": true,
-		"data":    records,
-	})
+	utils.Respond(w, http.StatusOK, utils.Response{Columns: models.KV7ResultColumns(models.RecentRecordColumns), Data: records})
 }
 
 // GetRecordDetail 获取单条记录的详细信息
 func (c *AnalyticsController) GetRecordDetail(w http.ResponseWriter, r *http.Request) {
-	// 只允许GET请求
-	if r.Method != http.MethodGet {
-		utils.RespondWithError(w, http.StatusMethodNotAllowed, "只允许GET请求")
-		return
-	}
-
 	// 检查是否已授权
 	_, err := utils.GetUserFromRequest(r)
 	if err != nil {
@@ -110,29 +81,19 @@ func (c *AnalyticsController) GetRecordDetail(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	// 从ClickHouse获取完整记录
-	record, err := models.GetFullKV7Record(recordID)
+	// 从日志存储获取完整记录
+	record, err := c.store.Get(r.Context(), recordID)
 	if err != nil {
-		log.Printf("获取记录详情失败: %v", err)
-		utils.RespondWithError(w, http.StatusInternalServerError, "获取记录详情失败")
+		respondDataError(w, "获取记录详情失败", err)
 		return
 	}
 
-	// 返回结果
-	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"success": true,
-		"data":    record,
-	})
+	// 返回结果，记录包含全部列
+	utils.Respond(w, http.StatusOK, utils.Response{Columns: models.KV7ResultColumns(models.KV7Columns()), Data: record})
 }
 
 // GetEventAnalytics 获取事件分析数据
 func (c *AnalyticsController) GetEventAnalytics(w http.ResponseWriter, r *http.Request) {
-	// 只允许GET请求
-	if r.Method != http.MethodGet {
-		utils.RespondWithError(w, http.StatusMethodNotAllowed, "只允许GET请求")
-		return
-	}
-
 	// 检查是否已授权
 	_, err := utils.GetUserFromRequest(r)
 	if err != nil {
@@ -141,52 +102,26 @@ func (c *AnalyticsController) GetEventAnalytics(w http.ResponseWriter, r *http.R
 	}
 
 	// 解析请求参数
-	startTimeStr := r.URL.Query().Get("start_time")
-	endTimeStr := r.URL.Query().Get("end_time")
 
-	// 处理时间参数
+	// 处理时间参数，未指定时不限制
 	var startTime, endTime time.Time
-	if startTimeStr != "" {
-		var err error
-		startTime, err = time.Parse(time.RFC3339, startTimeStr)
-		if err != nil {
-			utils.RespondWithError(w, http.StatusBadRequest, "开始时间格式无效")
-			return
-		}
-	}
-
-	if endTimeStr != "" {
-		var err error
-		endTime, err = time.Parse(time.RFC3339, endTimeStr)
-		if err != nil {
-			utils.RespondWithError(w, http.StatusBadRequest, "结束时间格式无效")
-			return
-		}
+	if !parseTimeRange(w, r, &startTime, &endTime) {
+		return
 	}
 
-	// 从ClickHouse获取数据
-	results, err := models.GetEventAnalytics(startTime, endTime)
+	// 从日志存储获取数据
+	results, err := models.GetEventAnalytics(r.Context(), c.store, startTime, endTime)
 	if err != nil {
-		log.Printf("获取事件分析数据失败: %v", err)
-		utils.RespondWithError(w, http.StatusInternalServerError, "获取事件分析数据失败")
+		respondDataError(w, "获取事件分析数据失败", err)
 		return
 	}
 
 	// 返回结果
-	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"success": true,
-		"data":    results,
-	})
+	utils.RespondWithJSON(w, http.StatusOK, results)
 }
 
 // GetUserDistribution 获取用户分布数据
 func (c *AnalyticsController) GetUserDistribution(w http.ResponseWriter, r *http.Request) {
-	// 只允许GET请求
-	if r.Method != http.MethodGet {
-		utils.RespondWithError(w, http.StatusMethodNotAllowed, "只允许GET请求")
-		return
-	}
-
 	// 检查是否已授权
 	_, err := utils.GetUserFromRequest(r)
 	if err != nil {
@@ -195,113 +130,26 @@ func (c *AnalyticsController) GetUserDistribution(w http.ResponseWriter, r *http
 	}
 
 	// 解析请求参数
-	startTimeStr := r.URL.Query().Get("start_time")
-	endTimeStr := r.URL.Query().Get("end_time")
 
-	// 处理时间参数
+	// 处理时间参数，未指定时不限制
 	var startTime, endTime time.Time
-	if startTimeStr != "" {
-		var err error
-		startTime, err = time.Parse(time.RFC3339, startTimeStr)
-		if err != nil {
-			utils.RespondWithError(w, http.StatusBadRequest, "开始时间格式无效")
-			return
-		}
-	}
-
-	if endTimeStr != "" {
-		var err error
-		endTime, err = time.Parse(time.RFC3339, endTimeStr)
-		if err != nil {
-			utils.RespondWithError(w, http.StatusBadRequest, "结束时间格式无效")
-			return
-		}
+	if !parseTimeRange(w, r, &startTime, &endTime) {
+		return
 	}
 
 	// 从ClickHouse获取数据
-	results, err := models.GetUserDistribution(startTime, endTime)
+	results, err := models.GetUserDistribution(r.Context(), c.store, startTime, endTime)
 	if err != nil {
-		log.Printf("获取用户分布数据失败: %v", err)
-		utils.RespondWithError(w, http.StatusInternalServerError, "获取用户分布数据失败")
+		respondDataError(w, "获取用户分布数据失败", err)
 		return
 	}
 
 	// 返回结果
-	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"success": true,
-		"data":    results,
-	})
-}
-
-// parseQueryParams 解析查询参数
-func parseQueryParams(r *http.Request) database.QueryOptions {
-	options := database.DefaultQueryOptions()
-
-	// 解析时间范围
-	startTimeStr := r.URL.Query().Get("start_time")
-	if startTimeStr != "" {
-		startTime, err := time.Parse(time.RFC3339, startTimeStr)
-		if err == nil {
-			options.StartTime = startTime
-		}
-	}
-
-	endTimeStr := r.URL.Query().Get("end_time")
-	if endTimeStr != "" {
-		endTime, err := time.Parse(time.RFC3339, endTimeStr)
-		if err == nil {
-			options.EndTime = endTime
-		}
-	}
-
-	// 解析分页参数
-	limitStr := r.URL.Query().Get("limit")
-	if limitStr != "" {
-		limit, err := strconv.Atoi(limitStr)
-		if err == nil && limit > 0 {
-			options.Limit = limit
-		}
-	}
-
-	offsetStr := r.URL.Query().Get("offset")
-	if offsetStr != "" {
-		offset, err := strconv.Atoi(offsetStr)
-		if err == nil && offset >= 0 {
-			options.Offset = offset
-		}
-	}
-
-	// 解析筛选参数
-	options.Category = r.URL.Query().Get("category")
-	options.Action = r.URL.Query().Get("action")
-	options.Platform = r.URL.Query().Get("platform")
-	options.AppID = r.URL.Query().Get("app_id")
-	options.UserID = r.URL.Query().Get("user_id")
-	options.OS = r.URL.Query().Get("os")
-	options.Version = r.URL.Query().Get("version")
-
-	// 解析排序参数
-	sortBy := r.URL.Query().Get("sort_by")
-	if sortBy != "" {
-		options.SortBy = sortBy
-	}
-
-	sortOrder := r.URL.Query().Get("sort_order")
-	if sortOrder == "asc" || sortOrder == "desc" {
-		options.SortOrder = sortOrder
-	}
-
-	return options
+	utils.RespondWithJSON(w, http.StatusOK, results)
 }
 
 // GetNetworkPerformance 获取网络性能统计数据
 func (c *AnalyticsController) GetNetworkPerformance(w http.ResponseWriter, r *http.Request) {
-	// 检查请求方法
-	if r.Method != http.MethodGet {
-		utils.RespondWithError(w, http.StatusMethodNotAllowed, "方法不允许")
-		return
-	}
-
 	// 验证授权
 	token := r.Header.Get("Authorization")
 	if !utils.IsValidToken(token) {
@@ -311,38 +159,28 @@ func (c *AnalyticsController) GetNetworkPerformance(w http.ResponseWriter, r *ht
 
 	// 解析查询参数
 	queryParams := r.URL.Query()
-	startTimeStr := queryParams.Get("start_time")
-	endTimeStr := queryParams.Get("end_time")
 	platform := queryParams.Get("platform")
 	os := queryParams.Get("os")
 	userID := queryParams.Get("user_id")
+	appID := queryParams.Get("app_id")
 
 	// 创建查询选项
 	options := database.QueryOptions{
 		Platform: platform,
 		OS:       os,
 		UserID:   userID,
+		AppID:    appID,
 	}
 
-	// 解析时间参数
-	if startTimeStr != "" {
-		startTime, err := time.Parse(time.RFC3339, startTimeStr)
-		if err == nil {
-			options.StartTime = startTime
-		}
-	}
-
-	if endTimeStr != "" {
-		endTime, err := time.Parse(time.RFC3339, endTimeStr)
-		if err == nil {
-			options.EndTime = endTime
-		}
+	// 解析时间参数，未指定时不限制
+	if !parseTimeRange(w, r, &options.StartTime, &options.EndTime) {
+		return
 	}
 
 	// 获取网络性能统计数据
-	results, err := models.GetNetworkPerformanceStats(options)
+	results, err := models.GetNetworkPerformanceStats(r.Context(), c.store, options)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "获取网络性能统计失败")
+		respondDataError(w, "获取网络性能统计失败", err)
 		return
 	}
 
@@ -352,12 +190,6 @@ func (c *AnalyticsController) GetNetworkPerformance(w http.ResponseWriter, r *ht
 
 // GetIOSDeviceStats 获取iOS设备统计数据
 func (c *AnalyticsController) GetIOSDeviceStats(w http.ResponseWriter, r *http.Request) {
-	// 检查请求方法
-	if r.Method != http.MethodGet {
-		utils.RespondWithError(w, http.StatusMethodNotAllowed, "方法不允许")
-		return
-	}
-
 	// 验证授权
 	token := r.Header.Get("Authorization")
 	if !utils.IsValidToken(token) {
@@ -367,8 +199,6 @@ func (c *AnalyticsController) GetIOSDeviceStats(w http.ResponseWriter, r *http.R
 
 	// 解析查询参数
 	queryParams := r.URL.Query()
-	startTimeStr := queryParams.Get("start_time")
-	endTimeStr := queryParams.Get("end_time")
 	category := queryParams.Get("category")
 	userID := queryParams.Get("user_id")
 
@@ -378,25 +208,15 @@ func (c *AnalyticsController) GetIOSDeviceStats(w http.ResponseWriter, r *http.R
 		UserID:   userID,
 	}
 
-	// 解析时间参数
-	if startTimeStr != "" {
-		startTime, err := time.Parse(time.RFC3339, startTimeStr)
-		if err == nil {
-			options.StartTime = startTime
-		}
-	}
-
-	if endTimeStr != "" {
-		endTime, err := time.Parse(time.RFC3339, endTimeStr)
-		if err == nil {
-			options.EndTime = endTime
-		}
+	// 解析时间参数，未指定时不限制
+	if !parseTimeRange(w, r, &options.StartTime, &options.EndTime) {
+		return
 	}
 
 	// 获取iOS设备统计数据
-	results, err := models.GetIOSDeviceStats(options)
+	results, err := models.GetIOSDeviceStats(r.Context(), c.store, options)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "获取iOS设备统计失败")
+		respondDataError(w, "获取iOS设备统计失败", err)
 		return
 	}
 
@@ -406,12 +226,6 @@ func (c *AnalyticsController) GetIOSDeviceStats(w http.ResponseWriter, r *http.R
 
 // QueryKV7Table 查询kv_7表数据
 func (c *AnalyticsController) QueryKV7Table(w http.ResponseWriter, r *http.Request) {
-	// 检查请求方法
-	if r.Method != http.MethodGet {
-		utils.RespondWithError(w, http.StatusMethodNotAllowed, "方法不允许")
-		return
-	}
-
 	// 解析查询参数
 	queryParams := r.URL.Query()
 	limitStr := queryParams.Get("limit")
@@ -439,15 +253,20 @@ func (c *AnalyticsController) QueryKV7Table(w http.ResponseWriter, r *http.Reque
 		}
 	}
 
+	// cursor为上一页响应中的next_cursor，指定时代替offset
+	if cursorOffset, err := utils.DecodeCursor(queryParams.Get("cursor")); err == nil {
+		offset = cursorOffset
+	}
+
 	// 判断是否需要获取总记录数
 	if countStr != "" {
 		needCount = countStr == "true" || countStr == "1"
 	}
 
 	// 获取kv_7表数据
-	db := database.GetClickHouseConn()
-	if db == nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "数据库连接失败")
+	db, err := database.Conn()
+	if err != nil {
+		respondDataError(w, "查询数据失败", err)
 		return
 	}
 
@@ -476,10 +295,10 @@ func (c *AnalyticsController) QueryKV7Table(w http.ResponseWriter, r *http.Reque
 		countQuery := "SELECT COUNT(*) FROM kv_7 " + whereClause
 
 		// 执行COUNT查询
-		countRow := db.QueryRow(countQuery, whereParams...)
+		countRow := db.QueryRowContext(r.Context(), countQuery, whereParams...)
 		if err := countRow.Scan(&totalCount); err != nil {
-			log.Printf("获取kv_7表总记录数失败: %v", err)
-			totalCount = 0 // 失败时使用0作为默认值
+			respondDataError(w, "获取kv_7表总记录数失败", err)
+			return
 		}
 
 		log.Printf("总记录数查询: %s, 参数: %v, 结果: %d", countQuery, whereParams, totalCount)
@@ -500,82 +319,36 @@ func (c *AnalyticsController) QueryKV7Table(w http.ResponseWriter, r *http.Reque
 	log.Printf("执行查询: %s, 参数: %v", query, finalParams)
 
 	// 执行查询
-	rows, err := db.Query(query, finalParams...)
+	rows, err := db.QueryContext(r.Context(), query, finalParams...)
 	if err != nil {
-		log.Printf("查询kv_7表失败: %v", err)
-		utils.RespondWithError(w, http.StatusInternalServerError, "查询数据失败")
+		respondDataError(w, "查询数据失败", err)
 		return
 	}
 	defer rows.Close()
 
-	// 处理查询结果
-	var results []map[string]interface{}
-	columns, err := rows.Columns()
+	// 获取列信息
+	columns, err := rowstream.Columns(rows)
 	if err != nil {
-		log.Printf("获取列名失败: %v", err)
-		utils.RespondWithError(w, http.StatusInternalServerError, "处理查询结果失败")
+		respondDataError(w, "处理查询结果失败", err)
 		return
 	}
 
-	// 创建接收数据的切片
-	count := len(columns)
-	values := make([]interface{}, count)
-	valuePtrs := make([]interface{}, count)
-
-	for i := range columns {
-		valuePtrs[i] = &values[i]
-	}
-
-	// 遍历结果集
-	for rows.Next() {
-		err := rows.Scan(valuePtrs...)
-		if err != nil {
-			log.Printf("扫描行数据失败: %v", err)
-			continue
+	// 逐行编码写出，格式由Accept请求头决定
+	// 未请求总数时按当前页是否取满判断是否有下一页
+	written, err := rowstream.Stream(rowstream.NewEncoder(w, rowstream.Negotiate(r)), rows, columns, 0, -1, func(written, read int, err error) map[string]interface{} {
+		total := -1
+		if needCount {
+			total = totalCount
 		}
-
-		// 将数据转换为map
-		entry := make(map[string]interface{})
-		for i, col := range columns {
-			val := values[i]
-
-			// 处理不同类型的数据
-			switch v := val.(type) {
-			case []byte:
-				entry[col] = string(v)
-			default:
-				entry[col] = v
-			}
-		}
-
-		results = append(results, entry)
-	}
-
-	// 检查遍历过程中是否有错误
-	if err = rows.Err(); err != nil {
+		return streamTrailer(w, utils.NewPagination(total, offset, limit, written), utils.Meta{}, err)
+	})
+	if err != nil {
+		// 响应头已发送，错误已写入响应的error字段
 		log.Printf("遍历结果集时发生错误: %v", err)
-		utils.RespondWithError(w, http.StatusInternalServerError, "处理查询结果失败")
 		return
 	}
 
 	// 日志记录接收到的请求和返回的数据量
 	log.Printf("KV7查询: limit=%d, offset=%d, start_time=%s, end_time=%s, needCount=%v, 返回数据量=%d, 总记录数=%d",
-		limit, offset, startTime, endTime, needCount, len(results), totalCount)
-
-	// 返回结果
-	response := map[string]interface{}{
-		"success": true,
-		"data":    results,
-		"limit":   limit,
-		"offset":  offset,
-	}
-
-	// 只有当请求要求获取总数时才返回总数
-	if needCount {
-		response["total"] = totalCount
-		response["count"] = totalCount
-		response["dbTotalCount"] = totalCount
-	}
-
-	utils.RespondWithJSON(w, http.StatusOK, response)
+		limit, offset, startTime, endTime, needCount, written, totalCount)
 }
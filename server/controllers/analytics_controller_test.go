@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAnalyticsAggregates(t *testing.T) {
+	now := time.Now()
+	c := NewAnalyticsController(newTestStore(now))
+	since := "start_time=" + url.QueryEscape(now.Add(-24*time.Hour).Format(time.RFC3339))
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		query   string
+		want    string
+	}{
+		{"events", c.GetEventAnalytics, "",
+			`[{"category":"PERF_NET_SSE","action":"request","count":2},{"category":"page","action":"view","count":2}]`},
+		{"events", c.GetEventAnalytics, since,
+			`[{"category":"PERF_NET_SSE","action":"request","count":2},{"category":"page","action":"view","count":1}]`},
+		// u1在iOS上有两条记录，按用户去重
+		{"users", c.GetUserDistribution, "",
+			`[{"os":"iOS","count":2,"percent":66.67},{"os":"Android","count":1,"percent":33.33}]`},
+		{"users", c.GetUserDistribution, since,
+			`[{"os":"Android","count":1,"percent":50},{"os":"iOS","count":1,"percent":50}]`},
+		{"users", c.GetUserDistribution, "start_time=" + url.QueryEscape(now.Add(time.Hour).Format(time.RFC3339)), `[]`},
+	}
+	for _, tt := range tests {
+		status, resp := serve(t, tt.handler, httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil))
+		if status != http.StatusOK {
+			t.Errorf("%s %s: 状态码为%d，错误%+v", tt.name, tt.query, status, resp.Error)
+			continue
+		}
+		var got, want interface{}
+		json.Unmarshal(resp.Data, &got)
+		json.Unmarshal([]byte(tt.want), &want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s %s: 返回%s，期望%s", tt.name, tt.query, resp.Data, tt.want)
+		}
+	}
+}
+
+func TestAnalyticsRecords(t *testing.T) {
+	c := NewAnalyticsController(newTestStore(time.Now()))
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		query   string
+		status  int
+		ids     string
+	}{
+		{"recent", c.GetRecentData, "", http.StatusOK, "r3,r2,r1,old"},
+		{"recent", c.GetRecentData, "platform=iOS&limit=2", http.StatusOK, "r3,r1"},
+		{"recent", c.GetRecentData, "category=page&action=view", http.StatusOK, "r3,old"},
+		{"recent", c.GetRecentData, "limit=x", http.StatusBadRequest, ""},
+		{"recent", c.GetRecentData, "start_time=yesterday", http.StatusBadRequest, ""},
+		{"record", c.GetRecordDetail, "id=r2", http.StatusOK, ""},
+		{"record", c.GetRecordDetail, "id=missing", http.StatusNotFound, ""},
+		{"record", c.GetRecordDetail, "", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		status, resp := serve(t, tt.handler, httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil))
+		if status != tt.status {
+			t.Errorf("%s %s: 状态码为%d，期望%d，错误%+v", tt.name, tt.query, status, tt.status, resp.Error)
+			continue
+		}
+		if tt.ids != "" {
+			if got := recordIDs(t, resp.Data); got != tt.ids {
+				t.Errorf("%s %s: 返回%s，期望%s", tt.name, tt.query, got, tt.ids)
+			}
+		}
+	}
+}
+
+func TestNetworkPerformance(t *testing.T) {
+	c := NewAnalyticsController(newTestStore(time.Now()))
+	tests := []struct {
+		query    string
+		networks string
+		total    float64
+	}{
+		{"", `[{"type":"4g","count":1},{"type":"wifi","count":1}]`, 200},
+		{"platform=iOS", `[{"type":"wifi","count":1}]`, 300},
+		{"platform=Web", `[]`, 0},
+	}
+	for _, tt := range tests {
+		status, resp := serve(t, c.GetNetworkPerformance, httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil))
+		if status != http.StatusOK {
+			t.Errorf("%s: 状态码为%d，错误%+v", tt.query, status, resp.Error)
+			continue
+		}
+		var data struct {
+			NetworkTypes json.RawMessage `json:"network_types"`
+			ResponseTime struct {
+				Total float64 `json:"total"`
+			} `json:"response_time"`
+		}
+		if err := json.Unmarshal(resp.Data, &data); err != nil {
+			t.Fatalf("解析统计失败: %v\n%s", err, resp.Data)
+		}
+		var got, want interface{}
+		json.Unmarshal(data.NetworkTypes, &got)
+		json.Unmarshal([]byte(tt.networks), &want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: 网络类型为%s，期望%s", tt.query, data.NetworkTypes, tt.networks)
+		}
+		if data.ResponseTime.Total != tt.total {
+			t.Errorf("%s: 平均总耗时为%v，期望%v", tt.query, data.ResponseTime.Total, tt.total)
+		}
+	}
+}
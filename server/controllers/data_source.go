@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+
+	"server/database"
+	"server/models"
+	"server/utils"
+)
+
+// DataErrorStatus 返回数据查询错误对应的HTTP状态码
+//   - 按ID查找的日志不存在: 404
+//   - 当前数据源不支持该操作: 501
+//   - 无法连接ClickHouse: 503
+//   - 查询超时: 504
+//   - ClickHouse返回的错误: 502
+//   - 其他错误: 500
+func DataErrorStatus(err error) int {
+	var exception *clickhouse.Exception
+	switch {
+	case errors.Is(err, models.ErrLogNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrUnsupported):
+		return http.StatusNotImplemented
+	case errors.Is(err, database.ErrUnavailable), errors.Is(err, database.ErrQueueClosed):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.As(err, &exception):
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+// respondDataError 按DataErrorStatus返回数据查询失败的响应，不再以模拟数据代替
+func respondDataError(w http.ResponseWriter, message string, err error) {
+	log.Printf("%s: %v", message, err)
+	utils.RespondWithError(w, DataErrorStatus(err), fmt.Sprintf("%s: %v", message, err))
+}
+
+// respondQueryError SQL控制台的语句被ClickHouse拒绝(如列不存在)时返回400和invalid_query，其他错误同respondDataError
+func respondQueryError(w http.ResponseWriter, message string, err error) {
+	var exception *clickhouse.Exception
+	if errors.As(err, &exception) {
+		log.Printf("%s: %v", message, err)
+		utils.RespondWithErrorCode(w, http.StatusBadRequest, utils.CodeInvalidQuery, fmt.Sprintf("%s: %s", message, exception.Message), nil)
+		return
+	}
+	respondDataError(w, message, err)
+}
+
+// streamTrailer 流式结果写在data之后的字段，读取中途出错时按DataErrorStatus给出错误码
+func streamTrailer(w http.ResponseWriter, page *utils.Pagination, meta utils.Meta, err error) map[string]interface{} {
+	return utils.Trailer(w, page, meta, DataErrorStatus(err), err)
+}
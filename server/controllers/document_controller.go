@@ -4,9 +4,10 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
-	"strings"
+	"sync"
 	"time"
 
+	"server/router"
 	"server/utils"
 )
 
@@ -24,6 +25,9 @@ type Document struct {
 	UpdateAt   time.Time `json:"-"`
 }
 
+// documentsMu 保护documents，文档接口可能被并发调用
+var documentsMu sync.RWMutex
+
 // 存储文档的内存数据库（模拟数据）
 var documents = []Document{
 	{
@@ -60,38 +64,11 @@ func NewDocumentController() *DocumentController {
 	return &DocumentController{}
 }
 
-// HandleDocuments 处理文档列表请求
-func (c *DocumentController) HandleDocuments(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		c.GetDocuments(w, r)
-	case http.MethodPost:
-		c.CreateDocument(w, r)
-	default:
-		utils.RespondWithError(w, http.StatusMethodNotAllowed, "仅支持GET和POST请求")
-	}
-}
-
-// HandleDocument 处理单个文档请求
-func (c *DocumentController) HandleDocument(w http.ResponseWriter, r *http.Request) {
-	// 从URL中提取文档ID
-	id := strings.TrimPrefix(r.URL.Path, "/api/documents/")
-
-	// 根据HTTP方法调用不同处理函数
-	switch r.Method {
-	case http.MethodGet:
-		c.GetDocument(w, r, id)
-	case http.MethodPut:
-		c.UpdateDocument(w, r, id)
-	case http.MethodDelete:
-		c.DeleteDocument(w, r, id)
-	default:
-		utils.RespondWithError(w, http.StatusMethodNotAllowed, "仅支持GET、PUT和DELETE请求")
-	}
-}
-
 // GetDocuments 获取文档列表
 func (c *DocumentController) GetDocuments(w http.ResponseWriter, r *http.Request) {
+	documentsMu.RLock()
+	defer documentsMu.RUnlock()
+
 	// 简化版：返回不包含内容的文档列表
 	docsWithoutContent := make([]Document, len(documents))
 	for i, doc := range documents {
@@ -103,7 +80,10 @@ func (c *DocumentController) GetDocuments(w http.ResponseWriter, r *http.Request
 }
 
 // GetDocument 获取单个文档
-func (c *DocumentController) GetDocument(w http.ResponseWriter, r *http.Request, id string) {
+func (c *DocumentController) GetDocument(w http.ResponseWriter, r *http.Request) {
+	id := router.Param(r, "id")
+	documentsMu.RLock()
+	defer documentsMu.RUnlock()
 	for _, doc := range documents {
 		if doc.ID == id {
 			utils.RespondWithJSON(w, http.StatusOK, doc)
@@ -131,14 +111,17 @@ func (c *DocumentController) CreateDocument(w http.ResponseWriter, r *http.Reque
 	newDoc.UpdateAt = now
 
 	// 添加到文档列表
+	documentsMu.Lock()
 	documents = append(documents, newDoc)
+	documentsMu.Unlock()
 
 	log.Printf("创建了新文档: ID=%s, 标题=%s", newDoc.ID, newDoc.Title)
 	utils.RespondWithJSON(w, http.StatusCreated, newDoc)
 }
 
 // UpdateDocument 更新文档
-func (c *DocumentController) UpdateDocument(w http.ResponseWriter, r *http.Request, id string) {
+func (c *DocumentController) UpdateDocument(w http.ResponseWriter, r *http.Request) {
+	id := router.Param(r, "id")
 	var updatedDoc Document
 	decoder := json.NewDecoder(r.Body)
 	if err := decoder.Decode(&updatedDoc); err != nil {
@@ -146,6 +129,8 @@ func (c *DocumentController) UpdateDocument(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	documentsMu.Lock()
+	defer documentsMu.Unlock()
 	for i, doc := range documents {
 		if doc.ID == id {
 			// 更新文档
@@ -165,7 +150,10 @@ func (c *DocumentController) UpdateDocument(w http.ResponseWriter, r *http.Reque
 }
 
 // DeleteDocument 删除文档
-func (c *DocumentController) DeleteDocument(w http.ResponseWriter, r *http.Request, id string) {
+func (c *DocumentController) DeleteDocument(w http.ResponseWriter, r *http.Request) {
+	id := router.Param(r, "id")
+	documentsMu.Lock()
+	defer documentsMu.Unlock()
 	for i, doc := range documents {
 		if doc.ID == id {
 			// 删除文档
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+
+	"server/models"
+	"server/router"
+	"server/utils"
+)
+
+// ExportController 处理异步导出任务，每个用户只能查看、下载和取消自己创建的任务，其他用户的任务按不存在处理
+type ExportController struct {
+	jobs *exportJobManager
+}
+
+// NewExportController 创建导出任务控制器并启动worker，导出store中的日志
+func NewExportController(store models.LogStore) (*ExportController, error) {
+	jobs, err := newExportJobManager(defaultExportJobConfig(), store)
+	if err != nil {
+		return nil, err
+	}
+	return &ExportController{jobs: jobs}, nil
+}
+
+// Close 停止worker并中断运行中的导出任务
+func (c *ExportController) Close() {
+	c.jobs.Close()
+}
+
+// CreateExport 创建异步导出任务，参数与 /api/logs/export 相同，通过查询字符串传递
+func (c *ExportController) CreateExport(w http.ResponseWriter, r *http.Request) {
+	req, ok := parseExportRequest(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := c.jobs.Submit(req, requestUser(r))
+	if err != nil {
+		if errors.Is(err, errExportQueueFull) {
+			utils.RespondWithError(w, http.StatusServiceUnavailable, "导出任务过多，请稍后重试")
+			return
+		}
+		log.Printf("创建导出任务失败: %v", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "创建导出任务失败")
+		return
+	}
+
+	w.Header().Set("Location", "/api/exports/"+job.ID)
+	utils.RespondWithJSON(w, http.StatusAccepted, job)
+}
+
+// ListExports 获取当前用户的导出任务
+func (c *ExportController) ListExports(w http.ResponseWriter, r *http.Request) {
+	utils.RespondWithJSON(w, http.StatusOK, c.jobs.List(requestUser(r)))
+}
+
+// GetExport 获取导出任务的状态和进度，只能查看当前用户创建的任务
+func (c *ExportController) GetExport(w http.ResponseWriter, r *http.Request) {
+	id := router.Param(r, "id")
+	job, ok := c.jobs.Get(id, requestUser(r))
+	if !ok {
+		utils.RespondWithError(w, http.StatusNotFound, "导出任务不存在或已过期")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, job)
+}
+
+// DownloadExport 下载已完成任务的文件，路径为 /api/exports/{id}/download，支持Range断点续传
+func (c *ExportController) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	id := router.Param(r, "id")
+	f, job, err := c.jobs.Open(id, requestUser(r))
+	if err != nil {
+		if os.IsNotExist(err) {
+			utils.RespondWithError(w, http.StatusNotFound, "导出任务不存在或已过期")
+			return
+		}
+		log.Printf("打开导出文件失败: %v", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "打开导出文件失败")
+		return
+	}
+	if f == nil {
+		utils.RespondWithError(w, http.StatusConflict, "导出任务尚未完成，当前状态: "+job.Status)
+		return
+	}
+	defer f.Close()
+
+	filename := "logs_export_" + job.ID + "." + job.Request.extension()
+	w.Header().Set("Content-Type", job.Request.contentType())
+	w.Header().Set("Content-Disposition", "attachment; filename="+filename)
+
+	// ServeContent处理Range、If-Range和HEAD请求
+	http.ServeContent(w, r, filename, *job.FinishedAt, f)
+}
+
+// CancelExport 取消未完成的任务，或删除已结束的任务及其文件
+func (c *ExportController) CancelExport(w http.ResponseWriter, r *http.Request) {
+	id := router.Param(r, "id")
+	if !c.jobs.Cancel(id, requestUser(r)) {
+		utils.RespondWithError(w, http.StatusNotFound, "导出任务不存在或已过期")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "导出任务已取消"})
+}
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"server/models"
+	"server/router"
+)
+
+func TestExportLogs(t *testing.T) {
+	c := NewLogsController(newTestStore(time.Now()))
+	tests := []struct {
+		query  string
+		status int
+		body   string
+	}{
+		{"columns=id,platform", http.StatusOK, "id,platform\nr3,iOS\nr2,Android\nr1,iOS\n"},
+		{"columns=id,platform&platform=iOS&limit=1", http.StatusOK, "id,platform\nr3,iOS\n"},
+		{"columns=id,v1&format=ndjson&level=ERROR", http.StatusOK, `{"id":"r1","v1":300}` + "\n"},
+		{"format=pdf", http.StatusBadRequest, ""},
+		{"format=xlsx&gzip=true", http.StatusBadRequest, ""},
+		{"columns=nope", http.StatusBadRequest, ""},
+		{"limit=-1", http.StatusBadRequest, ""},
+		{"q=level:", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		c.ExportLogs(rec, httptest.NewRequest(http.MethodGet, "/api/logs/export?"+tt.query, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s: 状态码为%d，期望%d\n%s", tt.query, rec.Code, tt.status, rec.Body.String())
+			continue
+		}
+		if tt.body != "" && rec.Body.String() != tt.body {
+			t.Errorf("%s: 导出内容为%q，期望%q", tt.query, rec.Body.String(), tt.body)
+		}
+	}
+}
+
+// newTestExportRouter 返回使用临时目录的导出任务路由，测试结束时停止worker
+func newTestExportRouter(t *testing.T) http.HandlerFunc {
+	t.Helper()
+	jobs, err := newExportJobManager(exportJobConfig{Dir: t.TempDir(), Workers: 1, QueueSize: 10, TTL: time.Hour}, newTestStore(time.Now()))
+	if err != nil {
+		t.Fatalf("创建导出任务管理器失败: %v", err)
+	}
+	c := &ExportController{jobs: jobs}
+	t.Cleanup(c.Close)
+
+	rt := router.New()
+	rt.Get("/api/exports", "导出任务列表", c.ListExports)
+	rt.Post("/api/exports", "创建导出任务", c.CreateExport)
+	rt.Get("/api/exports/{id}", "导出任务状态", c.GetExport)
+	rt.Delete("/api/exports/{id}", "取消导出任务", c.CancelExport)
+	rt.Get("/api/exports/{id}/download", "下载导出文件", c.DownloadExport)
+	return rt.ServeHTTP
+}
+
+// exportJobStatus 解析任务状态
+func exportJobStatus(t *testing.T, data json.RawMessage) (string, string, int64) {
+	t.Helper()
+	var job struct {
+		ID          string `json:"id"`
+		Status      string `json:"status"`
+		RowsWritten int64  `json:"rows_written"`
+	}
+	if err := json.Unmarshal(data, &job); err != nil {
+		t.Fatalf("解析任务失败: %v\n%s", err, data)
+	}
+	return job.ID, job.Status, job.RowsWritten
+}
+
+func TestExportJobs(t *testing.T) {
+	handler := newTestExportRouter(t)
+
+	status, resp := serve(t, handler, httptest.NewRequest(http.MethodPost, "/api/exports?columns=id&platform=iOS", nil))
+	if status != http.StatusAccepted {
+		t.Fatalf("创建任务的状态码为%d，错误%+v", status, resp.Error)
+	}
+	id, _, _ := exportJobStatus(t, resp.Data)
+
+	// 等待任务完成
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, resp = serve(t, handler, httptest.NewRequest(http.MethodGet, "/api/exports/"+id, nil))
+		if status != http.StatusOK {
+			t.Fatalf("查询任务的状态码为%d，错误%+v", status, resp.Error)
+		}
+		_, jobStatus, rows := exportJobStatus(t, resp.Data)
+		if jobStatus == exportStatusCompleted {
+			if rows != 2 {
+				t.Errorf("写入了%d行，期望2行", rows)
+			}
+			break
+		}
+		if jobStatus == exportStatusFailed || time.Now().After(deadline) {
+			t.Fatalf("任务状态为%s", jobStatus)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/api/exports/"+id+"/download", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "id\nr3\nr1\n" {
+		t.Errorf("下载的状态码为%d，内容%q", rec.Code, rec.Body.String())
+	}
+
+	status, resp = serve(t, handler, httptest.NewRequest(http.MethodGet, "/api/exports", nil))
+	var list []json.RawMessage
+	if err := json.Unmarshal(resp.Data, &list); status != http.StatusOK || err != nil || len(list) != 1 {
+		t.Errorf("任务列表的状态码为%d，内容%s", status, resp.Data)
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{http.MethodPost, "/api/exports?format=pdf", http.StatusBadRequest},
+		{http.MethodDelete, "/api/exports/" + id, http.StatusOK},
+		{http.MethodGet, "/api/exports/" + id, http.StatusNotFound},
+		{http.MethodGet, "/api/exports/" + id + "/download", http.StatusNotFound},
+		{http.MethodDelete, "/api/exports/" + id, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		status, resp := serve(t, handler, httptest.NewRequest(tt.method, tt.path, nil))
+		if status != tt.status {
+			t.Errorf("%s %s: 状态码为%d，期望%d，错误%+v", tt.method, tt.path, status, tt.status, resp.Error)
+		}
+	}
+}
+
+func TestExportJobOwner(t *testing.T) {
+	jobs, err := newExportJobManager(exportJobConfig{Dir: t.TempDir(), Workers: 1, QueueSize: 10, TTL: time.Hour}, newTestStore(time.Now()))
+	if err != nil {
+		t.Fatalf("创建导出任务管理器失败: %v", err)
+	}
+	defer jobs.Close()
+
+	req := &exportRequest{Format: "csv", Columns: []models.ExportColumn{{Column: "id", Header: "id"}}}
+	job, err := jobs.Submit(req, "alice")
+	if err != nil {
+		t.Fatalf("创建任务失败: %v", err)
+	}
+
+	// 其他用户看不到、不能下载也不能取消alice的任务
+	if list := jobs.List("bob"); len(list) != 0 {
+		t.Errorf("bob的任务列表为%v，期望为空", list)
+	}
+	if _, ok := jobs.Get(job.ID, "bob"); ok {
+		t.Error("bob可以查看alice的任务")
+	}
+	if _, _, err := jobs.Open(job.ID, "bob"); !os.IsNotExist(err) {
+		t.Errorf("bob下载alice的任务返回%v，期望不存在", err)
+	}
+	if jobs.Cancel(job.ID, "bob") {
+		t.Error("bob可以取消alice的任务")
+	}
+
+	if list := jobs.List("alice"); len(list) != 1 || list[0].ID != job.ID || list[0].Owner != "alice" {
+		t.Errorf("alice的任务列表为%v，期望只有%s", list, job.ID)
+	}
+	if !jobs.Cancel(job.ID, "alice") {
+		t.Error("alice不能取消自己的任务")
+	}
+}
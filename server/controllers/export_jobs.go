@@ -0,0 +1,455 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"server/models"
+)
+
+// 导出任务状态
+const (
+	exportStatusQueued    = "queued"
+	exportStatusRunning   = "running"
+	exportStatusCompleted = "completed"
+	exportStatusFailed    = "failed"
+	exportStatusCancelled = "cancelled"
+)
+
+// errExportQueueFull 等待队列已满
+var errExportQueueFull = errors.New("导出任务队列已满")
+
+// exportJobConfig 导出任务配置
+type exportJobConfig struct {
+	Dir       string
+	Workers   int
+	QueueSize int
+	TTL       time.Duration
+}
+
+// defaultExportJobConfig 从环境变量读取导出任务配置
+// EXPORT_DIR(默认data/exports)、EXPORT_WORKERS(默认2)、EXPORT_QUEUE_SIZE(默认100)、EXPORT_TTL_MINUTES(默认60)
+func defaultExportJobConfig() exportJobConfig {
+	dir := os.Getenv("EXPORT_DIR")
+	if dir == "" {
+		dir = filepath.Join("data", "exports")
+	}
+	return exportJobConfig{
+		Dir:       dir,
+		Workers:   getEnvInt("EXPORT_WORKERS", 2),
+		QueueSize: getEnvInt("EXPORT_QUEUE_SIZE", 100),
+		TTL:       time.Duration(getEnvInt("EXPORT_TTL_MINUTES", 60)) * time.Minute,
+	}
+}
+
+// getEnvInt 读取整数环境变量，无效时返回默认值
+func getEnvInt(key string, defaultValue int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+// exportJob 一个异步导出任务
+type exportJob struct {
+	ID      string         `json:"id"`
+	Owner   string         `json:"owner"`
+	Request *exportRequest `json:"request"`
+	Status  string         `json:"status"`
+	Error   string         `json:"error,omitempty"`
+
+	RowsScanned int64 `json:"rows_scanned"`
+	RowsWritten int64 `json:"rows_written"`
+	FileSize    int64 `json:"file_size,omitempty"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+
+	path   string
+	cancel context.CancelFunc
+	stream *models.LogStream
+	out    *exportOutput
+}
+
+// finished 任务是否已结束
+func (j *exportJob) finished() bool {
+	return j.Status == exportStatusCompleted || j.Status == exportStatusFailed || j.Status == exportStatusCancelled
+}
+
+// exportJobManager 管理异步导出任务，固定数量的worker依次执行等待队列中的任务
+type exportJobManager struct {
+	config exportJobConfig
+	store  models.LogStore
+
+	mu   sync.Mutex
+	jobs map[string]*exportJob
+
+	queue  chan *exportJob
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// newExportJobManager 创建任务管理器并启动worker和过期清理
+// 任务只保存在内存中，启动时清理上次运行遗留的文件
+func newExportJobManager(config exportJobConfig, store models.LogStore) (*exportJobManager, error) {
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建导出目录失败: %w", err)
+	}
+	cleanStaleExports(config.Dir)
+
+	m := &exportJobManager{
+		config: config,
+		store:  store,
+		jobs:   make(map[string]*exportJob),
+		queue:  make(chan *exportJob, config.QueueSize),
+		stopCh: make(chan struct{}),
+	}
+
+	for i := 0; i < config.Workers; i++ {
+		m.wg.Add(1)
+		go m.worker()
+	}
+	m.wg.Add(1)
+	go m.janitor()
+
+	return m, nil
+}
+
+// Submit 为owner创建任务并加入等待队列
+func (m *exportJobManager) Submit(req *exportRequest, owner string) (*exportJob, error) {
+	id, err := newExportJobID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	job := &exportJob{
+		ID:        id,
+		Owner:     owner,
+		Request:   req,
+		Status:    exportStatusQueued,
+		CreatedAt: now,
+		ExpiresAt: now.Add(m.config.TTL),
+		path:      filepath.Join(m.config.Dir, id+"."+req.extension()),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	select {
+	case m.queue <- job:
+	default:
+		return nil, errExportQueueFull
+	}
+	m.jobs[id] = job
+
+	log.Printf("用户%s创建导出任务 %s，格式%s", owner, id, req.Format)
+	return m.snapshot(job), nil
+}
+
+// Get 获取owner的任务的当前状态，其他用户的任务视为不存在
+func (m *exportJobManager) Get(id, owner string) (*exportJob, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.lookup(id, owner)
+	if !ok {
+		return nil, false
+	}
+	return m.snapshot(job), true
+}
+
+// List 按创建时间倒序返回owner的任务
+func (m *exportJobManager) List(owner string) []*exportJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := []*exportJob{}
+	for _, job := range m.jobs {
+		if job.Owner == owner {
+			jobs = append(jobs, m.snapshot(job))
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+	})
+	return jobs
+}
+
+// Open 打开owner已完成任务的文件
+func (m *exportJobManager) Open(id, owner string) (*os.File, *exportJob, error) {
+	job, ok := m.Get(id, owner)
+	if !ok {
+		return nil, nil, os.ErrNotExist
+	}
+	if job.Status != exportStatusCompleted {
+		return nil, job, nil
+	}
+
+	f, err := os.Open(job.path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, job, nil
+}
+
+// Cancel 取消owner未结束的任务；已结束的任务直接删除任务和文件
+func (m *exportJobManager) Cancel(id, owner string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.lookup(id, owner)
+	if !ok {
+		return false
+	}
+
+	if job.finished() {
+		m.remove(job)
+		return true
+	}
+
+	// 排队中的任务由worker取出时跳过，运行中的任务通过context中断
+	job.Status = exportStatusCancelled
+	now := time.Now()
+	job.FinishedAt = &now
+	if job.cancel != nil {
+		job.cancel()
+	}
+	log.Printf("取消导出任务 %s", id)
+	return true
+}
+
+// Close 停止worker并中断运行中的任务
+func (m *exportJobManager) Close() {
+	close(m.stopCh)
+
+	m.mu.Lock()
+	for _, job := range m.jobs {
+		if job.cancel != nil {
+			job.cancel()
+		}
+	}
+	m.mu.Unlock()
+
+	m.wg.Wait()
+}
+
+// lookup 查找属于owner的任务，调用方需持有锁
+func (m *exportJobManager) lookup(id, owner string) (*exportJob, bool) {
+	job, ok := m.jobs[id]
+	if !ok || job.Owner != owner {
+		return nil, false
+	}
+	return job, true
+}
+
+// snapshot 复制任务的当前状态用于返回，调用方需持有锁
+func (m *exportJobManager) snapshot(job *exportJob) *exportJob {
+	copied := *job
+	if job.stream != nil {
+		copied.RowsScanned = job.stream.Scanned()
+	}
+	if job.out != nil {
+		copied.RowsWritten = job.out.Written()
+	}
+	copied.cancel = nil
+	copied.stream = nil
+	copied.out = nil
+	return &copied
+}
+
+// remove 删除任务和文件，调用方需持有锁
+func (m *exportJobManager) remove(job *exportJob) {
+	delete(m.jobs, job.ID)
+	if err := os.Remove(job.path); err != nil && !os.IsNotExist(err) {
+		log.Printf("删除导出文件失败: %v", err)
+	}
+	os.Remove(job.path + ".part")
+}
+
+func (m *exportJobManager) worker() {
+	defer m.wg.Done()
+	for {
+		select {
+		case job := <-m.queue:
+			m.run(job)
+		case <-m.stopCh:
+			return
+		}
+	}
+}
+
+// run 执行任务，先写入临时文件，成功后再重命名
+func (m *exportJobManager) run(job *exportJob) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m.mu.Lock()
+	if job.Status != exportStatusQueued {
+		// 排队期间已被取消
+		m.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	job.Status = exportStatusRunning
+	job.StartedAt = &now
+	job.cancel = cancel
+	m.mu.Unlock()
+
+	rows, size, err := m.export(ctx, job)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if job.stream != nil {
+		job.RowsScanned = job.stream.Scanned()
+	}
+	job.RowsWritten = rows
+	job.stream = nil
+	job.out = nil
+	job.cancel = nil
+
+	if job.Status == exportStatusCancelled {
+		os.Remove(job.path + ".part")
+		return
+	}
+
+	finished := time.Now()
+	job.FinishedAt = &finished
+	if err != nil {
+		job.Status = exportStatusFailed
+		job.Error = err.Error()
+		os.Remove(job.path + ".part")
+		log.Printf("导出任务 %s 失败，已写入%d行: %v", job.ID, rows, err)
+		return
+	}
+
+	if err := os.Rename(job.path+".part", job.path); err != nil {
+		job.Status = exportStatusFailed
+		job.Error = err.Error()
+		log.Printf("导出任务 %s 保存文件失败: %v", job.ID, err)
+		return
+	}
+
+	job.Status = exportStatusCompleted
+	job.FileSize = size
+	// 过期时间从完成时开始计算，保证下载有完整的有效期
+	job.ExpiresAt = finished.Add(m.config.TTL)
+	log.Printf("导出任务 %s 完成，共%d行，%d字节", job.ID, rows, size)
+}
+
+// export 将数据写入临时文件，返回写入的行数和文件大小
+func (m *exportJobManager) export(ctx context.Context, job *exportJob) (int64, int64, error) {
+	req := job.Request
+
+	stream, err := models.OpenLogStream(ctx, m.store, req.Options, req.Columns)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer stream.Close()
+
+	f, err := os.Create(job.path + ".part")
+	if err != nil {
+		return 0, 0, fmt.Errorf("创建导出文件失败: %w", err)
+	}
+	defer f.Close()
+
+	out := newExportOutput(f, req.Gzip)
+
+	m.mu.Lock()
+	job.stream = stream
+	job.out = out
+	m.mu.Unlock()
+
+	rows, err := req.format().Write(out, stream, req.Columns)
+	if err == nil {
+		err = out.Close()
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return rows, 0, err
+	}
+
+	if err := f.Sync(); err != nil {
+		return rows, 0, fmt.Errorf("写入导出文件失败: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return rows, 0, err
+	}
+	return rows, info.Size(), nil
+}
+
+// janitor 定期删除过期的任务和文件
+func (m *exportJobManager) janitor() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			m.mu.Lock()
+			for _, job := range m.jobs {
+				if job.finished() && now.After(job.ExpiresAt) {
+					log.Printf("导出任务 %s 已过期，删除文件", job.ID)
+					m.remove(job)
+				}
+			}
+			m.mu.Unlock()
+		case <-m.stopCh:
+			return
+		}
+	}
+}
+
+// cleanStaleExports 删除导出目录中上次运行遗留的任务文件，只删除以任务ID命名的文件
+func cleanStaleExports(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Printf("读取导出目录失败: %v", err)
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || len(name) <= exportJobIDLength || name[exportJobIDLength] != '.' {
+			continue
+		}
+		if _, err := hex.DecodeString(name[:exportJobIDLength]); err != nil {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			log.Printf("删除遗留的导出文件失败: %v", err)
+		}
+	}
+}
+
+// exportJobIDLength 任务ID的长度（16字节的十六进制）
+const exportJobIDLength = 32
+
+// newExportJobID 生成随机任务ID，下载链接凭ID访问，需不可猜测
+func newExportJobID() (string, error) {
+	b := make([]byte, exportJobIDLength/2)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("生成任务ID失败: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
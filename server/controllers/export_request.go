@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"server/database"
+	"server/models"
+	"server/utils"
+)
+
+// exportRequest 导出参数，同步导出和异步导出任务共用
+type exportRequest struct {
+	Format  string                `json:"format"`
+	Gzip    bool                  `json:"gzip"`
+	Columns []models.ExportColumn `json:"columns"`
+	Options database.QueryOptions `json:"filter"`
+}
+
+// format 返回导出格式
+func (req *exportRequest) format() exportFormat {
+	return exportFormats[req.Format]
+}
+
+// extension 返回导出文件的扩展名
+func (req *exportRequest) extension() string {
+	ext := req.format().Extension
+	if req.Gzip {
+		ext += ".gz"
+	}
+	return ext
+}
+
+// contentType 返回导出文件的Content-Type
+func (req *exportRequest) contentType() string {
+	if req.Gzip {
+		return "application/gzip"
+	}
+	return req.format().ContentType
+}
+
+// parseExportRequest 解析导出参数，筛选参数与QueryLogs相同，无效时写入400响应并返回false
+// format为csv(默认)、ndjson、parquet或xlsx；columns(逗号分隔)可使用别名和映射的语义名；
+// limit限制最多导出的行数；gzip=true时压缩输出(仅csv和ndjson)
+func parseExportRequest(w http.ResponseWriter, r *http.Request) (*exportRequest, bool) {
+	req := &exportRequest{
+		Format: r.URL.Query().Get("format"),
+		Gzip:   r.URL.Query().Get("gzip") == "true",
+	}
+	if req.Format == "" {
+		req.Format = "csv"
+	}
+	format, ok := exportFormats[req.Format]
+	if !ok {
+		utils.RespondWithError(w, http.StatusBadRequest, "不支持的导出格式: "+req.Format)
+		return nil, false
+	}
+	if req.Gzip && !format.Compressible {
+		utils.RespondWithError(w, http.StatusBadRequest, req.Format+"格式已压缩，不支持gzip参数")
+		return nil, false
+	}
+
+	// 解析查询参数，导出不分页
+	options, ok := parseLogsQueryParams(w, r)
+	if !ok || !validateLogQuery(w, options) {
+		return nil, false
+	}
+	options.Offset = 0
+	options.Limit = 0
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 0 {
+			utils.RespondWithError(w, http.StatusBadRequest, "limit参数无效")
+			return nil, false
+		}
+		options.Limit = limit
+	}
+	req.Options = options
+
+	var names []string
+	if columnsStr := r.URL.Query().Get("columns"); columnsStr != "" {
+		for _, name := range strings.Split(columnsStr, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	columns, err := models.ResolveExportColumns(names, options.AppID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	req.Columns = columns
+
+	return req, true
+}
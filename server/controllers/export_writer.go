@@ -0,0 +1,396 @@
+package controllers
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/xuri/excelize/v2"
+
+	"server/models"
+)
+
+// 导出时的刷新策略，达到任一条件即把缓冲的数据发送给客户端
+const (
+	exportFlushRows     = 5000
+	exportFlushInterval = time.Second
+)
+
+// parquetRowGroupSize 每个Parquet行组的行数，行组写出后释放内存
+const parquetRowGroupSize = 100000
+
+// xlsxMaxDataRows 每个工作表最多的数据行数（Excel上限1048576行，减去表头）
+const xlsxMaxDataRows = excelize.TotalRows - 1
+
+// exportFormat 导出格式
+type exportFormat struct {
+	ContentType string
+	Extension   string
+	// Compressible 是否支持gzip，parquet和xlsx本身已压缩
+	Compressible bool
+	Write        func(out *exportOutput, stream *models.LogStream, columns []models.ExportColumn) (int64, error)
+}
+
+// exportFormats 支持的导出格式
+var exportFormats = map[string]exportFormat{
+	"csv":     {ContentType: "text/csv; charset=utf-8", Extension: "csv", Compressible: true, Write: writeCSVExport},
+	"ndjson":  {ContentType: "application/x-ndjson", Extension: "ndjson", Compressible: true, Write: writeNDJSONExport},
+	"parquet": {ContentType: "application/vnd.apache.parquet", Extension: "parquet", Write: writeParquetExport},
+	"xlsx":    {ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", Extension: "xlsx", Write: writeXLSXExport},
+}
+
+// exportOutput 导出的输出目标，可选gzip压缩，Flush时把缓冲的数据推送给客户端
+type exportOutput struct {
+	io.Writer
+	gz        *gzip.Writer
+	flusher   http.Flusher
+	lastFlush time.Time
+	written   atomic.Int64
+}
+
+// rowWritten 记录一行已写出，用于导出任务的进度
+func (o *exportOutput) rowWritten() {
+	o.written.Add(1)
+}
+
+// Written 返回已写出的行数
+func (o *exportOutput) Written() int64 {
+	return o.written.Load()
+}
+
+// newExportOutput 创建输出目标，w实现http.Flusher时支持分段推送
+func newExportOutput(w io.Writer, useGzip bool) *exportOutput {
+	out := &exportOutput{Writer: w, lastFlush: time.Now()}
+	out.flusher, _ = w.(http.Flusher)
+	if useGzip {
+		out.gz = gzip.NewWriter(w)
+		out.Writer = out.gz
+	}
+	return out
+}
+
+// Flush 刷新gzip缓冲并推送给客户端
+func (o *exportOutput) Flush() error {
+	if o.gz != nil {
+		if err := o.gz.Flush(); err != nil {
+			return err
+		}
+	}
+	if o.flusher != nil {
+		o.flusher.Flush()
+	}
+	o.lastFlush = time.Now()
+	return nil
+}
+
+// shouldFlush 每exportFlushRows行或每exportFlushInterval刷新一次
+func (o *exportOutput) shouldFlush(rows int64) bool {
+	return rows%exportFlushRows == 0 || time.Since(o.lastFlush) >= exportFlushInterval
+}
+
+// Close 写出gzip结尾
+func (o *exportOutput) Close() error {
+	if o.gz != nil {
+		return o.gz.Close()
+	}
+	return nil
+}
+
+// writeCSVExport 将日志流写为CSV，返回写入的数据行数
+func writeCSVExport(out *exportOutput, stream *models.LogStream, columns []models.ExportColumn) (int64, error) {
+	cw := csv.NewWriter(out)
+	flush := func() error {
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+		return out.Flush()
+	}
+
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.Header
+	}
+	if err := cw.Write(header); err != nil {
+		return 0, err
+	}
+
+	var rows int64
+	record := make([]string, len(columns))
+	var values []interface{}
+	for stream.Next() {
+		values = stream.Values(values)
+		for i, v := range values {
+			record[i] = formatCSVValue(v)
+		}
+		if err := cw.Write(record); err != nil {
+			return rows, err
+		}
+		rows++
+		out.rowWritten()
+
+		if out.shouldFlush(rows) {
+			if err := flush(); err != nil {
+				return rows, err
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		flush()
+		return rows, err
+	}
+	return rows, flush()
+}
+
+// writeNDJSONExport 将日志流写为NDJSON，每行一个对象，键的顺序与columns一致
+func writeNDJSONExport(out *exportOutput, stream *models.LogStream, columns []models.ExportColumn) (int64, error) {
+	keys := make([][]byte, len(columns))
+	for i, c := range columns {
+		key, err := json.Marshal(c.Header)
+		if err != nil {
+			return 0, err
+		}
+		keys[i] = key
+	}
+
+	var rows int64
+	var values []interface{}
+	var line []byte
+	for stream.Next() {
+		values = stream.Values(values)
+		line = append(line[:0], '{')
+		for i, v := range values {
+			if i > 0 {
+				line = append(line, ',')
+			}
+			value, err := json.Marshal(v)
+			if err != nil {
+				return rows, err
+			}
+			line = append(line, keys[i]...)
+			line = append(line, ':')
+			line = append(line, value...)
+		}
+		line = append(line, '}', '\n')
+		if _, err := out.Write(line); err != nil {
+			return rows, err
+		}
+		rows++
+		out.rowWritten()
+
+		if out.shouldFlush(rows) {
+			if err := out.Flush(); err != nil {
+				return rows, err
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		out.Flush()
+		return rows, err
+	}
+	return rows, out.Flush()
+}
+
+// parquetNode 按kv_7的列类型生成Parquet列类型
+func parquetNode(columnType string) parquet.Node {
+	switch columnType {
+	case "DateTime":
+		return parquet.Timestamp(parquet.Millisecond)
+	case "Int64":
+		return parquet.Int(64)
+	case "Int32":
+		return parquet.Int(32)
+	}
+	return parquet.String()
+}
+
+// parquetValue 将列值转换为Parquet值
+func parquetValue(v interface{}) parquet.Value {
+	switch value := v.(type) {
+	case time.Time:
+		return parquet.Int64Value(value.UnixMilli())
+	case int64:
+		return parquet.Int64Value(value)
+	case int32:
+		return parquet.Int32Value(value)
+	case string:
+		return parquet.ByteArrayValue([]byte(value))
+	}
+	return parquet.ByteArrayValue([]byte(fmt.Sprint(v)))
+}
+
+// writeParquetExport 将日志流写为Parquet，列类型与kv_7一致，每parquetRowGroupSize行写出一个行组
+func writeParquetExport(out *exportOutput, stream *models.LogStream, columns []models.ExportColumn) (int64, error) {
+	group := make(parquet.Group, len(columns))
+	for _, c := range columns {
+		group[c.Header] = parquetNode(c.Type)
+	}
+	schema := parquet.NewSchema("kv_7", group)
+
+	// Parquet按列名排序存储，记录每个导出列在schema中的位置
+	leafIndex := make(map[string]int, len(columns))
+	for i, path := range schema.Columns() {
+		leafIndex[path[0]] = i
+	}
+	positions := make([]int, len(columns))
+	for i, c := range columns {
+		positions[i] = leafIndex[c.Header]
+	}
+
+	writer := parquet.NewWriter(out, schema, parquet.Compression(&parquet.Zstd))
+
+	var rows int64
+	var values []interface{}
+	row := make(parquet.Row, len(columns))
+	for stream.Next() {
+		values = stream.Values(values)
+		for i, v := range values {
+			row[positions[i]] = parquetValue(v).Level(0, 0, positions[i])
+		}
+		if _, err := writer.WriteRows([]parquet.Row{row}); err != nil {
+			return rows, err
+		}
+		rows++
+		out.rowWritten()
+
+		if rows%parquetRowGroupSize == 0 {
+			if err := writer.Flush(); err != nil {
+				return rows, err
+			}
+			if err := out.Flush(); err != nil {
+				return rows, err
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return rows, err
+	}
+	if err := writer.Close(); err != nil {
+		return rows, err
+	}
+	return rows, out.Flush()
+}
+
+// writeXLSXExport 将日志流写为Excel，超过单个工作表的行数上限时拆分到新的工作表
+// xlsx为zip格式，行数据先由excelize的流式写入器缓存到临时文件，最后一次性输出
+func writeXLSXExport(out *exportOutput, stream *models.LogStream, columns []models.ExportColumn) (int64, error) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	header := make([]interface{}, len(columns))
+	for i, c := range columns {
+		header[i] = c.Header
+	}
+
+	var sw *excelize.StreamWriter
+	sheetIndex := 0
+	sheetRows := 0
+	newSheet := func() error {
+		if sw != nil {
+			if err := sw.Flush(); err != nil {
+				return err
+			}
+		}
+		sheetIndex++
+		name := fmt.Sprintf("logs_%d", sheetIndex)
+		if sheetIndex == 1 {
+			// 重命名默认工作表
+			if err := f.SetSheetName("Sheet1", name); err != nil {
+				return err
+			}
+		} else if _, err := f.NewSheet(name); err != nil {
+			return err
+		}
+
+		var err error
+		sw, err = f.NewStreamWriter(name)
+		if err != nil {
+			return err
+		}
+		sheetRows = 0
+		return sw.SetRow("A1", header)
+	}
+	if err := newSheet(); err != nil {
+		return 0, err
+	}
+
+	var rows int64
+	var values []interface{}
+	record := make([]interface{}, len(columns))
+	for stream.Next() {
+		if sheetRows == xlsxMaxDataRows {
+			if err := newSheet(); err != nil {
+				return rows, err
+			}
+		}
+
+		values = stream.Values(values)
+		for i, v := range values {
+			if t, ok := v.(time.Time); ok {
+				record[i] = t.Format("2006-01-02 15:04:05")
+			} else if s, ok := v.(string); ok {
+				record[i] = sanitizeCSVCell(s)
+			} else {
+				record[i] = v
+			}
+		}
+
+		cell, err := excelize.CoordinatesToCellName(1, sheetRows+2)
+		if err != nil {
+			return rows, err
+		}
+		if err := sw.SetRow(cell, record); err != nil {
+			return rows, err
+		}
+		sheetRows++
+		rows++
+		out.rowWritten()
+	}
+	if err := stream.Err(); err != nil {
+		return rows, err
+	}
+
+	if err := sw.Flush(); err != nil {
+		return rows, err
+	}
+	if err := f.Write(out); err != nil {
+		return rows, err
+	}
+	return rows, out.Flush()
+}
+
+// formatCSVValue 将列值格式化为CSV单元格
+func formatCSVValue(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return sanitizeCSVCell(value)
+	case time.Time:
+		return value.Format("2006-01-02 15:04:05")
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case int32:
+		return strconv.FormatInt(int64(value), 10)
+	case nil:
+		return ""
+	}
+	return sanitizeCSVCell(fmt.Sprint(v))
+}
+
+// sanitizeCSVCell 防止CSV注入：以公式字符开头的内容前加单引号，避免被表格软件当作公式执行
+func sanitizeCSVCell(s string) string {
+	if s == "" {
+		return s
+	}
+	switch s[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"server/models"
+	"server/router"
+	"server/utils"
+)
+
+// FieldMappingController 管理各应用匿名列(d/v/info/ud/sd等)的语义映射
+type FieldMappingController struct{}
+
+// NewFieldMappingController 创建一个新的字段映射控制器
+func NewFieldMappingController() *FieldMappingController {
+	return &FieldMappingController{}
+}
+
+// fieldMappingRequest 创建或更新映射的请求体
+type fieldMappingRequest struct {
+	AppID string `json:"app_id"`
+	models.FieldMapping
+}
+
+// ListFieldMappings 获取映射列表
+// 指定app_id时返回该应用自身的映射和合并默认映射后实际生效的映射
+func (c *FieldMappingController) ListFieldMappings(w http.ResponseWriter, r *http.Request) {
+	registry := models.FieldMappings()
+
+	appID := r.URL.Query().Get("app_id")
+	if appID == "" {
+		all := make(map[string][]models.FieldMapping)
+		for _, id := range registry.Apps() {
+			all[id] = registry.List(id)
+		}
+		utils.RespondWithJSON(w, http.StatusOK, all)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"app_id":    appID,
+		"fields":    registry.List(appID),
+		"effective": registry.Effective(appID),
+	})
+}
+
+// GetFieldMapping 获取单个映射
+func (c *FieldMappingController) GetFieldMapping(w http.ResponseWriter, r *http.Request) {
+	appID, column := router.Param(r, "app_id"), router.Param(r, "column")
+	mapping, ok := models.FieldMappings().Get(appID, column)
+	if !ok {
+		utils.RespondWithError(w, http.StatusNotFound, "字段映射不存在")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, mapping)
+}
+
+// CreateFieldMapping 创建映射，请求体需包含app_id和column
+func (c *FieldMappingController) CreateFieldMapping(w http.ResponseWriter, r *http.Request) {
+	var req fieldMappingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "无效的请求数据")
+		return
+	}
+
+	if _, exists := models.FieldMappings().Get(req.AppID, req.Column); exists {
+		utils.RespondWithError(w, http.StatusConflict, "该列已存在映射，请使用PUT更新")
+		return
+	}
+
+	if !c.putFieldMapping(w, req.AppID, req.FieldMapping) {
+		return
+	}
+
+	log.Printf("创建了字段映射: app_id=%s, %s -> %s", req.AppID, req.Column, req.Name)
+	utils.RespondWithJSON(w, http.StatusCreated, req.FieldMapping)
+}
+
+// UpdateFieldMapping 新增或更新指定列的映射
+func (c *FieldMappingController) UpdateFieldMapping(w http.ResponseWriter, r *http.Request) {
+	appID, column := router.Param(r, "app_id"), router.Param(r, "column")
+	var mapping models.FieldMapping
+	if err := json.NewDecoder(r.Body).Decode(&mapping); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "无效的请求数据")
+		return
+	}
+	mapping.Column = column
+
+	if !c.putFieldMapping(w, appID, mapping) {
+		return
+	}
+
+	log.Printf("更新了字段映射: app_id=%s, %s -> %s", appID, column, mapping.Name)
+	utils.RespondWithJSON(w, http.StatusOK, mapping)
+}
+
+// DeleteFieldMapping 删除映射
+func (c *FieldMappingController) DeleteFieldMapping(w http.ResponseWriter, r *http.Request) {
+	appID, column := router.Param(r, "app_id"), router.Param(r, "column")
+	deleted, err := models.FieldMappings().Delete(appID, column)
+	if err != nil {
+		log.Printf("删除字段映射失败: %v", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "删除字段映射失败")
+		return
+	}
+	if !deleted {
+		utils.RespondWithError(w, http.StatusNotFound, "字段映射不存在")
+		return
+	}
+
+	log.Printf("删除了字段映射: app_id=%s, column=%s", appID, column)
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "字段映射已删除"})
+}
+
+// putFieldMapping 保存映射，校验失败返回400，持久化失败返回500
+func (c *FieldMappingController) putFieldMapping(w http.ResponseWriter, appID string, mapping models.FieldMapping) bool {
+	err := models.FieldMappings().Put(appID, mapping)
+	if err == nil {
+		return true
+	}
+
+	var mappingErr *models.FieldMappingError
+	if errors.As(err, &mappingErr) {
+		utils.RespondWithErrorCode(w, http.StatusBadRequest, utils.CodeInvalidParams, "字段映射无效", []router.InvalidParam{
+			{In: router.InBody, Field: mappingErr.Field, Reason: mappingErr.Reason},
+		})
+		return false
+	}
+
+	log.Printf("保存字段映射失败: %v", err)
+	utils.RespondWithError(w, http.StatusInternalServerError, "保存字段映射失败")
+	return false
+}
@@ -0,0 +1,310 @@
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"server/models"
+	"server/utils"
+)
+
+// maxIngestBodySize 单次写入请求体的最大字节数
+const maxIngestBodySize = 32 << 20
+
+// NDJSON流式写入的批次与上报限制
+const (
+	ndjsonBatchSize       = 5000
+	ndjsonFlushInterval   = 2 * time.Second
+	ndjsonMaxLineSize     = 1 << 20
+	ndjsonMaxReportErrors = 1000
+)
+
+// IngestController 处理日志写入相关请求
+type IngestController struct {
+	store models.LogStore
+}
+
+// NewIngestController 创建一个新的写入控制器，同步写入的记录写入store
+func NewIngestController(store models.LogStore) *IngestController {
+	return &IngestController{store: store}
+}
+
+// recordError 描述批次中某条记录的错误
+type recordError struct {
+	Index  int    `json:"index"`
+	Field  string `json:"field,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// lineError 描述NDJSON中某一行的错误，行号从1开始
+type lineError struct {
+	Line   int    `json:"line"`
+	Field  string `json:"field,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// ndjsonLine 表示读取到的一行记录
+type ndjsonLine struct {
+	Line   int
+	Record models.KV7Record
+	Err    *lineError
+}
+
+// ingestReport 汇总NDJSON写入结果
+type ingestReport struct {
+	Accepted  int         `json:"accepted"`
+	Rejected  int         `json:"rejected"`
+	Batches   int         `json:"batches"`
+	Errors    []lineError `json:"errors"`
+	Truncated bool        `json:"truncated"`
+}
+
+// reject 记录被拒绝的行，超出上报上限时只计数
+func (rep *ingestReport) reject(e lineError) {
+	rep.Rejected++
+	if len(rep.Errors) >= ndjsonMaxReportErrors {
+		rep.Truncated = true
+		return
+	}
+	rep.Errors = append(rep.Errors, e)
+}
+
+// IngestLogs 批量写入日志记录到kv_7表
+func (c *IngestController) IngestLogs(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIngestBodySize))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusRequestEntityTooLarge, "请求体过大或读取失败")
+		return
+	}
+
+	// 同时支持 [...] 和 {"records": [...]} 两种格式
+	var records []models.KV7Record
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &records)
+	} else {
+		var payload struct {
+			Records []models.KV7Record `json:"records"`
+		}
+		err = json.Unmarshal(trimmed, &payload)
+		records = payload.Records
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("无效的请求数据: %v", err))
+		return
+	}
+
+	if len(records) == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "记录不能为空")
+		return
+	}
+
+	if len(records) > models.MaxIngestBatchSize {
+		utils.RespondWithError(w, http.StatusBadRequest,
+			fmt.Sprintf("单次最多写入%d条记录", models.MaxIngestBatchSize))
+		return
+	}
+
+	// 校验全部记录，存在无效记录时整批拒绝
+	now := time.Now()
+	var errors []recordError
+	for i := range records {
+		if verr := models.ValidateKV7Record(&records[i], now); verr != nil {
+			errors = append(errors, recordError{Index: i, Field: verr.Field, Reason: verr.Reason})
+			continue
+		}
+		models.PrepareKV7Record(&records[i], now)
+	}
+
+	if len(errors) > 0 {
+		utils.RespondWithErrorCode(w, http.StatusBadRequest, utils.CodeInvalidParams, "存在无效的记录", errors)
+		return
+	}
+
+	// mode=async时交给带落盘缓冲的写入队列，ClickHouse不可用时记录不会丢失
+	if r.URL.Query().Get("mode") == "async" {
+		if err := models.EnqueueKV7Records(records); err != nil {
+			// 服务器停止过程中队列已关闭(ErrQueueClosed)，客户端应稍后重试
+			log.Printf("日志加入写入队列失败: %v", err)
+			utils.RespondWithError(w, http.StatusServiceUnavailable, fmt.Sprintf("写入队列不可用: %v", err))
+			return
+		}
+
+		utils.RespondWithJSON(w, http.StatusAccepted, map[string]interface{}{
+			"queued": len(records),
+		})
+		return
+	}
+
+	// 同步写入随请求取消，并在响应meta中带上query_id；异步写入由队列在请求结束后完成，不受影响
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	if err := c.store.Insert(ctx, records); err != nil {
+		respondDataError(w, "写入日志失败", err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"inserted": len(records),
+	})
+}
+
+// IngestNDJSON 以NDJSON格式流式写入日志记录
+// 请求体逐行解析，不会整体读入内存；记录按数量或时间分批写入ClickHouse，
+// 写入期间暂停读取请求体，从而对客户端形成背压
+func (c *IngestController) IngestNDJSON(w http.ResponseWriter, r *http.Request) {
+	done := make(chan struct{})
+	defer close(done)
+
+	// 通道容量即为内存中最多缓冲的记录数
+	lines := make(chan ndjsonLine, ndjsonBatchSize)
+	readErr := make(chan error, 1)
+	go readNDJSON(r.Body, lines, readErr, done)
+
+	report := &ingestReport{Errors: []lineError{}}
+	batch := make([]models.KV7Record, 0, ndjsonBatchSize)
+	batchLines := make([]int, 0, ndjsonBatchSize)
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		err := c.store.Insert(ctx, batch)
+		cancel()
+
+		report.Batches++
+		if err != nil {
+			log.Printf("NDJSON批次写入失败: %v", err)
+			for _, line := range batchLines {
+				report.reject(lineError{Line: line, Reason: fmt.Sprintf("批次写入失败: %v", err)})
+			}
+		} else {
+			report.Accepted += len(batch)
+		}
+
+		batch = batch[:0]
+		batchLines = batchLines[:0]
+	}
+
+	ticker := time.NewTicker(ndjsonFlushInterval)
+	defer ticker.Stop()
+
+	for lines != nil {
+		select {
+		case item, ok := <-lines:
+			if !ok {
+				lines = nil
+				break
+			}
+
+			if item.Err != nil {
+				report.reject(*item.Err)
+				continue
+			}
+
+			batch = append(batch, item.Record)
+			batchLines = append(batchLines, item.Line)
+			if len(batch) >= ndjsonBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+	flush()
+
+	// 读取请求体出错时，已写入的批次保留，报告与错误一起返回
+	status := http.StatusOK
+	response := utils.Response{Data: report}
+	if err := <-readErr; err != nil {
+		log.Printf("读取NDJSON请求体失败: %v", err)
+		status = http.StatusBadRequest
+		response.Error = utils.NewError(w, utils.CodeBadRequest, fmt.Sprintf("读取请求体失败: %v", err), nil)
+	}
+
+	log.Printf("NDJSON写入完成: 接受%d条, 拒绝%d条, 批次%d", report.Accepted, report.Rejected, report.Batches)
+	utils.Respond(w, status, response)
+}
+
+// readNDJSON 逐行解析请求体并发送到lines通道，结束时关闭通道
+// 超过ndjsonMaxLineSize的行丢弃其余内容并作为该行的错误上报，不影响后续行
+func readNDJSON(body io.Reader, lines chan<- ndjsonLine, readErr chan<- error, done <-chan struct{}) {
+	defer close(lines)
+
+	reader := bufio.NewReaderSize(body, 64*1024)
+	var buf []byte
+
+	lineNo := 0
+	for {
+		raw, tooLong, err := readNDJSONLine(reader, buf)
+		buf = raw[:0]
+		if err != nil && err != io.EOF {
+			readErr <- fmt.Errorf("第%d行之后: %w", lineNo, err)
+			return
+		}
+		eof := err == io.EOF
+		if eof && len(raw) == 0 && !tooLong {
+			readErr <- nil
+			return
+		}
+		lineNo++
+
+		item := ndjsonLine{Line: lineNo}
+		if tooLong {
+			item.Err = &lineError{Line: lineNo, Reason: fmt.Sprintf("行长度超过%d字节", ndjsonMaxLineSize)}
+		} else if raw = bytes.TrimSpace(raw); len(raw) == 0 {
+			continue
+		} else {
+			now := time.Now()
+			if err := json.Unmarshal(raw, &item.Record); err != nil {
+				item.Err = &lineError{Line: lineNo, Reason: fmt.Sprintf("JSON解析失败: %v", err)}
+			} else if verr := models.ValidateKV7Record(&item.Record, now); verr != nil {
+				item.Err = &lineError{Line: lineNo, Field: verr.Field, Reason: verr.Reason}
+			} else {
+				models.PrepareKV7Record(&item.Record, now)
+			}
+		}
+
+		select {
+		case lines <- item:
+		case <-done:
+			readErr <- nil
+			return
+		}
+		if eof {
+			readErr <- nil
+			return
+		}
+	}
+}
+
+// readNDJSONLine 读取一行（不含结尾的换行符），buf用于复用内存
+// 行超过ndjsonMaxLineSize时继续读到行尾但不保存内容，tooLong为true；读到结尾时err为io.EOF
+func readNDJSONLine(reader *bufio.Reader, buf []byte) (line []byte, tooLong bool, err error) {
+	line = buf[:0]
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		chunk = bytes.TrimSuffix(chunk, []byte("\n"))
+		if !tooLong {
+			if len(line)+len(chunk) > ndjsonMaxLineSize {
+				tooLong, line = true, line[:0]
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		return line, tooLong, err
+	}
+}
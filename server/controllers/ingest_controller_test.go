@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"server/database"
+	"server/models"
+)
+
+// collectNDJSON 读取全部行，返回接受的记录ID、各行错误和读取错误
+func collectNDJSON(body string) ([]string, map[int]string, error) {
+	lines := make(chan ndjsonLine)
+	readErr := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go readNDJSON(strings.NewReader(body), lines, readErr, done)
+
+	var ids []string
+	errs := make(map[int]string)
+	for item := range lines {
+		if item.Err != nil {
+			errs[item.Line] = item.Err.Reason
+			continue
+		}
+		ids = append(ids, item.Record.ID)
+	}
+	return ids, errs, <-readErr
+}
+
+func ndjsonRecord(id string, extra string) string {
+	return fmt.Sprintf(`{"id":%q,"data_time":"2024-01-01T00:00:00Z"%s}`, id, extra)
+}
+
+func TestReadNDJSONSkipsOversizedLine(t *testing.T) {
+	huge := ndjsonRecord("huge", fmt.Sprintf(`,"d1":%q`, strings.Repeat("x", ndjsonMaxLineSize)))
+	body := strings.Join([]string{
+		ndjsonRecord("a", ""),
+		huge,
+		"",
+		"{bad",
+		ndjsonRecord("b", ""),
+		ndjsonRecord("c", ""), // 最后一行没有换行符
+	}, "\n")
+
+	ids, errs, err := collectNDJSON(body)
+	if err != nil {
+		t.Fatalf("读取失败: %v", err)
+	}
+	if got := strings.Join(ids, ","); got != "a,b,c" {
+		t.Errorf("接受的记录为%s，期望a,b,c", got)
+	}
+	if !strings.Contains(errs[2], "行长度超过") {
+		t.Errorf("第2行的错误为%q，期望行长度超限", errs[2])
+	}
+	if !strings.Contains(errs[4], "JSON解析失败") {
+		t.Errorf("第4行的错误为%q，期望JSON解析失败", errs[4])
+	}
+	if len(errs) != 2 {
+		t.Errorf("共%d行出错(%v)，期望2行", len(errs), errs)
+	}
+}
+
+func TestReadNDJSONLineAtLimit(t *testing.T) {
+	// 恰好ndjsonMaxLineSize字节的行可以读取，多一个字节即超限
+	pad := ndjsonMaxLineSize - len(ndjsonRecord("lim1", `,"d1":""`))
+	atLimit := ndjsonRecord("lim1", fmt.Sprintf(`,"d1":%q`, strings.Repeat("x", pad)))
+	overLimit := ndjsonRecord("lim2", fmt.Sprintf(`,"d1":%q`, strings.Repeat("x", pad+1)))
+
+	ids, errs, err := collectNDJSON(atLimit + "\n" + overLimit + "\n")
+	if err != nil {
+		t.Fatalf("读取失败: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != "lim1" {
+		t.Errorf("接受的记录为%v，期望[lim1]", ids)
+	}
+	if _, ok := errs[2]; !ok {
+		t.Errorf("第2行应当超限，实际错误为%v", errs)
+	}
+}
+
+// storedIDs 返回存储中全部记录的ID，按ID升序
+func storedIDs(t *testing.T, store models.LogStore) string {
+	t.Helper()
+	records, err := store.Query(context.Background(), database.QueryOptions{SortBy: "id", SortOrder: "asc"}, nil)
+	if err != nil {
+		t.Fatalf("查询存储失败: %v", err)
+	}
+	ids := make([]string, len(records))
+	for i, r := range records {
+		ids[i] = r.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestIngestLogs(t *testing.T) {
+	store := models.NewMemoryStore(nil)
+	c := NewIngestController(store)
+	if err := models.StartIngestQueue(store); err != nil {
+		t.Fatalf("启动写入队列失败: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		body   string
+		status int
+		stored string
+	}{
+		{"同步写入数组", "/api/ingest", "[" + ndjsonRecord("a", "") + "]", http.StatusOK, "a"},
+		{"同步写入records", "/api/ingest", `{"records":[` + ndjsonRecord("b", "") + "]}", http.StatusOK, "a,b"},
+		{"异步写入", "/api/ingest?mode=async", "[" + ndjsonRecord("c", "") + "]", http.StatusAccepted, "a,b,c"},
+		{"缺少ID整批拒绝", "/api/ingest", "[" + ndjsonRecord("d", "") + `,{"data_time":"2024-01-01T00:00:00Z"}]`, http.StatusBadRequest, "a,b,c"},
+		{"空记录", "/api/ingest", "[]", http.StatusBadRequest, "a,b,c"},
+		{"无效JSON", "/api/ingest", "{bad", http.StatusBadRequest, "a,b,c"},
+	}
+	for _, tt := range tests {
+		status, resp := serve(t, c.IngestLogs, httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body)))
+		if status != tt.status {
+			t.Errorf("%s: 状态码为%d，期望%d，错误%+v", tt.name, status, tt.status, resp.Error)
+		}
+		if got := storedIDs(t, store); got != tt.stored {
+			t.Errorf("%s: 存储中的记录为%s，期望%s", tt.name, got, tt.stored)
+		}
+	}
+}
+
+func TestIngestNDJSON(t *testing.T) {
+	store := models.NewMemoryStore(nil)
+	c := NewIngestController(store)
+
+	body := strings.Join([]string{
+		ndjsonRecord("a", ""),
+		`{"data_time":"2024-01-01T00:00:00Z"}`,
+		"{bad",
+		ndjsonRecord("b", ""),
+	}, "\n")
+	status, resp := serve(t, c.IngestNDJSON, httptest.NewRequest(http.MethodPost, "/api/ingest/ndjson", strings.NewReader(body)))
+	if status != http.StatusOK {
+		t.Fatalf("状态码为%d，错误%+v", status, resp.Error)
+	}
+
+	var report ingestReport
+	if err := json.Unmarshal(resp.Data, &report); err != nil {
+		t.Fatalf("解析报告失败: %v", err)
+	}
+	if report.Accepted != 2 || report.Rejected != 2 {
+		t.Errorf("接受%d条、拒绝%d条，期望各2条", report.Accepted, report.Rejected)
+	}
+	if len(report.Errors) != 2 || report.Errors[0].Line != 2 || report.Errors[0].Field != "id" || report.Errors[1].Line != 3 {
+		t.Errorf("各行错误为%+v，期望第2行缺少id、第3行JSON无效", report.Errors)
+	}
+
+	if got := storedIDs(t, store); got != "a,b" {
+		t.Errorf("存储中的记录为%s，期望a,b", got)
+	}
+}
@@ -1,6 +1,10 @@
 package controllers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
@@ -9,255 +13,378 @@ import (
 
 	"server/database"
 	"server/models"
+	"server/querylang"
+	"server/router"
 	"server/utils"
 )
 
 // LogsController 处理日志查询相关请求
-type LogsController struct{}
-
-// 创建一个全局的LogsController实例
-var logsController = NewLogsController()
-
-// 暴露出的处理函数，用于路由绑定
-var (
-	QueryLogs    = logsController.QueryLogs
-	GetLogDetail = logsController.GetLogDetail
-	GetLogFields = logsController.GetLogFields
-	GetProjects  = logsController.GetProjects
-	GetLogTypes  = logsController.GetLogTypes
-	ExportLogs   = logsController.ExportLogs
+type LogsController struct {
+	store models.LogStore
+}
+
+// 实时追踪的轮询与心跳配置
+const (
+	tailDefaultInterval = 2 * time.Second
+	tailMinInterval     = time.Second
+	tailHeartbeat       = 15 * time.Second
+	tailBatchLimit      = 500
 )
 
-// NewLogsController 创建一个新的日志控制器
-func NewLogsController() *LogsController {
-	return &LogsController{}
+// NewLogsController 创建一个新的日志控制器，日志列表、详情、取值、字段统计、导出和实时追踪都从store读取
+func NewLogsController(store models.LogStore) *LogsController {
+	return &LogsController{store: store}
 }
 
 // QueryLogs 查询日志记录
 func (c *LogsController) QueryLogs(w http.ResponseWriter, r *http.Request) {
-	// 只允许GET请求
-	if r.Method != http.MethodGet {
-		utils.RespondWithError(w, http.StatusMethodNotAllowed, "只允许GET请求")
-		return
-	}
-
 	// 不再检查授权，直接让所有请求通过
 
 	// 解析查询参数
-	options := parseLogsQueryParams(r)
+	options, ok := parseLogsQueryParams(w, r)
+	if !ok || !validateLogQuery(w, options) {
+		return
+	}
 
 	// 检查是否请求了总条数
-	countRequested := r.URL.Query().Get("count") == "true"
+	countStr := r.URL.Query().Get("count")
+	countRequested := countStr == "true" || countStr == "1"
 
-	// 从数据库获取日志数据或降级使用模拟数据
-	logs, total, dbTotal, err := models.QueryLogs(options, countRequested)
+	// 从日志存储获取日志数据，查询失败时返回错误
+	logs, total, dbTotal, err := models.QueryLogs(r.Context(), c.store, options, countRequested)
 	if err != nil {
-		log.Printf("查询日志失败: %v", err)
-		utils.RespondWithError(w, http.StatusInternalServerError, "查询日志失败")
+		respondDataError(w, "查询日志失败", err)
 		return
 	}
 
-	// 返回结果，包含总条数
-	result := map[string]interface{}{
-		"success": true,
-		"data":    logs,
-		"total":   total,
+	// 返回结果，total为符合条件的条数，请求了count时meta.table_rows为表中的总条数
+	response := utils.Response{
+		Columns:    models.KV7ResultColumns(nil),
+		Data:       logs,
+		Pagination: utils.NewPagination(total, options.Offset, options.Limit, len(logs)),
 	}
-
-	// 如果请求了总条数，添加dbTotalCount字段
 	if countRequested {
-		result["dbTotalCount"] = dbTotal
+		response.Meta.TableRows = utils.Int64(dbTotal)
 	}
 
-	utils.RespondWithJSON(w, http.StatusOK, result)
+	utils.Respond(w, http.StatusOK, response)
 }
 
-// GetLogDetail 获取日志详情
+// GetLogDetail 获取日志详情，路径为 /api/logs/{id}，兼容 /api/logs/detail?id=
 func (c *LogsController) GetLogDetail(w http.ResponseWriter, r *http.Request) {
-	// 只允许GET请求
-	if r.Method != http.MethodGet {
-		utils.RespondWithError(w, http.StatusMethodNotAllowed, "只允许GET请求")
-		return
-	}
-
 	// 不再检查授权，直接让所有请求通过
 
 	// 获取日志ID
-	logID := r.URL.Query().Get("id")
+	logID := router.Param(r, "id")
+	if logID == "" {
+		logID = r.URL.Query().Get("id")
+	}
 	if logID == "" {
 		utils.RespondWithError(w, http.StatusBadRequest, "日志ID不能为空")
 		return
 	}
 
-	// 获取模拟日志记录而不是从ClickHouse获取
-	record, err := models.GetFullKV7Record(logID)
+	// 从日志存储获取完整记录，不存在时返回404
+	record, err := c.store.Get(r.Context(), logID)
 	if err != nil {
-		log.Printf("获取日志详情失败: %v", err)
-		utils.RespondWithError(w, http.StatusInternalServerError, "获取日志详情失败")
+		respondDataError(w, "获取日志详情失败", err)
 		return
 	}
 
-	// 返回结果
-	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"success": true,
-		"data":    record,
-	})
+	// 返回结果，记录包含全部列
+	utils.Respond(w, http.StatusOK, utils.Response{Columns: models.KV7ResultColumns(models.KV7Columns()), Data: record})
 }
 
-// GetLogFields 获取可用的日志字段
+// GetLogFields 获取日志表的字段及其统计
+// 字段来自日志存储(ClickHouse为system.columns)并合并别名和字段映射；支持与QueryLogs相同的时间范围和筛选参数，
+// fields(逗号分隔)限定字段，top指定高频值个数，stats=false时不做统计
 func (c *LogsController) GetLogFields(w http.ResponseWriter, r *http.Request) {
-	// 只允许GET请求
-	if r.Method != http.MethodGet {
-		utils.RespondWithError(w, http.StatusMethodNotAllowed, "只允许GET请求")
+	queryOptions, ok := parseLogsQueryParams(w, r)
+	if !ok || !validateLogQuery(w, queryOptions) {
 		return
 	}
+	options := models.LogFieldsOptions{
+		QueryOptions: queryOptions,
+		TopK:         models.DefaultFieldTopK,
+		WithStats:    r.URL.Query().Get("stats") != "false",
+	}
 
-	// 不再检查授权，直接让所有请求通过
+	if fieldsStr := r.URL.Query().Get("fields"); fieldsStr != "" {
+		for _, f := range strings.Split(fieldsStr, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				options.Columns = append(options.Columns, f)
+			}
+		}
+	}
+
+	if topStr := r.URL.Query().Get("top"); topStr != "" {
+		top, err := strconv.Atoi(topStr)
+		if err != nil || top <= 0 || top > models.MaxFieldTopK {
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("top参数必须在1到%d之间", models.MaxFieldTopK))
+			return
+		}
+		options.TopK = top
+	}
 
-	// 更新字段列表，使其与kv_7表的字段匹配
-	fields := []map[string]string{
-		{"key": "date", "label": "日期"},
-		{"key": "time", "label": "时间"},
-		{"key": "id", "label": "日志ID"},
-		{"key": "platform", "label": "平台"},
-		{"key": "category", "label": "类别"},
-		{"key": "action", "label": "操作"},
-		{"key": "os", "label": "操作系统"},
-		{"key": "uid", "label": "用户ID"},
-		{"key": "app_id", "label": "应用ID"},
-		{"key": "version", "label": "版本"},
-		{"key": "device_id", "label": "设备ID"},
-		{"key": "model", "label": "设备型号"},
-		{"key": "os_ver", "label": "系统版本"},
-		{"key": "msg", "label": "消息内容"},
-		{"key": "label", "label": "标签"},
-		{"key": "state", "label": "状态"},
-		{"key": "value", "label": "数值"},
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	fields, totalRows, err := models.GetLogFields(ctx, c.store, options)
+	if err != nil {
+		respondDataError(w, "获取日志字段失败", err)
+		return
 	}
 
 	// 返回结果
 	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"success": true,
-		"data":    fields,
+		"fields":     fields,
+		"total_rows": totalRows,
+		"start_time": options.StartTime.Format(time.RFC3339),
+		"end_time":   options.EndTime.Format(time.RFC3339),
 	})
 }
 
-// GetProjects 获取可选的项目列表
+// GetProjects 获取时间范围内有日志的项目及日志条数
+// 时间范围参数与QueryLogs相同，默认最近24小时
 func (c *LogsController) GetProjects(w http.ResponseWriter, r *http.Request) {
-	// 只允许GET请求
-	if r.Method != http.MethodGet {
-		utils.RespondWithError(w, http.StatusMethodNotAllowed, "只允许GET请求")
+	options, ok := parseLogsQueryParams(w, r)
+	if !ok {
 		return
 	}
 
-	// 不再检查授权，直接让所有请求通过
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
 
-	// 直接返回预定义的项目列表，不再从数据库获取
-	projects := []map[string]string{
-		{"value": "腾讯云前端监控项目Web-?20000.2:demo", "text": "腾讯云前端监控项目Web-?20000.2:demo"},
-		{"value": "腾讯文档Web-10001", "text": "腾讯文档Web-10001"},
-		{"value": "腾讯云音视频项目-30001", "text": "腾讯云音视频项目-30001"},
+	projects, err := models.GetProjects(ctx, c.store, options.StartTime, options.EndTime)
+	if err != nil {
+		respondDataError(w, "获取项目列表失败", err)
+		return
 	}
 
 	// 返回结果
-	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"success": true,
-		"data":    projects,
-	})
+	utils.RespondWithJSON(w, http.StatusOK, projects)
 }
 
-// GetLogTypes 获取可选的日志类型
+// GetLogTypes 获取时间范围内出现的日志级别和类别及日志条数
+// levels为日志级别，第一项“全部日志”的value为空；categories为日志类别
 func (c *LogsController) GetLogTypes(w http.ResponseWriter, r *http.Request) {
-	// 只允许GET请求
-	if r.Method != http.MethodGet {
-		utils.RespondWithError(w, http.StatusMethodNotAllowed, "只允许GET请求")
+	options, ok := parseLogsQueryParams(w, r)
+	if !ok {
 		return
 	}
 
-	// 不再检查授权，直接让所有请求通过
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
 
-	// 直接返回预定义的日志类型列表，不再从数据库获取
-	logTypes := []map[string]string{
-		{"value": "全部日志", "text": "全部日志"},
-		{"value": "错误日志", "text": "错误日志"},
-		{"value": "警告日志", "text": "警告日志"},
-		{"value": "信息日志", "text": "信息日志"},
-		{"value": "调试日志", "text": "调试日志"},
+	levels, err := models.GetLogLevels(ctx, c.store, options.StartTime, options.EndTime)
+	if err != nil {
+		respondDataError(w, "获取日志类型失败", err)
+		return
 	}
 
+	categories, err := models.GetLogCategories(ctx, c.store, options.StartTime, options.EndTime)
+	if err != nil {
+		respondDataError(w, "获取日志类型失败", err)
+		return
+	}
+
+	all := models.DistinctValue{Value: "", Text: "全部日志"}
+	for _, l := range levels {
+		all.Count += l.Count
+	}
+	logTypes := append([]models.DistinctValue{all}, levels...)
+
 	// 返回结果
 	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"success": true,
-		"data":    logTypes,
+		"levels":     logTypes,
+		"categories": categories,
 	})
 }
 
-// ExportLogs 导出日志数据
+// ExportLogs 流式导出日志数据，边读取边写入响应，不限制行数
+// 参数见parseExportRequest，耗时较长的导出可使用异步导出任务 /api/exports
 func (c *LogsController) ExportLogs(w http.ResponseWriter, r *http.Request) {
-	// 只允许GET请求
-	if r.Method != http.MethodGet {
-		utils.RespondWithError(w, http.StatusMethodNotAllowed, "只允许GET请求")
+	req, ok := parseExportRequest(w, r)
+	if !ok {
 		return
 	}
 
-	// 不再检查授权，直接让所有请求通过
-
-	// 解析查询参数
-	options := parseLogsQueryParams(r)
+	stream, err := models.OpenLogStream(r.Context(), c.store, req.Options, req.Columns)
+	if err != nil {
+		respondDataError(w, "导出日志失败", err)
+		return
+	}
+	defer stream.Close()
 
-	// 设置导出的数量限制
-	options.Limit = 10000 // 最多导出1万条记录
+	w.Header().Set("Content-Type", req.contentType())
+	w.Header().Set("Content-Disposition", "attachment; filename=logs_export."+req.extension())
 
-	// 从数据库获取日志数据或降级使用模拟数据
-	logs, _, _, err := models.QueryLogs(options, false)
+	out := newExportOutput(w, req.Gzip)
+	rows, err := req.format().Write(out, stream, req.Columns)
+	if err == nil {
+		err = out.Close()
+	}
 	if err != nil {
-		log.Printf("导出日志失败: %v", err)
-		utils.RespondWithError(w, http.StatusInternalServerError, "导出日志失败")
+		// 响应头已发送，只能中断输出并记录日志
+		log.Printf("导出日志中断，已写入%d行: %v", rows, err)
 		return
 	}
+	log.Printf("导出日志完成，格式%s，共%d行", req.Format, rows)
+}
 
-	// 设置响应头，指示这是CSV文件下载
-	w.Header().Set("Content-Type", "text/csv")
-	w.Header().Set("Content-Disposition", "attachment; filename=logs_export.csv")
+// TailLogs 通过Server-Sent Events实时推送新写入的日志
+// 支持与QueryLogs相同的筛选参数，另外可通过since(RFC3339)指定起始写入时间，
+// interval(秒)指定轮询间隔
+func (c *LogsController) TailLogs(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "服务器不支持流式响应")
+		return
+	}
 
-	// 生成CSV内容
-	csvContent := "data_time,id,user_id,platform,os,action,message\n"
-	for _, record := range logs {
-		// 避免CSV注入
-		csvContent += escapeCSV(record.DataTime.Format(time.RFC3339)) + ","
-		csvContent += escapeCSV(record.ID) + ","
-		csvContent += escapeCSV(record.UserID) + ","
-		csvContent += escapeCSV(record.Platform) + ","
-		csvContent += escapeCSV(record.OS) + ","
-		csvContent += escapeCSV(record.Action) + ","
-		csvContent += escapeCSV(record.D1) + "\n" // 假设D1存储了message
+	options, ok := parseLogsQueryParams(w, r)
+	if !ok || !validateLogQuery(w, options) {
+		return
 	}
 
-	// 写入响应
-	w.Write([]byte(csvContent))
-}
+	// 默认从当前时间开始追踪
+	since := time.Now().Add(-tailDefaultInterval)
+	if sinceStr := r.URL.Query().Get("since"); sinceStr != "" {
+		t, err := utils.ParseTime(sinceStr)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "since参数格式无效")
+			return
+		}
+		since = t
+	}
 
-// parseLogsQueryParams 解析日志查询参数
-func parseLogsQueryParams(r *http.Request) database.QueryOptions {
-	options := database.DefaultQueryOptions()
+	interval := tailDefaultInterval
+	if intervalStr := r.URL.Query().Get("interval"); intervalStr != "" {
+		seconds, err := strconv.Atoi(intervalStr)
+		if err != nil || time.Duration(seconds)*time.Second < tailMinInterval {
+			utils.RespondWithError(w, http.StatusBadRequest, "interval参数无效")
+			return
+		}
+		interval = time.Duration(seconds) * time.Second
+	}
 
-	// 解析时间范围
-	startTimeStr := r.URL.Query().Get("start_time")
-	endTimeStr := r.URL.Query().Get("end_time")
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	log.Printf("开始实时追踪日志: since=%s, interval=%s", since.Format(time.RFC3339), interval)
+
+	cursor := models.NewTailCursor(since)
+	pollTicker := time.NewTicker(interval)
+	defer pollTicker.Stop()
+	heartbeatTicker := time.NewTicker(tailHeartbeat)
+	defer heartbeatTicker.Stop()
+
+	// 客户端断开时r.Context()被取消，轮询随之停止
+	ctx := r.Context()
+	poll := func() bool {
+		queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+
+		records, err := c.store.Tail(queryCtx, options, cursor, tailBatchLimit)
+		if err != nil {
+			if ctx.Err() != nil {
+				return false
+			}
+			log.Printf("实时追踪查询失败: %v", err)
+			return writeSSE(w, flusher, "error", "", utils.NewError(w, utils.CodeForStatus(DataErrorStatus(err)), "查询新日志失败", nil))
+		}
 
-	if startTimeStr != "" {
-		startTime, err := time.Parse("2006-01-02 15:04", startTimeStr)
-		if err == nil {
-			options.StartTime = startTime
+		for _, record := range records {
+			if !writeSSE(w, flusher, "log", record.ID, record) {
+				return false
+			}
 		}
+		return true
+	}
+
+	if !poll() {
+		return
 	}
 
-	if endTimeStr != "" {
-		endTime, err := time.Parse("2006-01-02 15:04", endTimeStr)
-		if err == nil {
-			options.EndTime = endTime
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("实时追踪客户端已断开")
+			return
+		case <-pollTicker.C:
+			if !poll() {
+				return
+			}
+		case <-heartbeatTicker.C:
+			if _, err := fmt.Fprintf(w, ": heartbeat %d\n\n", time.Now().Unix()); err != nil {
+				return
+			}
+			flusher.Flush()
 		}
 	}
+}
+
+// writeSSE 写入一条SSE事件，写入失败（通常是客户端断开）时返回false
+func writeSSE(w http.ResponseWriter, flusher http.Flusher, event string, id string, payload interface{}) bool {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("序列化SSE事件失败: %v", err)
+		return true
+	}
+
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return false
+		}
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return false
+	}
+	flusher.Flush()
+	return true
+}
+
+// parseTimeRange 解析start_time和end_time，未指定的参数保留start、end中原有的值
+// 格式无效时写入400 invalid_params响应并返回false，details与路由参数校验的格式相同
+func parseTimeRange(w http.ResponseWriter, r *http.Request, start, end *time.Time) bool {
+	var invalid []router.InvalidParam
+	for _, param := range []struct {
+		name string
+		t    *time.Time
+	}{{"start_time", start}, {"end_time", end}} {
+		value := r.URL.Query().Get(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := utils.ParseTime(value)
+		if err != nil {
+			invalid = append(invalid, router.InvalidParam{
+				In: router.InQuery, Field: param.name, Reason: "必须是RFC3339或YYYY-MM-DD HH:MM格式的时间", Value: value,
+			})
+			continue
+		}
+		*param.t = t
+	}
+
+	if len(invalid) > 0 {
+		utils.RespondWithErrorCode(w, http.StatusBadRequest, utils.CodeInvalidParams, "", invalid)
+		return false
+	}
+	return true
+}
+
+// parseLogsQueryParams 解析日志查询参数，时间范围默认最近24小时，格式无效时写入400响应并返回false
+func parseLogsQueryParams(w http.ResponseWriter, r *http.Request) (database.QueryOptions, bool) {
+	options := database.DefaultQueryOptions()
+
+	// 解析时间范围
+	if !parseTimeRange(w, r, &options.StartTime, &options.EndTime) {
+		return options, false
+	}
 
 	// 解析分页参数
 	pageStr := r.URL.Query().Get("page")
@@ -278,15 +405,19 @@ func parseLogsQueryParams(r *http.Request) database.QueryOptions {
 		}
 	}
 
-	// 计算offset和limit
+	// 计算offset和limit，cursor为上一页响应中的next_cursor，指定时代替page
 	options.Offset = (page - 1) * pageSize
 	options.Limit = pageSize
+	if offset, err := utils.DecodeCursor(r.URL.Query().Get("cursor")); err == nil {
+		options.Offset = offset
+	}
 
 	// 解析其他筛选参数 - 根据前端表单字段名调整
 	options.UserID = r.URL.Query().Get("user_id")    // 用户ID
 	options.Platform = r.URL.Query().Get("platform") // 平台
 	options.OS = r.URL.Query().Get("os")             // 操作系统
 	options.Category = r.URL.Query().Get("category") // 日志类别
+	options.Level = r.URL.Query().Get("level")       // 日志级别
 	options.Action = r.URL.Query().Get("action")     // 操作类型
 
 	// 项目和日志类型筛选（可根据实际需求调整字段映射）
@@ -297,17 +428,9 @@ func parseLogsQueryParams(r *http.Request) database.QueryOptions {
 		options.AppID = project
 	}
 
-	if logType != "" && logType != "全部日志" {
-		switch logType {
-		case "错误日志":
-			options.Category = "ERROR"
-		case "警告日志":
-			options.Category = "WARNING"
-		case "信息日志":
-			options.Category = "INFO"
-		case "调试日志":
-			options.Category = "DEBUG"
-		}
+	// log_type为/api/logs/types返回的日志级别，按level列筛选
+	if level := models.LogTypeLevel(logType); level != "" {
+		options.Level = level
 	}
 
 	// 添加字段搜索 - 这些需要在frontend.QueryOptions中添加处理
@@ -328,6 +451,9 @@ func parseLogsQueryParams(r *http.Request) database.QueryOptions {
 		options.Filter["model"] = modelFilter
 	}
 
+	// 查询语言表达式，如 level:ERROR AND platform:(iOS OR Android)
+	options.Query = strings.TrimSpace(r.URL.Query().Get("q"))
+
 	// 排序配置
 	sortField := r.URL.Query().Get("sort_field")
 	sortOrder := r.URL.Query().Get("sort_order")
@@ -340,14 +466,31 @@ func parseLogsQueryParams(r *http.Request) database.QueryOptions {
 		options.SortOrder = sortOrder
 	}
 
-	return options
+	return options, true
 }
 
-// escapeCSV 转义CSV字段，防止CSV注入
-func escapeCSV(field string) string {
-	if strings.Contains(field, ",") || strings.Contains(field, "\"") || strings.Contains(field, "\n") {
-		// 如果字段包含逗号、双引号或换行符，需要用双引号包围，并将字段中的双引号替换为两个双引号
-		return "\"" + strings.ReplaceAll(field, "\"", "\"\"") + "\""
+// validateLogQuery 校验查询语言表达式，无效时返回包含出错位置的400响应
+func validateLogQuery(w http.ResponseWriter, options database.QueryOptions) bool {
+	q := options.Query
+	if q == "" {
+		return true
 	}
-	return field
+
+	_, _, err := models.CompileLogQuery(q, options.AppID)
+	if err == nil {
+		return true
+	}
+
+	var queryErr *querylang.Error
+	if errors.As(err, &queryErr) {
+		utils.RespondWithErrorCode(w, http.StatusBadRequest, utils.CodeInvalidQuery, queryErr.Message, map[string]interface{}{
+			"position": queryErr.Pos,
+			"query":    q,
+		})
+		return false
+	}
+
+	log.Printf("编译查询语句失败: %v", err)
+	utils.RespondWithErrorCode(w, http.StatusBadRequest, utils.CodeInvalidQuery, "", nil)
+	return false
 }
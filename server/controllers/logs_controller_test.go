@@ -0,0 +1,301 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"server/models"
+)
+
+// newTestStore 返回包含固定记录的内存存储，记录的data_time都在默认的24小时查询范围内，old除外
+func newTestStore(now time.Time) *models.MemoryStore {
+	written := now.Add(-2 * time.Hour)
+	return models.NewMemoryStore([]models.KV7Record{
+		{ID: "r1", DataTime: now.Add(-3 * time.Hour), WriteTime: written, Platform: "iOS", OS: "iOS", UserID: "u1", Level: "ERROR",
+			Category: "PERF_NET_SSE", Action: "request", D1: "timeout after 5s", D38: "wifi", V1: 300},
+		{ID: "r2", DataTime: now.Add(-2 * time.Hour), WriteTime: written, Platform: "Android", OS: "Android", UserID: "u2", Level: "INFO",
+			Category: "PERF_NET_SSE", Action: "request", D1: "ok", D38: "4g", V1: 100},
+		{ID: "r3", DataTime: now.Add(-1 * time.Hour), WriteTime: written, Platform: "iOS", OS: "iOS", UserID: "u1", Level: "INFO",
+			Category: "page", Action: "view", D1: "login"},
+		{ID: "old", DataTime: now.Add(-48 * time.Hour), WriteTime: written, Platform: "iOS", OS: "iOS", UserID: "u3", Level: "ERROR",
+			Category: "page", Action: "view"},
+	})
+}
+
+// testResponse 响应信封，data按接口解析
+type testResponse struct {
+	Data       json.RawMessage `json:"data"`
+	Pagination *struct {
+		Total *int64 `json:"total"`
+	} `json:"pagination"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// serve 调用handler并解析响应信封
+func serve(t *testing.T, handler http.HandlerFunc, req *http.Request) (int, testResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	var resp testResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("解析响应失败: %v\n%s", err, rec.Body.String())
+	}
+	return rec.Code, resp
+}
+
+// recordIDs 按顺序返回data中记录的ID
+func recordIDs(t *testing.T, data json.RawMessage) string {
+	t.Helper()
+	var records []models.KV7Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		t.Fatalf("解析记录失败: %v\n%s", err, data)
+	}
+	ids := make([]string, len(records))
+	for i, r := range records {
+		ids[i] = r.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestQueryLogs(t *testing.T) {
+	c := NewLogsController(newTestStore(time.Now()))
+	tests := []struct {
+		query string
+		ids   string
+		total int64
+	}{
+		{"", "r3,r2,r1", 3},
+		{"platform=iOS", "r3,r1", 2},
+		{"level=ERROR", "r1", 1},
+		{"log_type=ERROR", "r1", 1},
+		{"log_type=" + url.QueryEscape("错误日志"), "r1", 1},
+		{"q=" + url.QueryEscape("platform:iOS AND d1:timeout*"), "r1", 1},
+		{"page_size=2&page=2", "r1", 3},
+		{"start_time=" + time.Now().Add(-72*time.Hour).Format(time.RFC3339), "r3,r2,r1,old", 4},
+	}
+	for _, tt := range tests {
+		status, resp := serve(t, c.QueryLogs, httptest.NewRequest(http.MethodGet, "/api/logs?"+tt.query, nil))
+		if status != http.StatusOK {
+			t.Errorf("%s: 状态码为%d，错误%+v", tt.query, status, resp.Error)
+			continue
+		}
+		if got := recordIDs(t, resp.Data); got != tt.ids {
+			t.Errorf("%s: 返回%s，期望%s", tt.query, got, tt.ids)
+		}
+		if resp.Pagination == nil || resp.Pagination.Total == nil || *resp.Pagination.Total != tt.total {
+			t.Errorf("%s: 分页信息为%+v，期望total=%d", tt.query, resp.Pagination, tt.total)
+		}
+	}
+}
+
+func TestQueryLogsInvalidQuery(t *testing.T) {
+	c := NewLogsController(newTestStore(time.Now()))
+	status, resp := serve(t, c.QueryLogs, httptest.NewRequest(http.MethodGet, "/api/logs?q=level:", nil))
+	if status != http.StatusBadRequest || resp.Error == nil || resp.Error.Code != "invalid_query" {
+		t.Errorf("状态码为%d，错误%+v，期望400 invalid_query", status, resp.Error)
+	}
+}
+
+func TestInvalidTimeRange(t *testing.T) {
+	store := newTestStore(time.Now())
+	logs := NewLogsController(store)
+	analytics := NewAnalyticsController(store)
+	handlers := map[string]http.HandlerFunc{
+		"logs":     logs.QueryLogs,
+		"fields":   logs.GetLogFields,
+		"projects": logs.GetProjects,
+		"types":    logs.GetLogTypes,
+		"export":   logs.ExportLogs,
+		"recent":   analytics.GetRecentData,
+		"events":   analytics.GetEventAnalytics,
+		"users":    analytics.GetUserDistribution,
+		"network":  analytics.GetNetworkPerformance,
+		"ios":      analytics.GetIOSDeviceStats,
+	}
+	// 无效的时间不再按默认范围查询，返回400 invalid_params
+	for name, handler := range handlers {
+		for _, query := range []string{"start_time=yesterday", "end_time=2024-13-01"} {
+			status, resp := serve(t, handler, httptest.NewRequest(http.MethodGet, "/?"+query, nil))
+			if status != http.StatusBadRequest || resp.Error == nil || resp.Error.Code != "invalid_params" {
+				t.Errorf("%s %s: 状态码为%d，错误%+v，期望400 invalid_params", name, query, status, resp.Error)
+			}
+		}
+	}
+}
+
+func TestGetLogDetailEncoding(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	record := models.KV7Record{ID: "big", DataTime: now, WriteTime: now, Time: 1<<62 + 1, V1: -(1<<60 + 3), Stamp: 7}
+	c := NewLogsController(models.NewMemoryStore([]models.KV7Record{record}))
+
+	rec := httptest.NewRecorder()
+	c.GetLogDetail(rec, httptest.NewRequest(http.MethodGet, "/api/logs/detail?id=big", nil))
+	var resp struct {
+		Columns []struct {
+			Name string `json:"name"`
+			Type string `json:"type"`
+		} `json:"columns"`
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("解析响应失败: %v\n%s", err, rec.Body.String())
+	}
+
+	// Int64列为十进制字符串，Int32列为数字，DateTime为RFC3339，与/api/query的格式一致
+	want := map[string]interface{}{
+		"time":      "4611686018427387905",
+		"v1":        "-1152921504606846979",
+		"stamp":     float64(7),
+		"data_time": "2024-01-02T03:04:05Z",
+	}
+	for column, value := range want {
+		if resp.Data[column] != value {
+			t.Errorf("%s为%#v，期望%#v", column, resp.Data[column], value)
+		}
+	}
+	if len(resp.Columns) != len(models.KV7Columns()) || resp.Columns[4].Name != "time" || resp.Columns[4].Type != "Int64" {
+		t.Errorf("columns为%v，期望全部列及其类型", resp.Columns)
+	}
+
+	// 输出的JSON可以原样解析回记录
+	var data struct {
+		Data models.KV7Record `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &data); err != nil {
+		t.Fatalf("解析记录失败: %v", err)
+	}
+	if data.Data.Time != record.Time || data.Data.V1 != record.V1 || !data.Data.DataTime.Equal(now) {
+		t.Errorf("解析得到%+v，期望与写入的记录相同", data.Data)
+	}
+}
+
+func TestGetLogFieldsStats(t *testing.T) {
+	c := NewLogsController(newTestStore(time.Now()))
+	status, resp := serve(t, c.GetLogFields, httptest.NewRequest(http.MethodGet, "/api/logs/fields?fields=platform,level,d2&top=1", nil))
+	if status != http.StatusOK {
+		t.Fatalf("状态码为%d，错误%+v", status, resp.Error)
+	}
+
+	var data struct {
+		Fields    []models.LogField `json:"fields"`
+		TotalRows uint64            `json:"total_rows"`
+	}
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		t.Fatalf("解析字段失败: %v", err)
+	}
+	if data.TotalRows != 3 {
+		t.Errorf("统计了%d行，期望3行", data.TotalRows)
+	}
+
+	stats := map[string]*models.FieldStats{}
+	for _, f := range data.Fields {
+		stats[f.Name] = f.Stats
+	}
+	if s := stats["platform"]; s == nil || s.Cardinality != 2 || strings.Join(s.TopValues, ",") != "iOS" {
+		t.Errorf("platform的统计为%+v，期望基数2、高频值iOS", s)
+	}
+	if s := stats["level"]; s == nil || s.Cardinality != 2 || strings.Join(s.TopValues, ",") != "INFO" {
+		t.Errorf("level的统计为%+v，期望基数2、高频值INFO", s)
+	}
+	if s := stats["d2"]; s == nil || s.EmptyRatio != 1 {
+		t.Errorf("d2的统计为%+v，期望全部为空", s)
+	}
+}
+
+// sseEvent 一条SSE事件
+type sseEvent struct {
+	Event string
+	ID    string
+}
+
+// readSSE 逐条读取SSE事件，连接关闭时关闭通道
+func readSSE(body *bufio.Reader, events chan<- sseEvent) {
+	defer close(events)
+	var ev sseEvent
+	for {
+		line, err := body.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "":
+			if ev.Event != "" {
+				events <- ev
+			}
+			ev = sseEvent{}
+		case strings.HasPrefix(line, "event: "):
+			ev.Event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "id: "):
+			ev.ID = strings.TrimPrefix(line, "id: ")
+		}
+	}
+}
+
+// nextEvent 等待下一条SSE事件
+func nextEvent(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+	select {
+	case ev, ok := <-events:
+		if !ok {
+			t.Fatal("连接已关闭")
+		}
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待SSE事件超时")
+	}
+	return sseEvent{}
+}
+
+func TestTailLogs(t *testing.T) {
+	now := time.Now()
+	store := newTestStore(now)
+	c := NewLogsController(store)
+	server := httptest.NewServer(http.HandlerFunc(c.TailLogs))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	target := server.URL + "?interval=1&platform=iOS&since=" + now.Add(-time.Minute).Format(time.RFC3339)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type为%s", ct)
+	}
+
+	events := make(chan sseEvent, 16)
+	go readSSE(bufio.NewReader(resp.Body), events)
+
+	// since之前写入的记录和不符合筛选条件的记录都不推送
+	written := time.Now()
+	store.Insert(ctx, []models.KV7Record{
+		{ID: "t1", DataTime: written, WriteTime: written, Platform: "iOS"},
+		{ID: "t2", DataTime: written, WriteTime: written, Platform: "Android"},
+	})
+	if ev := nextEvent(t, events); ev.Event != "log" || ev.ID != "t1" {
+		t.Fatalf("收到%+v，期望t1", ev)
+	}
+
+	// write_time早于水位线、迟到提交的记录仍然推送，已推送的t1不会重复
+	store.Insert(ctx, []models.KV7Record{
+		{ID: "t3", DataTime: written, WriteTime: written.Add(-time.Second), Platform: "iOS"},
+	})
+	if ev := nextEvent(t, events); ev.Event != "log" || ev.ID != "t3" {
+		t.Fatalf("收到%+v，期望t3", ev)
+	}
+}
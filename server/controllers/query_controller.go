@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+
+	"server/database"
+	"server/router"
+	"server/utils"
+)
+
+// QueryController 查看和终止正在执行的ClickHouse查询
+type QueryController struct{}
+
+// NewQueryController 创建一个新的查询控制器
+func NewQueryController() *QueryController {
+	return &QueryController{}
+}
+
+// ListQueries 返回正在执行的查询，按开始时间排序
+func (c *QueryController) ListQueries(w http.ResponseWriter, r *http.Request) {
+	queries := database.RunningQueries()
+	utils.RespondWithPage(w, http.StatusOK, queries, utils.NewPagination(len(queries), 0, 0, len(queries)))
+}
+
+// KillQuery 终止正在执行的查询，路径为 /api/queries/{id}
+func (c *QueryController) KillQuery(w http.ResponseWriter, r *http.Request) {
+	id := router.Param(r, "id")
+	found, err := database.KillQuery(r.Context(), id)
+	if !found {
+		utils.RespondWithError(w, http.StatusNotFound, "查询不存在或已结束")
+		return
+	}
+	if err != nil {
+		// 本地已取消，但服务端可能仍在执行
+		log.Printf("终止查询失败: %v", err)
+		utils.RespondWithError(w, http.StatusBadGateway, "终止查询失败: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "查询已终止",
+		"id":      id,
+	})
+}
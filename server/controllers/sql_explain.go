@@ -0,0 +1,266 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"server/database"
+	"server/models"
+	"server/sqlparse"
+	"server/utils"
+)
+
+// EXPLAIN的种类
+const (
+	explainPlan     = "plan"
+	explainPipeline = "pipeline"
+	explainEstimate = "estimate"
+)
+
+// 超过行数预算时的处理方式
+const (
+	rowBudgetWarn   = "warn"
+	rowBudgetReject = "reject"
+)
+
+// explainTimeout EXPLAIN不读取数据，正常情况下很快返回
+const explainTimeout = 10 * time.Second
+
+// rowBudget 查询预估读取行数的限制
+type rowBudget struct {
+	// maxRows 为0时不检查
+	maxRows uint64
+	action  string
+}
+
+// rowBudgetFromEnv 读取行数预算
+// SQL_ROW_BUDGET 为EXPLAIN ESTIMATE预估读取行数的上限(默认0，不检查)
+// SQL_ROW_BUDGET_ACTION 为warn(默认，返回警告)或reject(拒绝执行)
+func rowBudgetFromEnv() rowBudget {
+	budget := rowBudget{action: rowBudgetWarn}
+	if v := getEnvInt("SQL_ROW_BUDGET", 0); v > 0 {
+		budget.maxRows = uint64(v)
+	}
+	if strings.EqualFold(os.Getenv("SQL_ROW_BUDGET_ACTION"), rowBudgetReject) {
+		budget.action = rowBudgetReject
+	}
+	return budget
+}
+
+// exceeded 判断预估行数是否超过预算
+func (b rowBudget) exceeded(rows uint64) bool {
+	return b.maxRows > 0 && rows > b.maxRows
+}
+
+// message 返回超过预算时的提示
+func (b rowBudget) message(rows uint64) string {
+	return fmt.Sprintf("预估读取%d行，超过限制%d行，请添加data_time等过滤条件缩小范围", rows, b.maxRows)
+}
+
+// TableEstimate EXPLAIN ESTIMATE给出的单个表的读取量
+type TableEstimate struct {
+	Database string `json:"database"`
+	Table    string `json:"table"`
+	Parts    uint64 `json:"parts"`
+	Rows     uint64 `json:"rows"`
+	Marks    uint64 `json:"marks"`
+}
+
+// QueryEstimate 查询的预估读取量
+// 只有MergeTree系列的表会出现在EXPLAIN ESTIMATE中
+type QueryEstimate struct {
+	Tables []TableEstimate `json:"tables"`
+	Parts  uint64          `json:"parts"`
+	Rows   uint64          `json:"rows"`
+	Marks  uint64          `json:"marks"`
+}
+
+// ExplainSQL 对SQL控制台允许执行的SELECT语句执行EXPLAIN PLAN、EXPLAIN PIPELINE和EXPLAIN ESTIMATE
+// 请求体为{"query", "types", "params"}，types可选plan、pipeline、estimate，默认全部
+func (c *SQLController) ExplainSQL(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Query  string            `json:"query"`
+		Types  []string          `json:"types"`
+		Params map[string]string `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "无效的请求数据")
+		return
+	}
+	if request.Query == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "SQL查询不能为空")
+		return
+	}
+
+	types := map[string]bool{}
+	if len(request.Types) == 0 {
+		request.Types = []string{explainPlan, explainPipeline, explainEstimate}
+	}
+	for _, t := range request.Types {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != explainPlan && t != explainPipeline && t != explainEstimate {
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("不支持的EXPLAIN类型: %s，可选plan、pipeline、estimate", t))
+			return
+		}
+		types[t] = true
+	}
+
+	// 与ExecuteSQL使用相同的解析、访问策略和参数绑定
+	stmt, params, err := c.bindParams(request.Query, nil, request.Params)
+	if err != nil {
+		respondSQLError(w, request.Query, err)
+		return
+	}
+	if stmt.Kind != sqlparse.StatementSelect {
+		utils.RespondWithError(w, http.StatusBadRequest, "EXPLAIN只支持SELECT语句")
+		return
+	}
+
+	if mock := models.Mock(); mock != nil {
+		utils.RespondWithJSON(w, http.StatusOK, c.mockExplain(mock, types))
+		return
+	}
+
+	conn, err := database.Conn()
+	if err != nil {
+		respondDataError(w, "EXPLAIN执行失败", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(withParams(r.Context(), params), explainTimeout)
+	defer cancel()
+
+	data := map[string]interface{}{}
+	for _, t := range []string{explainPlan, explainPipeline} {
+		if !types[t] {
+			continue
+		}
+		lines, err := explainLines(ctx, conn, t, stmt)
+		if err != nil {
+			respondExplainError(w, err)
+			return
+		}
+		data[t] = lines
+	}
+	if types[explainEstimate] {
+		estimate, err := estimateQuery(ctx, conn, stmt)
+		if err != nil {
+			respondExplainError(w, err)
+			return
+		}
+		data[explainEstimate] = estimate
+		data["row_budget"] = c.budget.maxRows
+		data["over_budget"] = c.budget.exceeded(estimate.Rows)
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, data)
+}
+
+// checkRowBudget 执行前检查查询的预估读取行数
+// 超过预算时返回提示信息，配置为拒绝时同时写入400响应(row_budget_exceeded)并返回false
+// 预估失败不影响查询执行
+func (c *SQLController) checkRowBudget(ctx context.Context, w http.ResponseWriter, conn *database.ClickHouseDB, stmt *sqlparse.Statement) (string, bool) {
+	if c.budget.maxRows == 0 || stmt.Kind != sqlparse.StatementSelect {
+		return "", true
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, explainTimeout)
+	defer cancel()
+
+	estimate, err := estimateQuery(ctx, conn, stmt)
+	if err != nil {
+		log.Printf("预估查询读取行数失败: %v", err)
+		return "", true
+	}
+	if !c.budget.exceeded(estimate.Rows) {
+		return "", true
+	}
+
+	message := c.budget.message(estimate.Rows)
+	if c.budget.action == rowBudgetReject {
+		utils.RespondWithErrorCode(w, http.StatusBadRequest, utils.CodeRowBudgetExceeded, message, map[string]interface{}{
+			"estimated_rows": estimate.Rows,
+			"row_budget":     c.budget.maxRows,
+		})
+		return message, false
+	}
+	log.Printf("查询超过行数预算: %s", message)
+	return message, true
+}
+
+// explainLines 执行EXPLAIN PLAN或EXPLAIN PIPELINE，返回逐行的输出
+func explainLines(ctx context.Context, conn *database.ClickHouseDB, kind string, stmt *sqlparse.Statement) ([]string, error) {
+	// 换行避免原语句开头的注释吞掉EXPLAIN关键字之后的内容
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("EXPLAIN %s\n%s", strings.ToUpper(kind), stmt.SQL()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []string{}
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
+
+// estimateQuery 执行EXPLAIN ESTIMATE，返回各表及合计的parts、rows、marks
+func estimateQuery(ctx context.Context, conn *database.ClickHouseDB, stmt *sqlparse.Statement) (QueryEstimate, error) {
+	estimate := QueryEstimate{Tables: []TableEstimate{}}
+
+	rows, err := conn.QueryContext(ctx, "EXPLAIN ESTIMATE\n"+stmt.SQL())
+	if err != nil {
+		return estimate, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t TableEstimate
+		if err := rows.Scan(&t.Database, &t.Table, &t.Parts, &t.Rows, &t.Marks); err != nil {
+			return estimate, err
+		}
+		estimate.Tables = append(estimate.Tables, t)
+		estimate.Parts += t.Parts
+		estimate.Rows += t.Rows
+		estimate.Marks += t.Marks
+	}
+	return estimate, rows.Err()
+}
+
+// respondExplainError ClickHouse拒绝语句(如列不存在)时返回400，其他错误见DataErrorStatus
+func respondExplainError(w http.ResponseWriter, err error) {
+	respondQueryError(w, "EXPLAIN执行失败", err)
+}
+
+// mockExplain 由mock或fixture数据源生成EXPLAIN结果
+func (c *SQLController) mockExplain(mock models.MockSource, types map[string]bool) map[string]interface{} {
+	data := map[string]interface{}{}
+	for _, t := range []string{explainPlan, explainPipeline} {
+		if types[t] {
+			data[t] = mock.ExplainLines(t)
+		}
+	}
+	if types[explainEstimate] {
+		rows := mock.EstimatedRows()
+		table := TableEstimate{Database: "test_db", Table: "kv_7", Parts: 3, Rows: rows, Marks: 3}
+		data[explainEstimate] = QueryEstimate{
+			Tables: []TableEstimate{table},
+			Parts:  table.Parts,
+			Rows:   table.Rows,
+			Marks:  table.Marks,
+		}
+		data["row_budget"] = c.budget.maxRows
+		data["over_budget"] = c.budget.exceeded(table.Rows)
+	}
+	return data
+}
@@ -0,0 +1,328 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+
+	"server/models"
+	"server/router"
+	"server/sqlparse"
+	"server/utils"
+)
+
+// 执行历史分页
+const (
+	defaultHistoryPageSize = 50
+	maxHistoryPageSize     = 500
+)
+
+// requestUser 返回请求的用户，用于执行历史、保存的查询和导出任务
+func requestUser(r *http.Request) string {
+	user, err := utils.GetUserFromRequest(r)
+	if err != nil || user == "" {
+		return "anonymous"
+	}
+	return user
+}
+
+// bindParams 解析SQL并绑定查询参数，返回检查通过的语句和参数值
+// SQL中的 {name} 按declared中声明的类型(未声明时为String)改写为 {name:Type}，
+// 参数值作为ClickHouse查询参数发送，由服务端按类型解析，不拼接到SQL中
+func (c *SQLController) bindParams(query string, declared []models.QueryParameter, values map[string]string) (*sqlparse.Statement, clickhouse.Parameters, error) {
+	stmt, err := c.checkSQL(query)
+	if err != nil || len(stmt.Params) == 0 {
+		return stmt, nil, err
+	}
+
+	types := make(map[string]string, len(declared))
+	defaults := make(map[string]string, len(declared))
+	for _, p := range declared {
+		types[p.Name] = p.Type
+		if p.Default != "" {
+			defaults[p.Name] = p.Default
+		}
+	}
+
+	params := clickhouse.Parameters{}
+	untyped := false
+	for _, p := range stmt.Params {
+		if _, ok := params[p.Name]; ok {
+			continue
+		}
+		value, ok := values[p.Name]
+		if !ok {
+			value, ok = defaults[p.Name]
+		}
+		if !ok {
+			return nil, nil, fmt.Errorf("缺少参数%s的值", p.Name)
+		}
+
+		typ := p.Type
+		if typ == "" {
+			untyped = true
+			if typ = types[p.Name]; typ == "" {
+				typ = "String"
+			}
+		}
+		if err := models.ValidateParameterValue(typ, value); err != nil {
+			return nil, nil, fmt.Errorf("参数%s无效: %v", p.Name, err)
+		}
+		params[p.Name] = value
+	}
+
+	if untyped {
+		if stmt, err = c.checkSQL(stmt.BindTypes(types)); err != nil {
+			return nil, nil, err
+		}
+	}
+	return stmt, params, nil
+}
+
+// withParams 把查询参数放入context，之后派生的查询(分页、计数、预估)都会带上
+func withParams(ctx context.Context, params clickhouse.Parameters) context.Context {
+	if len(params) == 0 {
+		return ctx
+	}
+	return clickhouse.Context(ctx, clickhouse.WithParameters(params))
+}
+
+// recordHistory 记录SQL执行历史，写入失败只记录日志
+func recordHistory(entry models.SQLHistoryEntry) {
+	if err := models.SQLHistory().Record(entry); err != nil {
+		log.Printf("记录SQL执行历史失败: %v", err)
+	}
+}
+
+// ListHistory 按时间倒序返回当前用户的SQL执行历史，支持limit和offset，或用cursor代替offset(两者不能同时指定)
+func (c *SQLController) ListHistory(w http.ResponseWriter, r *http.Request) {
+	limit, offset := defaultHistoryPageSize, 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxHistoryPageSize {
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("limit参数必须在1到%d之间", maxHistoryPageSize))
+			return
+		}
+		limit = n
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			utils.RespondWithError(w, http.StatusBadRequest, "offset参数必须是非负整数")
+			return
+		}
+		offset = n
+	}
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		if r.URL.Query().Get("offset") != "" {
+			utils.RespondWithError(w, http.StatusBadRequest, "cursor和offset参数不能同时指定")
+			return
+		}
+		n, err := utils.DecodeCursor(v)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "cursor参数必须是响应中的next_cursor")
+			return
+		}
+		offset = n
+	}
+
+	entries, total := models.SQLHistory().List(requestUser(r), limit, offset)
+	utils.RespondWithPage(w, http.StatusOK, entries, utils.NewPagination(total, offset, limit, len(entries)))
+}
+
+// ClearHistory 清空当前用户的SQL执行历史
+func (c *SQLController) ClearHistory(w http.ResponseWriter, r *http.Request) {
+	if err := models.SQLHistory().Clear(requestUser(r)); err != nil {
+		log.Printf("清空SQL执行历史失败: %v", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "清空SQL执行历史失败")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "SQL执行历史已清空"})
+}
+
+// savedQueryRequest 创建或更新保存的查询的请求体
+type savedQueryRequest struct {
+	Name        string                  `json:"name"`
+	Description string                  `json:"description"`
+	Query       string                  `json:"query"`
+	Tags        []string                `json:"tags"`
+	Parameters  []models.QueryParameter `json:"parameters"`
+}
+
+// savedQueryResponse 保存的查询及其分享链接
+type savedQueryResponse struct {
+	models.SavedQuery
+	ShareURL string `json:"share_url"`
+}
+
+// shareURL 返回保存的查询的分享链接
+// SQL_SHARE_BASE_URL 为前端打开保存的查询的地址前缀，未设置时使用查询详情接口
+func shareURL(id string) string {
+	if base := os.Getenv("SQL_SHARE_BASE_URL"); base != "" {
+		return base + id
+	}
+	return "/api/sql/saved/" + id
+}
+
+func newSavedQueryResponse(q models.SavedQuery) savedQueryResponse {
+	return savedQueryResponse{SavedQuery: q, ShareURL: shareURL(q.ID)}
+}
+
+// GetSavedQuery 获取保存的查询，路径为 /api/sql/saved/{id}
+// 任何人都可以通过ID查看，用于分享链接；只有创建者可以修改和删除
+func (c *SQLController) GetSavedQuery(w http.ResponseWriter, r *http.Request) {
+	saved, ok := models.SavedQueries().Get(router.Param(r, "id"))
+	if !ok {
+		utils.RespondWithError(w, http.StatusNotFound, "保存的查询不存在")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, newSavedQueryResponse(saved))
+}
+
+// checkSavedQueryOwner 检查保存的查询存在且属于当前用户，否则返回404或403
+func (c *SQLController) checkSavedQueryOwner(w http.ResponseWriter, r *http.Request, id string) bool {
+	saved, ok := models.SavedQueries().Get(id)
+	if !ok {
+		utils.RespondWithError(w, http.StatusNotFound, "保存的查询不存在")
+		return false
+	}
+	if saved.Owner != requestUser(r) {
+		utils.RespondWithError(w, http.StatusForbidden, "只有创建者可以修改或删除保存的查询")
+		return false
+	}
+	return true
+}
+
+// ListSavedQueries 返回当前用户保存的查询，可用tag参数筛选
+func (c *SQLController) ListSavedQueries(w http.ResponseWriter, r *http.Request) {
+	queries := models.SavedQueries().List(requestUser(r), r.URL.Query().Get("tag"))
+	data := make([]savedQueryResponse, 0, len(queries))
+	for _, q := range queries {
+		data = append(data, newSavedQueryResponse(q))
+	}
+
+	utils.RespondWithPage(w, http.StatusOK, data, utils.NewPagination(len(data), 0, 0, len(data)))
+}
+
+// CreateSavedQuery 保存新的查询
+func (c *SQLController) CreateSavedQuery(w http.ResponseWriter, r *http.Request) {
+	saved, ok := c.decodeSavedQuery(w, r)
+	if !ok {
+		return
+	}
+	saved.Owner = requestUser(r)
+
+	created, err := models.SavedQueries().Create(saved)
+	if !c.checkSavedQueryError(w, err) {
+		return
+	}
+
+	log.Printf("保存了查询: %s (%s)", created.Name, created.ID)
+	utils.RespondWithJSON(w, http.StatusCreated, newSavedQueryResponse(created))
+}
+
+// UpdateSavedQuery 更新保存的查询
+func (c *SQLController) UpdateSavedQuery(w http.ResponseWriter, r *http.Request) {
+	id := router.Param(r, "id")
+	if !c.checkSavedQueryOwner(w, r, id) {
+		return
+	}
+
+	saved, ok := c.decodeSavedQuery(w, r)
+	if !ok {
+		return
+	}
+
+	updated, err := models.SavedQueries().Update(id, saved)
+	if !c.checkSavedQueryError(w, err) {
+		return
+	}
+
+	log.Printf("更新了保存的查询: %s (%s)", updated.Name, updated.ID)
+	utils.RespondWithJSON(w, http.StatusOK, newSavedQueryResponse(updated))
+}
+
+// DeleteSavedQuery 删除保存的查询
+func (c *SQLController) DeleteSavedQuery(w http.ResponseWriter, r *http.Request) {
+	id := router.Param(r, "id")
+	if !c.checkSavedQueryOwner(w, r, id) {
+		return
+	}
+
+	deleted, err := models.SavedQueries().Delete(id)
+	if err != nil {
+		log.Printf("删除保存的查询失败: %v", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "删除保存的查询失败")
+		return
+	}
+	if !deleted {
+		utils.RespondWithError(w, http.StatusNotFound, "保存的查询不存在")
+		return
+	}
+
+	log.Printf("删除了保存的查询: %s", id)
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "保存的查询已删除"})
+}
+
+// decodeSavedQuery 解析请求体，SQL需通过与ExecuteSQL相同的解析和访问策略检查
+func (c *SQLController) decodeSavedQuery(w http.ResponseWriter, r *http.Request) (models.SavedQuery, bool) {
+	var req savedQueryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "无效的请求数据")
+		return models.SavedQuery{}, false
+	}
+
+	saved := models.SavedQuery{
+		Name:        req.Name,
+		Description: req.Description,
+		Query:       req.Query,
+		Tags:        req.Tags,
+		Parameters:  req.Parameters,
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		return saved, true
+	}
+
+	types := make(map[string]string, len(req.Parameters))
+	for _, p := range req.Parameters {
+		types[p.Name] = p.Type
+	}
+	stmt, err := c.checkSQL(req.Query)
+	if err == nil {
+		// 参数类型只能是支持的类型，补上类型后再检查一次
+		_, err = c.checkSQL(stmt.BindTypes(types))
+	}
+	if err != nil {
+		respondSQLError(w, req.Query, err)
+		return saved, false
+	}
+	return saved, true
+}
+
+// checkSavedQueryError 校验失败返回400，details与参数校验的格式相同，持久化失败返回500
+func (c *SQLController) checkSavedQueryError(w http.ResponseWriter, err error) bool {
+	if err == nil {
+		return true
+	}
+
+	var savedErr *models.SavedQueryError
+	if errors.As(err, &savedErr) {
+		utils.RespondWithErrorCode(w, http.StatusBadRequest, utils.CodeInvalidParams, "保存的查询无效", []router.InvalidParam{
+			{In: router.InBody, Field: savedErr.Field, Reason: savedErr.Reason},
+		})
+		return false
+	}
+
+	log.Printf("保存查询失败: %v", err)
+	utils.RespondWithError(w, http.StatusInternalServerError, "保存查询失败")
+	return false
+}
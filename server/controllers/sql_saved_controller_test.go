@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"server/utils"
+)
+
+func TestListHistoryInvalidCursor(t *testing.T) {
+	c := NewSQLController()
+	tests := []string{
+		"cursor=bogus",
+		"cursor=" + utils.EncodeCursor(10) + "&offset=0",
+		"offset=-1",
+		"limit=0",
+	}
+	for _, query := range tests {
+		status, resp := serve(t, c.ListHistory, httptest.NewRequest(http.MethodGet, "/api/sql/history?"+query, nil))
+		if status != http.StatusBadRequest {
+			t.Errorf("%s: 状态码为%d，错误%+v，期望400", query, status, resp.Error)
+		}
+	}
+}
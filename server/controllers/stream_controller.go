@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"server/database"
+	"server/models"
+	"server/utils"
+)
+
+// WebSocket连接配置
+const (
+	wsWriteTimeout    = 10 * time.Second
+	wsPongTimeout     = 60 * time.Second
+	wsPingInterval    = 25 * time.Second
+	wsMaxMessageSize  = 64 * 1024
+	wsMaxPausedBuffer = 5000 // 暂停期间最多缓存的记录数
+)
+
+// StreamController 处理WebSocket实时日志推送
+type StreamController struct {
+	hub      *models.TailHub
+	upgrader websocket.Upgrader
+}
+
+// NewStreamController 创建一个新的实时推送控制器，新日志从store轮询
+func NewStreamController(store models.LogStore) *StreamController {
+	return &StreamController{
+		hub: models.NewTailHub(store, tailDefaultInterval),
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  4096,
+			WriteBufferSize: 4096,
+			// 与CORS中间件保持一致，允许所有来源
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// streamMessage 客户端发送的控制消息
+// type取值: subscribe, update_filter, pause, resume
+type streamMessage struct {
+	Type   string                 `json:"type"`
+	Filter *database.QueryOptions `json:"filter,omitempty"`
+}
+
+// streamFrame 服务端推送的消息
+type streamFrame struct {
+	Type      string             `json:"type"`
+	Data      []models.KV7Record `json:"data,omitempty"`
+	FilterKey string             `json:"filter_key,omitempty"`
+	Dropped   int64              `json:"dropped,omitempty"`
+	Error     *utils.Error       `json:"error,omitempty"`
+}
+
+// TailWebSocket 通过WebSocket推送实时日志
+// 客户端可以在连接期间随时更换筛选条件、暂停或恢复推送，无需重新连接
+func (c *StreamController) TailWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := c.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("WebSocket升级失败: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	// 读取客户端消息的goroutine，连接关闭时关闭messages
+	messages := make(chan streamMessage)
+	go func() {
+		defer close(messages)
+		conn.SetReadLimit(wsMaxMessageSize)
+		conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		})
+		for {
+			var msg streamMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					log.Printf("读取WebSocket消息失败: %v", err)
+				}
+				return
+			}
+			select {
+			case messages <- msg:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	send := func(frame streamFrame) bool {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		if err := conn.WriteJSON(frame); err != nil {
+			log.Printf("发送WebSocket消息失败: %v", err)
+			return false
+		}
+		return true
+	}
+
+	var sub *models.TailSubscription
+	var subC chan []models.KV7Record
+	defer func() {
+		if sub != nil {
+			c.hub.Unsubscribe(sub)
+		}
+	}()
+
+	paused := false
+	var pausedBuffer []models.KV7Record
+	var pausedDropped int64
+
+	pingTicker := time.NewTicker(wsPingInterval)
+	defer pingTicker.Stop()
+
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+
+			switch msg.Type {
+			case "subscribe", "update_filter":
+				if msg.Filter == nil {
+					if !send(streamFrame{Type: "error", Error: utils.NewError(w, utils.CodeInvalidParams, "缺少filter参数", nil)}) {
+						return
+					}
+					continue
+				}
+				if msg.Filter.Query != "" {
+					if _, _, err := models.CompileLogQuery(msg.Filter.Query, msg.Filter.AppID); err != nil {
+						if !send(streamFrame{Type: "error", Error: utils.NewError(w, utils.CodeInvalidQuery, err.Error(), nil)}) {
+							return
+						}
+						continue
+					}
+				}
+				// 更换筛选条件时先退出旧的轮询，再加入新的
+				if sub != nil {
+					c.hub.Unsubscribe(sub)
+				}
+				sub = c.hub.Subscribe(*msg.Filter)
+				subC = sub.C
+				pausedBuffer = nil
+				if !send(streamFrame{Type: "subscribed", FilterKey: sub.Key}) {
+					return
+				}
+			case "pause":
+				paused = true
+				if !send(streamFrame{Type: "paused"}) {
+					return
+				}
+			case "resume":
+				paused = false
+				frame := streamFrame{Type: "resumed", Data: pausedBuffer, Dropped: pausedDropped}
+				pausedBuffer = nil
+				pausedDropped = 0
+				if !send(frame) {
+					return
+				}
+			default:
+				if !send(streamFrame{Type: "error", Error: utils.NewError(w, utils.CodeBadRequest, "未知的消息类型: "+msg.Type, nil)}) {
+					return
+				}
+			}
+
+		case records := <-subC:
+			if paused {
+				// 暂停期间缓存记录，超出上限时丢弃最早的记录
+				pausedBuffer = append(pausedBuffer, records...)
+				if over := len(pausedBuffer) - wsMaxPausedBuffer; over > 0 {
+					pausedBuffer = pausedBuffer[over:]
+					pausedDropped += int64(over)
+				}
+				continue
+			}
+			if !send(streamFrame{Type: "logs", Data: records, Dropped: sub.Dropped()}) {
+				return
+			}
+
+		case <-pingTicker.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// GetStreamStats 获取实时推送的轮询与订阅统计
+func (c *StreamController) GetStreamStats(w http.ResponseWriter, r *http.Request) {
+	utils.RespondWithJSON(w, http.StatusOK, c.hub.Stats())
+}
@@ -25,20 +25,22 @@ var db *sql.DB
 
 // QueryOptions 查询选项
 type QueryOptions struct {
-	StartTime time.Time
-	EndTime   time.Time
-	Limit     int
-	Offset    int
-	Platform  string
-	OS        string
-	UserID    string
-	Category  string
-	Action    string
-	AppID     string
-	Version   string
-	SortBy    string
-	SortOrder string
-	Filter    map[string]interface{}
+	StartTime time.Time              `json:"start_time"`
+	EndTime   time.Time              `json:"end_time"`
+	Limit     int                    `json:"limit,omitempty"`
+	Offset    int                    `json:"offset,omitempty"`
+	Platform  string                 `json:"platform,omitempty"`
+	OS        string                 `json:"os,omitempty"`
+	UserID    string                 `json:"user_id,omitempty"`
+	Category  string                 `json:"category,omitempty"`
+	Level     string                 `json:"level,omitempty"`
+	Action    string                 `json:"action,omitempty"`
+	AppID     string                 `json:"app_id,omitempty"`
+	Version   string                 `json:"version,omitempty"`
+	SortBy    string                 `json:"sort_by,omitempty"`
+	SortOrder string                 `json:"sort_order,omitempty"`
+	Filter    map[string]interface{} `json:"filter,omitempty"`
+	Query     string                 `json:"q,omitempty"` // 日志查询语言表达式
 }
 
 // DefaultQueryOptions 返回默认查询选项
@@ -103,8 +105,11 @@ func InitClickHouse() (*sql.DB, error) {
 	return db, nil
 }
 
-// GetDB 获取数据库连接
+// GetDB 获取数据库连接，数据源不是clickhouse时返回nil
 func GetDB() *sql.DB {
+	if source != SourceClickHouse {
+		return nil
+	}
 	if db == nil {
 		// 如果连接未初始化，尝试初始化
 		conn, err := InitClickHouse()
@@ -132,11 +137,12 @@ func GetDB() *sql.DB {
 	return db
 }
 
-// QueryLogs 从ClickHouse中查询日志数据
-func QueryLogs(ctx context.Context, table string, limit int) ([]map[string]interface{}, error) {
-	conn := GetDB()
-	if conn == nil {
-		return nil, fmt.Errorf("无法获取数据库连接")
+// QueryLogs 从ClickHouse中查询日志数据，返回的结果集由调用方逐行读取并关闭
+// table直接拼接到SQL中，必须是经过sqlparse.Policy.CheckTableName检查并引用的表名
+func QueryLogs(ctx context.Context, table string, limit int) (*Rows, error) {
+	conn, err := Conn()
+	if err != nil {
+		return nil, err
 	}
 
 	// 构建简单查询
@@ -148,55 +154,15 @@ func QueryLogs(ctx context.Context, table string, limit int) ([]map[string]inter
 	if err != nil {
 		return nil, fmt.Errorf("查询执行失败: %w", err)
 	}
-	defer rows.Close()
-
-	// 获取列信息
-	columns, err := rows.Columns()
-	if err != nil {
-		return nil, fmt.Errorf("获取列信息失败: %w", err)
-	}
-
-	// 准备结果
-	var results []map[string]interface{}
-
-	// 遍历结果集
-	for rows.Next() {
-		// 创建扫描目标
-		values := make([]interface{}, len(columns))
-		valuePtrs := make([]interface{}, len(columns))
-		for i := range values {
-			valuePtrs[i] = &values[i]
-		}
-
-		// 扫描行数据
-		if err := rows.Scan(valuePtrs...); err != nil {
-			return nil, fmt.Errorf("扫描行数据失败: %w", err)
-		}
-
-		// 创建行映射
-		row := make(map[string]interface{})
-		for i, col := range columns {
-			val := values[i]
-			row[col] = val
-		}
-
-		results = append(results, row)
-	}
-
-	// 检查遍历错误
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("遍历结果集失败: %w", err)
-	}
-
-	log.Printf("查询成功，返回 %d 条记录", len(results))
-	return results, nil
+	return rows, nil
 }
 
-// QueryKV7WithFilters 从kv_7表查询数据，支持时间范围过滤和获取总数
-func QueryKV7WithFilters(ctx context.Context, limit int, offset int, needCount bool, startTime string, endTime string) ([]map[string]interface{}, int, error) {
-	conn := GetDB()
-	if conn == nil {
-		return nil, 0, fmt.Errorf("无法获取数据库连接")
+// QueryKV7WithFilters 从kv_7表查询一页数据，支持时间范围过滤和获取总数
+// 返回的结果集由调用方逐行读取并关闭，needCount为false时总数为0
+func QueryKV7WithFilters(ctx context.Context, limit int, offset int, needCount bool, startTime string, endTime string) (*Rows, int, error) {
+	conn, err := Conn()
+	if err != nil {
+		return nil, 0, err
 	}
 
 	// 构建WHERE子句
@@ -226,8 +192,7 @@ func QueryKV7WithFilters(ctx context.Context, limit int, offset int, needCount b
 		// 执行COUNT查询
 		row := conn.QueryRowContext(ctx, countQuery, whereParams...)
 		if err := row.Scan(&totalCount); err != nil {
-			log.Printf("获取kv_7表总记录数失败: %v", err)
-			totalCount = 0 // 失败时使用0作为默认值
+			return nil, 0, fmt.Errorf("获取kv_7表总记录数失败: %w", err)
 		}
 
 		log.Printf("总记录数查询: %s, 参数: %v, 结果: %d", countQuery, whereParams, totalCount)
@@ -246,55 +211,7 @@ func QueryKV7WithFilters(ctx context.Context, limit int, offset int, needCount b
 	if err != nil {
 		return nil, 0, fmt.Errorf("查询执行失败: %w", err)
 	}
-	defer rows.Close()
-
-	// 获取列信息
-	columns, err := rows.Columns()
-	if err != nil {
-		return nil, 0, fmt.Errorf("获取列信息失败: %w", err)
-	}
-
-	// 准备结果
-	var results []map[string]interface{}
-
-	// 遍历结果集
-	for rows.Next() {
-		// 创建扫描目标
-		values := make([]interface{}, len(columns))
-		valuePtrs := make([]interface{}, len(columns))
-		for i := range values {
-			valuePtrs[i] = &values[i]
-		}
-
-		// 扫描行数据
-		if err := rows.Scan(valuePtrs...); err != nil {
-			return nil, 0, fmt.Errorf("扫描行数据失败: %w", err)
-		}
-
-		// 创建行映射
-		row := make(map[string]interface{})
-		for i, col := range columns {
-			val := values[i]
-
-			// 处理不同类型的数据
-			switch v := val.(type) {
-			case []byte:
-				row[col] = string(v)
-			default:
-				row[col] = v
-			}
-		}
-
-		results = append(results, row)
-	}
-
-	// 检查遍历错误
-	if err := rows.Err(); err != nil {
-		return nil, 0, fmt.Errorf("遍历结果集失败: %w", err)
-	}
-
-	log.Printf("查询kv_7成功，返回 %d 条记录，总数 %d", len(results), totalCount)
-	return results, totalCount, nil
+	return rows, totalCount, nil
 }
 
 // getClickHouseConfig 从环境变量获取ClickHouse配置
@@ -315,6 +232,11 @@ func getClickHouseConfig() ClickHouseConfig {
 	}
 }
 
+// LogsTable 返回日志表所在的数据库和表名，可通过CLICKHOUSE_DATABASE和LOGS_TABLE配置
+func LogsTable() (string, string) {
+	return getEnv("CLICKHOUSE_DATABASE", "test_db"), getEnv("LOGS_TABLE", "kv_7")
+}
+
 // getEnv 获取环境变量，如果不存在则返回默认值
 func getEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
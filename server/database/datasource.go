@@ -0,0 +1,82 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// Source 数据源模式，由DATA_SOURCE环境变量选择
+type Source string
+
+const (
+	// SourceClickHouse 查询ClickHouse，出错时返回错误，不降级为模拟数据
+	SourceClickHouse Source = "clickhouse"
+	// SourceMock 随机生成的模拟数据，不连接ClickHouse
+	SourceMock Source = "mock"
+	// SourceFixture 固定的测试数据，每次返回相同的结果，不连接ClickHouse
+	SourceFixture Source = "fixture"
+)
+
+var (
+	// ErrUnavailable ClickHouse数据源无法建立连接
+	ErrUnavailable = errors.New("无法获取数据库连接")
+	// ErrUnsupported 当前数据源不提供该数据，如mock模式下需要ClickHouse的接口
+	ErrUnsupported = errors.New("当前数据源不支持该操作")
+)
+
+// source 当前数据源，启动时由InitSource设置
+var source = SourceClickHouse
+
+// ParseSource 解析数据源名称，不区分大小写
+func ParseSource(s string) (Source, error) {
+	switch Source(strings.ToLower(strings.TrimSpace(s))) {
+	case SourceClickHouse:
+		return SourceClickHouse, nil
+	case SourceMock:
+		return SourceMock, nil
+	case SourceFixture:
+		return SourceFixture, nil
+	}
+	return "", fmt.Errorf("不支持的数据源%q，可选clickhouse、mock、fixture", s)
+}
+
+// InitSource 读取DATA_SOURCE设置当前数据源，未设置时使用clickhouse
+// 兼容旧的USE_REAL_DATABASE=false，等同于DATA_SOURCE=mock
+func InitSource() (Source, error) {
+	value := os.Getenv("DATA_SOURCE")
+	if value == "" {
+		if os.Getenv("USE_REAL_DATABASE") == "false" {
+			log.Println("USE_REAL_DATABASE已弃用，请改用DATA_SOURCE=mock")
+			source = SourceMock
+		}
+		return source, nil
+	}
+
+	s, err := ParseSource(value)
+	if err != nil {
+		return "", err
+	}
+	source = s
+	return source, nil
+}
+
+// CurrentSource 返回当前数据源
+func CurrentSource() Source {
+	return source
+}
+
+// Conn 返回ClickHouse连接
+// 当前数据源不是clickhouse时返回ErrUnsupported，无法连接时返回ErrUnavailable
+func Conn() (*ClickHouseDB, error) {
+	if source != SourceClickHouse {
+		return nil, fmt.Errorf("%w: DATA_SOURCE=%s", ErrUnsupported, source)
+	}
+	conn := GetClickHouseConn()
+	if conn == nil {
+		return nil, ErrUnavailable
+	}
+	return conn, nil
+}
@@ -0,0 +1,480 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// IngestQueueConfig 异步写入队列配置
+type IngestQueueConfig struct {
+	MaxRecords    int           // 单批最大记录数
+	MaxBytes      int           // 单批最大字节数（按JSON编码计算）
+	MaxAge        time.Duration // 批次最长等待时间
+	RetryInterval time.Duration // 回放落盘批次的重试间隔
+	WriteTimeout  time.Duration // 单批写入超时
+	SpoolDir      string        // 落盘目录
+}
+
+// DefaultIngestQueueConfig 从环境变量读取写入队列配置
+func DefaultIngestQueueConfig() IngestQueueConfig {
+	return IngestQueueConfig{
+		MaxRecords:    getEnvInt("INGEST_BATCH_SIZE", 5000),
+		MaxBytes:      getEnvInt("INGEST_BATCH_BYTES", 8<<20),
+		MaxAge:        time.Duration(getEnvInt("INGEST_BATCH_AGE_MS", 2000)) * time.Millisecond,
+		RetryInterval: time.Duration(getEnvInt("INGEST_RETRY_INTERVAL_MS", 5000)) * time.Millisecond,
+		WriteTimeout:  30 * time.Second,
+		SpoolDir:      getEnv("INGEST_SPOOL_DIR", "data/spool"),
+	}
+}
+
+var (
+	// ErrQueueClosed 队列已停止，不再接受新记录
+	ErrQueueClosed = errors.New("写入队列已关闭")
+	// ErrBatchRejected 批次中的数据无法写入，重试也不会成功，如无法解码或字段值无法转换
+	ErrBatchRejected = errors.New("批次数据被拒绝")
+)
+
+// rejectedExceptionCodes ClickHouse因数据本身拒绝写入的错误码，这类批次重试也不会成功
+// 表不存在、权限、超时、内存不足等错误在修复配置或恢复后可以写入，不在此列
+var rejectedExceptionCodes = map[int32]bool{
+	6:   true, // CANNOT_PARSE_TEXT
+	26:  true, // CANNOT_PARSE_QUOTED_STRING
+	27:  true, // CANNOT_PARSE_INPUT_ASSERTION_FAILED
+	38:  true, // CANNOT_PARSE_DATE
+	41:  true, // CANNOT_PARSE_DATETIME
+	53:  true, // TYPE_MISMATCH
+	69:  true, // ARGUMENT_OUT_OF_BOUND
+	70:  true, // CANNOT_CONVERT_TYPE
+	72:  true, // CANNOT_PARSE_NUMBER
+	117: true, // INCORRECT_DATA
+	131: true, // TOO_LARGE_STRING_SIZE
+	321: true, // VALUE_IS_OUT_OF_RANGE_OF_DATA_TYPE
+	349: true, // CANNOT_INSERT_NULL_IN_ORDINARY_COLUMN
+}
+
+// isRejected 判断批次写入失败是否由数据本身导致，这类批次转入死信文件而不是反复重试
+func isRejected(err error) bool {
+	var exception *clickhouse.Exception
+	if errors.As(err, &exception) {
+		return rejectedExceptionCodes[exception.Code]
+	}
+	return errors.Is(err, ErrBatchRejected)
+}
+
+// IngestBatch 表示一个待写入的批次，ID在落盘和回放过程中保持不变
+type IngestBatch struct {
+	ID      string            `json:"id"`
+	Records []json.RawMessage `json:"records"`
+}
+
+// BatchWriter 将一个批次写入ClickHouse
+type BatchWriter func(ctx context.Context, batch *IngestBatch) error
+
+// spoolEntry 落盘文件中的一行，批次写入成功或转入死信文件后追加ack行
+type spoolEntry struct {
+	Type  string       `json:"type"`
+	ID    string       `json:"id"`
+	Batch *IngestBatch `json:"batch,omitempty"`
+}
+
+// deadLetterEntry 死信文件中的一行，保留被拒绝的批次和原因，供人工排查后重新写入
+type deadLetterEntry struct {
+	ID       string       `json:"id"`
+	Error    string       `json:"error"`
+	FailedAt time.Time    `json:"failed_at"`
+	Batch    *IngestBatch `json:"batch"`
+}
+
+// IngestQueue 带落盘缓冲的异步写入队列
+// 记录先在内存中按数量、字节数和时间攒批，写入失败的批次追加到本地spool文件，
+// 连接恢复后按顺序回放。批次ID作为insert_deduplication_token传给ClickHouse，
+// 因此崩溃后重复回放同一批次不会产生重复数据。
+// 数据本身被拒绝的批次（见isRejected）写入死信文件ingest.deadletter，不阻塞后续批次
+type IngestQueue struct {
+	config IngestQueueConfig
+	write  BatchWriter
+
+	mu           sync.Mutex
+	pending      []json.RawMessage
+	pendingBytes int
+	pendingSince time.Time
+	seq          int64
+	closed       bool
+
+	spoolMu        sync.Mutex
+	spoolPath      string
+	spoolFile      *os.File
+	spoolCount     int // 尚未确认的落盘批次数
+	deadLetterPath string
+	deadLetters    int // 本次运行转入死信文件的批次数
+
+	flushCh chan struct{}
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+}
+
+// NewIngestQueue 创建写入队列并加载未回放的落盘批次
+func NewIngestQueue(config IngestQueueConfig, write BatchWriter) (*IngestQueue, error) {
+	if err := os.MkdirAll(config.SpoolDir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建落盘目录失败: %w", err)
+	}
+
+	q := &IngestQueue{
+		config:         config,
+		write:          write,
+		spoolPath:      filepath.Join(config.SpoolDir, "ingest.spool"),
+		deadLetterPath: filepath.Join(config.SpoolDir, "ingest.deadletter"),
+		flushCh:        make(chan struct{}, 1),
+		stopCh:         make(chan struct{}),
+		doneCh:         make(chan struct{}),
+	}
+
+	batches, err := q.readSpool()
+	if err != nil {
+		return nil, err
+	}
+	q.spoolCount = len(batches)
+
+	q.spoolFile, err = os.OpenFile(q.spoolPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("打开落盘文件失败: %w", err)
+	}
+
+	if q.spoolCount > 0 {
+		log.Printf("发现 %d 个待回放的落盘批次", q.spoolCount)
+	}
+
+	go q.run()
+	return q, nil
+}
+
+// Enqueue 将记录加入队列，全部加入或全部不加入；队列已关闭时返回ErrQueueClosed
+func (q *IngestQueue) Enqueue(records ...interface{}) error {
+	encoded := make([]json.RawMessage, len(records))
+	size := 0
+	for i, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("编码记录失败: %w", err)
+		}
+		encoded[i] = data
+		size += len(data)
+	}
+
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return ErrQueueClosed
+	}
+	if len(q.pending) == 0 {
+		q.pendingSince = time.Now()
+	}
+	q.pending = append(q.pending, encoded...)
+	q.pendingBytes += size
+	full := len(q.pending) >= q.config.MaxRecords || q.pendingBytes >= q.config.MaxBytes
+	q.mu.Unlock()
+
+	if full {
+		select {
+		case q.flushCh <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Stats 返回队列当前状态
+func (q *IngestQueue) Stats() map[string]interface{} {
+	q.mu.Lock()
+	pending := len(q.pending)
+	q.mu.Unlock()
+
+	q.spoolMu.Lock()
+	spooled := q.spoolCount
+	deadLetters := q.deadLetters
+	q.spoolMu.Unlock()
+
+	return map[string]interface{}{
+		"pending_records":     pending,
+		"spooled_batches":     spooled,
+		"dead_letter_batches": deadLetters,
+	}
+}
+
+// Close 停止接受新记录，剩余记录写入ClickHouse或落盘
+// 调用前应先停止HTTP服务，等待处理中的请求完成
+func (q *IngestQueue) Close() error {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+
+	close(q.stopCh)
+	<-q.doneCh
+
+	q.spoolMu.Lock()
+	defer q.spoolMu.Unlock()
+	return q.spoolFile.Close()
+}
+
+// run 后台攒批和回放循环
+func (q *IngestQueue) run() {
+	defer close(q.doneCh)
+
+	ageTicker := time.NewTicker(q.config.MaxAge / 2)
+	defer ageTicker.Stop()
+	retryTicker := time.NewTicker(q.config.RetryInterval)
+	defer retryTicker.Stop()
+
+	for {
+		select {
+		case <-q.flushCh:
+			q.flush(false)
+		case <-ageTicker.C:
+			q.flush(true)
+		case <-retryTicker.C:
+			q.replay()
+		case <-q.stopCh:
+			q.flush(false)
+			return
+		}
+	}
+}
+
+// cut 取出当前攒好的批次，onlyExpired为true时只取出超过MaxAge的批次
+func (q *IngestQueue) cut(onlyExpired bool) *IngestBatch {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending) == 0 {
+		return nil
+	}
+	if onlyExpired && time.Since(q.pendingSince) < q.config.MaxAge {
+		return nil
+	}
+
+	// 按数量和字节上限截取，至少包含一条记录
+	n, size := 0, 0
+	for n < len(q.pending) && n < q.config.MaxRecords {
+		if n > 0 && size+len(q.pending[n]) > q.config.MaxBytes {
+			break
+		}
+		size += len(q.pending[n])
+		n++
+	}
+
+	q.seq++
+	batch := &IngestBatch{
+		ID:      fmt.Sprintf("%d-%d", time.Now().UnixNano(), q.seq),
+		Records: q.pending[:n:n],
+	}
+	q.pending = q.pending[n:]
+	q.pendingBytes -= size
+	if len(q.pending) > 0 {
+		q.pendingSince = time.Now()
+	}
+	return batch
+}
+
+// flush 写入当前批次，失败或存在更早的落盘批次时追加到spool保证顺序
+func (q *IngestQueue) flush(onlyExpired bool) {
+	for {
+		batch := q.cut(onlyExpired)
+		if batch == nil {
+			return
+		}
+
+		q.spoolMu.Lock()
+		hasSpool := q.spoolCount > 0
+		q.spoolMu.Unlock()
+
+		if hasSpool {
+			q.spool(batch)
+			q.replay()
+		} else if err := q.writeBatch(batch); err != nil {
+			if isRejected(err) {
+				q.spoolMu.Lock()
+				err = q.deadLetter(batch, err)
+				q.spoolMu.Unlock()
+			}
+			if err != nil {
+				log.Printf("批次 %s 写入失败，落盘等待回放: %v", batch.ID, err)
+				q.spool(batch)
+			}
+		}
+
+		if onlyExpired {
+			return
+		}
+	}
+}
+
+// writeBatch 带去重令牌写入一个批次
+func (q *IngestQueue) writeBatch(batch *IngestBatch) error {
+	ctx, cancel := context.WithTimeout(context.Background(), q.config.WriteTimeout)
+	defer cancel()
+	return q.write(WithDeduplicationToken(ctx, batch.ID), batch)
+}
+
+// spool 将批次追加到落盘文件
+func (q *IngestQueue) spool(batch *IngestBatch) {
+	q.spoolMu.Lock()
+	defer q.spoolMu.Unlock()
+
+	if err := q.appendSpool(spoolEntry{Type: "batch", ID: batch.ID, Batch: batch}); err != nil {
+		// 落盘失败时记录无法保留，只能记录日志
+		log.Printf("批次 %s 落盘失败，%d 条记录丢失: %v", batch.ID, len(batch.Records), err)
+		return
+	}
+	q.spoolCount++
+}
+
+// replay 按顺序回放落盘批次，被拒绝的批次转入死信文件后继续，遇到其他失败即停止，等待下次重试
+func (q *IngestQueue) replay() {
+	q.spoolMu.Lock()
+	defer q.spoolMu.Unlock()
+
+	if q.spoolCount == 0 {
+		return
+	}
+
+	batches, err := q.readSpool()
+	if err != nil {
+		log.Printf("读取落盘文件失败: %v", err)
+		return
+	}
+
+	for i, batch := range batches {
+		err := q.writeBatch(batch)
+		if err != nil && isRejected(err) {
+			if dlErr := q.deadLetter(batch, err); dlErr != nil {
+				log.Printf("批次 %s 写入死信文件失败，稍后重试: %v", batch.ID, dlErr)
+				q.spoolCount = len(batches) - i
+				return
+			}
+		} else if err != nil {
+			log.Printf("回放批次 %s 失败，稍后重试: %v", batch.ID, err)
+			q.spoolCount = len(batches) - i
+			return
+		} else {
+			log.Printf("回放批次 %s 成功，%d 条记录", batch.ID, len(batch.Records))
+		}
+		if err := q.appendSpool(spoolEntry{Type: "ack", ID: batch.ID}); err != nil {
+			log.Printf("记录批次 %s 确认失败: %v", batch.ID, err)
+		}
+	}
+
+	// 全部回放完成后清空落盘文件
+	if err := q.spoolFile.Truncate(0); err != nil {
+		log.Printf("清空落盘文件失败: %v", err)
+	}
+	q.spoolCount = 0
+}
+
+// deadLetter 将被拒绝的批次追加到死信文件并同步到磁盘，调用方需持有spoolMu
+func (q *IngestQueue) deadLetter(batch *IngestBatch, reason error) error {
+	data, err := json.Marshal(deadLetterEntry{ID: batch.ID, Error: reason.Error(), FailedAt: time.Now(), Batch: batch})
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(q.deadLetterPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+
+	q.deadLetters++
+	log.Printf("批次 %s 被拒绝，%d 条记录转入死信文件 %s: %v", batch.ID, len(batch.Records), q.deadLetterPath, reason)
+	return nil
+}
+
+// appendSpool 追加一行到落盘文件并同步到磁盘，调用方需持有spoolMu
+func (q *IngestQueue) appendSpool(entry spoolEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if _, err := q.spoolFile.Write(data); err != nil {
+		return err
+	}
+	return q.spoolFile.Sync()
+}
+
+// readSpool 读取落盘文件中尚未确认的批次，保持写入顺序
+func (q *IngestQueue) readSpool() ([]*IngestBatch, error) {
+	file, err := os.Open(q.spoolPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("打开落盘文件失败: %w", err)
+	}
+	defer file.Close()
+
+	var order []string
+	batches := make(map[string]*IngestBatch)
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			var entry spoolEntry
+			if jsonErr := json.Unmarshal(line, &entry); jsonErr != nil {
+				log.Printf("跳过无法解析的落盘记录: %v", jsonErr)
+			} else if entry.Type == "batch" && entry.Batch != nil {
+				if _, exists := batches[entry.ID]; !exists {
+					order = append(order, entry.ID)
+				}
+				batches[entry.ID] = entry.Batch
+			} else if entry.Type == "ack" {
+				delete(batches, entry.ID)
+			}
+		}
+		// 末尾不完整的行是写入中途崩溃留下的，直接忽略
+		if err != nil {
+			break
+		}
+	}
+
+	result := make([]*IngestBatch, 0, len(batches))
+	for _, id := range order {
+		if batch, ok := batches[id]; ok {
+			result = append(result, batch)
+			delete(batches, id)
+		}
+	}
+	return result, nil
+}
+
+// WithDeduplicationToken 为写入设置insert_deduplication_token，相同令牌的重复写入会被ClickHouse忽略
+func WithDeduplicationToken(ctx context.Context, token string) context.Context {
+	return clickhouse.Context(ctx, clickhouse.WithSettings(clickhouse.Settings{
+		"insert_deduplication_token": token,
+	}))
+}
+
+// getEnvInt 获取整数环境变量，解析失败时返回默认值
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// fakeWriter 记录写入成功的记录ID，down为true时模拟连接失败，id为bad的记录被拒绝
+type fakeWriter struct {
+	mu      sync.Mutex
+	down    bool
+	written []string
+}
+
+func (f *fakeWriter) write(ctx context.Context, batch *IngestBatch) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return errors.New("连接失败")
+	}
+	var ids []string
+	for _, raw := range batch.Records {
+		var record struct{ ID string }
+		if err := json.Unmarshal(raw, &record); err != nil {
+			return fmt.Errorf("%w: %v", ErrBatchRejected, err)
+		}
+		if record.ID == "bad" {
+			return fmt.Errorf("%w: 记录%s无法写入", ErrBatchRejected, record.ID)
+		}
+		ids = append(ids, record.ID)
+	}
+	f.written = append(f.written, ids...)
+	return nil
+}
+
+func (f *fakeWriter) setDown(down bool) {
+	f.mu.Lock()
+	f.down = down
+	f.mu.Unlock()
+}
+
+func newTestQueue(t *testing.T, writer *fakeWriter) *IngestQueue {
+	t.Helper()
+	q, err := NewIngestQueue(IngestQueueConfig{
+		MaxRecords:    1,
+		MaxBytes:      1 << 20,
+		MaxAge:        time.Hour,
+		RetryInterval: time.Hour,
+		WriteTimeout:  time.Second,
+		SpoolDir:      t.TempDir(),
+	}, writer.write)
+	if err != nil {
+		t.Fatalf("创建队列失败: %v", err)
+	}
+	return q
+}
+
+// waitSpooled 等待后台flush把批次落盘
+func waitSpooled(t *testing.T, q *IngestQueue, want int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for q.Stats()["spooled_batches"] != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("落盘批次数为%v，期望%d", q.Stats()["spooled_batches"], want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReplayMovesRejectedBatchToDeadLetter(t *testing.T) {
+	writer := &fakeWriter{down: true}
+	q := newTestQueue(t, writer)
+	defer q.Close()
+
+	for i, id := range []string{"r1", "bad", "r3"} {
+		if err := q.Enqueue(map[string]string{"id": id}); err != nil {
+			t.Fatalf("加入队列失败: %v", err)
+		}
+		waitSpooled(t, q, i+1)
+	}
+
+	writer.setDown(false)
+	q.replay()
+
+	if got := strings.Join(writer.written, ","); got != "r1,r3" {
+		t.Errorf("回放写入%s，期望r1,r3", got)
+	}
+	stats := q.Stats()
+	if stats["spooled_batches"] != 0 || stats["dead_letter_batches"] != 1 {
+		t.Errorf("队列状态为%v，期望落盘0批、死信1批", stats)
+	}
+
+	data, err := os.ReadFile(q.deadLetterPath)
+	if err != nil {
+		t.Fatalf("读取死信文件失败: %v", err)
+	}
+	var entry deadLetterEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("解析死信文件失败: %v", err)
+	}
+	if len(entry.Batch.Records) != 1 || !strings.Contains(string(entry.Batch.Records[0]), "bad") {
+		t.Errorf("死信批次为%s，期望包含bad记录", data)
+	}
+
+	// 再次回放时被拒绝的批次已确认，不会重复写入死信文件
+	batches, err := q.readSpool()
+	if err != nil || len(batches) != 0 {
+		t.Errorf("落盘文件中剩余%d个批次(%v)，期望0", len(batches), err)
+	}
+}
+
+func TestEnqueueAfterClose(t *testing.T) {
+	writer := &fakeWriter{}
+	q := newTestQueue(t, writer)
+
+	if err := q.Enqueue(map[string]string{"id": "r1"}, map[string]string{"id": "r2"}); err != nil {
+		t.Fatalf("加入队列失败: %v", err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatalf("关闭队列失败: %v", err)
+	}
+	if got := strings.Join(writer.written, ","); got != "r1,r2" {
+		t.Errorf("关闭时写入%s，期望r1,r2", got)
+	}
+	if err := q.Enqueue(map[string]string{"id": "r3"}); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("关闭后加入队列返回%v，期望ErrQueueClosed", err)
+	}
+}
+
+func TestIsRejected(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{fmt.Errorf("%w: 解码失败", ErrBatchRejected), true},
+		{fmt.Errorf("提交批量写入失败: %w", &clickhouse.Exception{Code: 53, Name: "TYPE_MISMATCH"}), true},
+		{&clickhouse.Exception{Code: 60, Name: "UNKNOWN_TABLE"}, false},
+		{&clickhouse.Exception{Code: 241, Name: "MEMORY_LIMIT_EXCEEDED"}, false},
+		{context.DeadlineExceeded, false},
+		{errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		if got := isRejected(tt.err); got != tt.want {
+			t.Errorf("isRejected(%v) = %v，期望%v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+
+	"server/utils"
+)
+
+// RunningQuery 正在执行的查询
+type RunningQuery struct {
+	ID        string    `json:"id"`
+	SQL       string    `json:"sql"`
+	User      string    `json:"user"`
+	StartedAt time.Time `json:"started_at"`
+	// Elapsed 已执行的秒数，列出时计算
+	Elapsed float64 `json:"elapsed"`
+
+	cancel context.CancelFunc
+}
+
+// queryRegistry 进程内正在执行的查询，按query_id索引
+type queryRegistry struct {
+	mu      sync.Mutex
+	queries map[string]*RunningQuery
+}
+
+var runningQueries = &queryRegistry{queries: make(map[string]*RunningQuery)}
+
+type queryUserKey struct{}
+
+// WithQueryUser 在context中记录发起查询的用户，显示在查询列表中
+func WithQueryUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, queryUserKey{}, user)
+}
+
+// QueryUser 返回context中记录的用户
+func QueryUser(ctx context.Context) string {
+	user, _ := ctx.Value(queryUserKey{}).(string)
+	return user
+}
+
+// RunningQueries 按开始时间返回正在执行的查询
+func RunningQueries() []RunningQuery {
+	runningQueries.mu.Lock()
+	defer runningQueries.mu.Unlock()
+
+	now := time.Now()
+	queries := make([]RunningQuery, 0, len(runningQueries.queries))
+	for _, q := range runningQueries.queries {
+		copied := *q
+		copied.Elapsed = now.Sub(q.StartedAt).Seconds()
+		copied.cancel = nil
+		queries = append(queries, copied)
+	}
+	sort.Slice(queries, func(i, j int) bool {
+		return queries[i].StartedAt.Before(queries[j].StartedAt)
+	})
+	return queries
+}
+
+// KillQuery 终止正在执行的查询：在ClickHouse上执行KILL QUERY，并取消本地的context
+// 查询不在列表中时返回false
+func KillQuery(ctx context.Context, id string) (bool, error) {
+	runningQueries.mu.Lock()
+	q, ok := runningQueries.queries[id]
+	runningQueries.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+
+	// 本地取消只中断当前连接上的读取，KILL QUERY确保服务端停止执行
+	var killErr error
+	if db != nil {
+		if _, err := db.ExecContext(ctx, "KILL QUERY WHERE query_id = ?", id); err != nil {
+			killErr = fmt.Errorf("执行KILL QUERY失败: %w", err)
+		}
+	}
+	q.cancel()
+	log.Printf("终止查询 %s (用户: %s)", id, q.User)
+	return true, killErr
+}
+
+// TrackQuery 为查询生成query_id并登记，返回带有query_id的context和结束时调用的函数
+// ctx结束时查询自动从列表中移除，避免调用方未关闭结果集时残留；
+// QueryContext等方法已自动登记，批量写入等直接使用sql.DB的操作需要自行调用
+func TrackQuery(ctx context.Context, query string) (context.Context, func()) {
+	id, err := newQueryID()
+	if err != nil {
+		log.Printf("生成query_id失败: %v", err)
+		return ctx, func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	q := &RunningQuery{
+		ID:        id,
+		SQL:       query,
+		User:      QueryUser(ctx),
+		StartedAt: time.Now(),
+		cancel:    cancel,
+	}
+
+	runningQueries.mu.Lock()
+	runningQueries.queries[id] = q
+	runningQueries.mu.Unlock()
+
+	var once sync.Once
+	done := func() {
+		once.Do(func() {
+			runningQueries.mu.Lock()
+			delete(runningQueries.queries, id)
+			runningQueries.mu.Unlock()
+			cancel()
+		})
+	}
+	context.AfterFunc(ctx, done)
+
+	// query_id和读取行数写入响应的meta
+	utils.RecordQuery(ctx, id)
+	progress := clickhouse.WithProgress(func(p *clickhouse.Progress) {
+		utils.AddRowsRead(ctx, int64(p.Rows))
+	})
+	return clickhouse.Context(ctx, clickhouse.WithQueryID(id), progress), done
+}
+
+// newQueryID 生成随机的query_id
+func newQueryID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// QueryContext 执行查询并登记到正在执行的查询列表，结果集关闭时移除
+func (c *ClickHouseDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	ctx, done := TrackQuery(ctx, query)
+	rows, err := c.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		done()
+		return nil, err
+	}
+	return &Rows{Rows: rows, done: done}, nil
+}
+
+// QueryRowContext 执行返回单行的查询并登记到正在执行的查询列表，Scan后移除
+func (c *ClickHouseDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *Row {
+	ctx, done := TrackQuery(ctx, query)
+	return &Row{row: c.DB.QueryRowContext(ctx, query, args...), done: done}
+}
+
+// Rows 登记过的查询结果集
+type Rows struct {
+	*sql.Rows
+	done func()
+}
+
+// Close 关闭结果集并从查询列表中移除
+func (r *Rows) Close() error {
+	err := r.Rows.Close()
+	r.done()
+	return err
+}
+
+// Row 登记过的单行查询结果
+type Row struct {
+	row  *sql.Row
+	done func()
+}
+
+// Scan 读取结果并从查询列表中移除
+func (r *Row) Scan(dest ...interface{}) error {
+	defer r.done()
+	return r.row.Scan(dest...)
+}
+
+// Err 返回查询的错误
+func (r *Row) Err() error {
+	return r.row.Err()
+}
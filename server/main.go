@@ -7,31 +7,226 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
 
 	"server/controllers"
 	"server/database"
+	"server/models"
+	"server/router"
+	"server/rowstream"
+	"server/sqlparse"
+	"server/utils"
 )
 
+// tablePolicy /api/query的table参数使用与SQL控制台相同的访问策略
+var tablePolicy = controllers.SQLPolicyFromEnv()
+
 func main() {
-	// 初始化数据库
-	_, err := database.InitClickHouse()
+	// 选择数据源，DATA_SOURCE=clickhouse|mock|fixture
+	source, err := database.InitSource()
 	if err != nil {
-		log.Fatalf("无法初始化数据库连接: %v", err)
+		log.Fatalf("数据源配置无效: %v", err)
 	}
-	log.Println("数据库连接已建立")
+	utils.SetDataSource(string(source))
+	log.Printf("使用%s数据源", source)
 
-	// 创建控制器实例
-	analyticsController := controllers.NewAnalyticsController()
+	if source == database.SourceClickHouse {
+		// 初始化数据库
+		if _, err := database.InitClickHouse(); err != nil {
+			log.Fatalf("无法初始化数据库连接: %v", err)
+		}
+		log.Println("数据库连接已建立")
+	}
+
+	// 日志存储，mock和fixture数据源使用内存存储
+	store, err := models.NewLogStore()
+	if err != nil {
+		log.Fatalf("无法准备%s数据源: %v", source, err)
+	}
+
+	// 启动异步写入队列，ClickHouse不可用时批次落盘等待回放
+	if err := models.StartIngestQueue(store); err != nil {
+		log.Fatalf("无法启动写入队列: %v", err)
+	}
 
-	// 设置路由
-	mux := http.NewServeMux()
-	mux.HandleFunc("/api/query", handleQuery)
-	mux.HandleFunc("/api/health", handleHealth)
+	// 创建控制器实例
+	// 项目、类别、级别下拉框的分组统计按TTL缓存，内存存储直接统计
+	logsStore := store
+	if source == database.SourceClickHouse {
+		logsStore = models.NewCachedStore(store, models.DiscoveryCacheTTL())
+	}
+	logsController := controllers.NewLogsController(logsStore)
+	analyticsController := controllers.NewAnalyticsController(store)
+	ingestController := controllers.NewIngestController(store)
+	streamController := controllers.NewStreamController(store)
+	fieldMappingController := controllers.NewFieldMappingController()
+	sqlController := controllers.NewSQLController()
+	exportController, err := controllers.NewExportController(store)
+	if err != nil {
+		log.Fatalf("无法启动导出任务: %v", err)
+	}
 
-	// 新增kv_7表查询接口
-	mux.HandleFunc("/api/query/kv7", analyticsController.QueryKV7Table)
+	documentController := controllers.NewDocumentController()
+	queryController := controllers.NewQueryController()
+
+	// 设置路由，按请求方法分发，路径参数写作{name}；/api/v1 与 /api 等价
+	// 声明了参数的路由由router.Validate校验，无效参数返回400并逐个列出
+	rt := router.New()
+	rt.Alias("/api/v1", "/api")
+	rt.Use(router.Validate)
+	rt.Get("/api/routes", "路由表", rt.ServeRoutes)
+	rt.Get("/api/openapi.json", "OpenAPI文档", rt.OpenAPIHandler(router.Info{
+		Title:       "logwatch API",
+		Version:     "1.0.0",
+		Description: "日志查询与分析接口，所有路径也可以通过/api/v1前缀访问",
+	}))
+	rt.Get("/api/errors", "错误码列表", handleErrorCatalog)
+	rt.Get("/api/health", "健康检查", handleHealth)
+
+	// 通用表查询接口
+	rt.Get("/api/query", "查询指定表的数据", handleQuery).
+		Query(params([]router.Parameter{router.String("table", "表名").DefaultValue("kv_7")}, tableQueryParams)...)
+	rt.Post("/api/query", "查询指定表的数据（请求体传参）", handleQuery).
+		Body("application/json", params([]router.Parameter{router.String("table", "表名").DefaultValue("kv_7")}, tableQueryParams)...)
+	rt.Get("/api/query/kv7", "按时间范围查询kv_7表", analyticsController.QueryKV7Table).
+		Query(tableQueryParams...)
+	rt.Get("/api/query/default", "SQL控制台默认数据", sqlController.GetDefaultData)
+
+	// 日志查询接口
+	rt.Get("/api/logs", "查询日志", logsController.QueryLogs).
+		Query(params(logFilterParams, logPageParams, []router.Parameter{router.Bool("count", "是否返回数据库中的总条数")})...)
+	rt.Get("/api/logs/{id}", "日志详情", logsController.GetLogDetail)
+	rt.Get("/api/logs/detail", "日志详情（id参数）", logsController.GetLogDetail).
+		Query(router.String("id", "日志ID").Require())
+	rt.Get("/api/logs/fields", "日志字段及统计", logsController.GetLogFields).
+		Query(params(logFilterParams, []router.Parameter{
+			router.String("fields", "限定的字段，逗号分隔"),
+			router.Int("top", "每个字段返回的高频值个数").Range(1, models.MaxFieldTopK).DefaultValue(strconv.Itoa(models.DefaultFieldTopK)),
+			router.Bool("stats", "是否统计字段的取值").DefaultValue("true"),
+		})...)
+	rt.Get("/api/logs/projects", "项目列表", logsController.GetProjects).
+		Query(timeRangeParams...)
+	rt.Get("/api/logs/types", "日志类别和级别", logsController.GetLogTypes).
+		Query(timeRangeParams...)
+	rt.Get("/api/logs/export", "导出日志", logsController.ExportLogs).
+		Query(params(logFilterParams, exportParams)...)
+
+	// 日志实时追踪接口，SSE和WebSocket，WebSocket支持连接期间更换筛选条件
+	rt.Get("/api/logs/tail", "实时追踪日志（SSE）", logsController.TailLogs).
+		Query(params(logFilterParams, []router.Parameter{
+			router.Time("since", "从该时间之后开始推送，默认为当前时间"),
+			router.Int("interval", "轮询间隔(秒)").Min(1).DefaultValue("2"),
+		})...)
+	rt.Get("/api/logs/ws", "实时追踪日志（WebSocket）", streamController.TailWebSocket)
+	rt.Get("/api/logs/ws/stats", "WebSocket连接统计", streamController.GetStreamStats)
+
+	// 日志写入接口
+	rt.Post("/api/ingest", "写入日志（JSON数组或{\"records\": [...]}）", ingestController.IngestLogs).
+		Query(router.String("mode", "写入方式，async时交给带落盘缓冲的写入队列").OneOf("sync", "async").DefaultValue("sync")).
+		Body("application/json")
+	rt.Post("/api/ingest/ndjson", "写入日志（NDJSON）", ingestController.IngestNDJSON).
+		Body("application/x-ndjson")
+
+	// 数据分析接口
+	rt.Get("/api/analytics/recent", "最近的记录", analyticsController.GetRecentData).
+		Query(params(timeRangeParams, []router.Parameter{
+			router.String("category", "事件分类"),
+			router.String("action", "行为类型"),
+			router.String("platform", "平台"),
+			router.Int("limit", "返回条数").Min(1).DefaultValue("100"),
+		})...)
+	rt.Get("/api/analytics/record", "单条记录详情（id参数）", analyticsController.GetRecordDetail).
+		Query(router.String("id", "记录ID").Require())
+	rt.Get("/api/analytics/events", "事件分析", analyticsController.GetEventAnalytics).
+		Query(timeRangeParams...)
+	rt.Get("/api/analytics/users", "用户分布", analyticsController.GetUserDistribution).
+		Query(timeRangeParams...)
+	rt.Get("/api/analytics/network", "网络性能统计", analyticsController.GetNetworkPerformance).
+		Query(params(timeRangeParams, []router.Parameter{
+			router.String("platform", "平台"),
+			router.String("os", "操作系统"),
+			router.String("user_id", "用户ID"),
+			router.String("app_id", "应用ID"),
+		})...)
+	rt.Get("/api/analytics/ios", "iOS设备统计", analyticsController.GetIOSDeviceStats).
+		Query(params(timeRangeParams, []router.Parameter{
+			router.String("category", "日志类别"),
+			router.String("user_id", "用户ID"),
+		})...)
+
+	// 文档接口
+	rt.Get("/api/documents", "文档列表", documentController.GetDocuments)
+	rt.Post("/api/documents", "创建文档", documentController.CreateDocument).
+		Body("application/json", documentFields...).Status(http.StatusCreated)
+	rt.Get("/api/documents/{id}", "文档详情", documentController.GetDocument)
+	rt.Put("/api/documents/{id}", "更新文档", documentController.UpdateDocument).
+		Body("application/json", documentFields...)
+	rt.Delete("/api/documents/{id}", "删除文档", documentController.DeleteDocument)
+
+	// 异步导出任务接口
+	rt.Get("/api/exports", "导出任务列表", exportController.ListExports)
+	rt.Post("/api/exports", "创建导出任务，参数与/api/logs/export相同", exportController.CreateExport).
+		Query(params(logFilterParams, exportParams)...).Status(http.StatusAccepted)
+	rt.Get("/api/exports/{id}", "导出任务状态", exportController.GetExport)
+	rt.Delete("/api/exports/{id}", "取消导出任务", exportController.CancelExport)
+	rt.Get("/api/exports/{id}/download", "下载导出文件，支持Range", exportController.DownloadExport)
+
+	// 字段映射接口，为各应用的匿名列配置语义名称
+	rt.Get("/api/field-mappings", "字段映射列表", fieldMappingController.ListFieldMappings).
+		Query(router.String("app_id", "应用ID，指定时同时返回合并默认映射后实际生效的映射"))
+	rt.Post("/api/field-mappings", "创建字段映射", fieldMappingController.CreateFieldMapping).
+		Body("application/json", params([]router.Parameter{
+			router.String("app_id", "应用ID，*表示所有应用的默认映射").Require(),
+			router.String("column", "kv_7的列名").Require(),
+		}, fieldMappingFields)...).Status(http.StatusCreated)
+	rt.Get("/api/field-mappings/{app_id}/{column}", "字段映射详情", fieldMappingController.GetFieldMapping)
+	rt.Put("/api/field-mappings/{app_id}/{column}", "更新字段映射", fieldMappingController.UpdateFieldMapping).
+		Body("application/json", fieldMappingFields...)
+	rt.Delete("/api/field-mappings/{app_id}/{column}", "删除字段映射", fieldMappingController.DeleteFieldMapping)
+
+	// SQL控制台接口，只允许单条只读语句
+	rt.Post("/api/sql/execute", "执行SQL", sqlController.ExecuteSQL).
+		Body("application/json",
+			router.String("query", "SQL语句，指定saved_query_id时可省略"),
+			router.Int("page", "页码").Min(1).DefaultValue("1"),
+			router.Int("pageSize", "每页条数").Min(1).DefaultValue("10"),
+			router.Cursor("cursor", "分页游标，指定时代替page"),
+			router.Object("params", "查询参数的值"),
+			router.String("saved_query_id", "执行保存的查询"),
+		)
+	rt.Post("/api/sql/explain", "查看SQL执行计划", sqlController.ExplainSQL).
+		Body("application/json",
+			router.String("query", "SQL语句").Require(),
+			router.Array("types", router.TypeString, "EXPLAIN类型，默认全部").OneOf("plan", "pipeline", "estimate"),
+			router.Object("params", "查询参数的值"),
+		)
+	rt.Get("/api/sql/tables", "表列表", sqlController.GetTables)
+	rt.Get("/api/sql/fields", "表字段（table参数）", sqlController.GetTableFields).
+		Query(router.String("table", "表名").Require())
+	rt.Get("/api/tables", "表列表", sqlController.GetTables)
+	rt.Get("/api/table/structure/{table}", "表字段", sqlController.GetTableFields)
+	rt.Get("/api/sql/history", "SQL执行历史", sqlController.ListHistory).
+		Query(
+			router.Int("limit", "返回条数").Range(1, 500).DefaultValue("50"),
+			router.Int("offset", "跳过条数").Min(0).DefaultValue("0"),
+			router.Cursor("cursor", "分页游标，代替offset，不能与offset同时指定"),
+		)
+	rt.Delete("/api/sql/history", "清空SQL执行历史", sqlController.ClearHistory)
+	rt.Get("/api/sql/saved", "保存的查询列表", sqlController.ListSavedQueries).
+		Query(router.String("tag", "按标签筛选"))
+	rt.Post("/api/sql/saved", "保存查询", sqlController.CreateSavedQuery).
+		Body("application/json", savedQueryFields...).Status(http.StatusCreated)
+	rt.Get("/api/sql/saved/{id}", "保存的查询详情", sqlController.GetSavedQuery)
+	rt.Put("/api/sql/saved/{id}", "更新保存的查询", sqlController.UpdateSavedQuery).
+		Body("application/json", savedQueryFields...)
+	rt.Delete("/api/sql/saved/{id}", "删除保存的查询", sqlController.DeleteSavedQuery)
+
+	// 正在执行的查询
+	rt.Get("/api/queries", "正在执行的查询", queryController.ListQueries)
+	rt.Delete("/api/queries/{id}", "终止查询", queryController.KillQuery)
 
 	// 获取端口配置
 	port := os.Getenv("BACKEND_PORT")
@@ -39,15 +234,50 @@ func main() {
 		port = "8888"
 	}
 
-	// 设置CORS
-	handler := corsMiddleware(mux)
+	// 设置CORS；RequestMeta收集响应meta中的query_id、耗时和读取行数
+	handler := corsMiddleware(dataSourceMiddleware(utils.RequestMeta(queryUserMiddleware(rt))))
+
+	server := &http.Server{Addr: ":" + port, Handler: handler}
+
+	// 收到退出信号时停止服务，并将写入队列中的剩余记录写入或落盘
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+		<-sigCh
+
+		log.Println("正在停止服务器...")
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("等待请求处理完成超时: %v", err)
+		}
+	}()
 
 	// 启动服务器
 	log.Printf("服务器启动在 http://localhost:%s", port)
-	err = http.ListenAndServe(":"+port, handler)
-	if err != nil {
+	err = server.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
 		log.Fatalf("无法启动服务器: %v", err)
 	}
+
+	// Shutdown开始时ListenAndServe就会返回，需要等处理中的请求完成后再关闭导出任务和写入队列
+	<-shutdownDone
+
+	exportController.Close()
+
+	if err := models.StopIngestQueue(); err != nil {
+		log.Printf("停止写入队列失败: %v", err)
+	}
+}
+
+// dataSourceMiddleware 在所有响应中通过X-Data-Source头标明数据来源，流式和二进制格式的响应也能区分
+func dataSourceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(utils.DataSourceHeader, utils.DataSource())
+		next.ServeHTTP(w, r)
+	})
 }
 
 // CORS中间件
@@ -55,8 +285,8 @@ func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// 设置CORS头
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Range")
 
 		// 处理OPTIONS请求
 		if r.Method == http.MethodOptions {
@@ -69,13 +299,20 @@ func corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// 查询用户中间件，在请求context中记录用户，显示在正在执行的查询列表中
+func queryUserMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := utils.GetUserFromRequest(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(database.WithQueryUser(r.Context(), user)))
+	})
+}
+
 // 处理查询请求
 func handleQuery(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet && r.Method != http.MethodPost {
-		respondWithError(w, http.StatusMethodNotAllowed, "仅支持GET和POST请求")
-		return
-	}
-
 	// 解析查询参数
 	var tableName string
 	var limit int
@@ -84,7 +321,7 @@ func handleQuery(w http.ResponseWriter, r *http.Request) {
 	var startTime string
 	var endTime string
 
-	if r.Method == http.MethodGet {
+	if r.Method != http.MethodPost {
 		// 获取基本参数
 		tableName = r.URL.Query().Get("table")
 		limitStr := r.URL.Query().Get("limit")
@@ -106,6 +343,11 @@ func handleQuery(w http.ResponseWriter, r *http.Request) {
 			offset = 0 // 默认值
 		}
 
+		// cursor为上一页响应中的next_cursor，指定时代替offset
+		if cursorOffset, err := utils.DecodeCursor(r.URL.Query().Get("cursor")); err == nil {
+			offset = cursorOffset
+		}
+
 		// 解析count参数
 		countRequested = countStr == "true" || countStr == "1"
 	} else {
@@ -114,6 +356,7 @@ func handleQuery(w http.ResponseWriter, r *http.Request) {
 			Table     string `json:"table"`
 			Limit     int    `json:"limit"`
 			Offset    int    `json:"offset"`
+			Cursor    string `json:"cursor"`
 			Count     bool   `json:"count"`
 			StartTime string `json:"start_time"`
 			EndTime   string `json:"end_time"`
@@ -138,6 +381,10 @@ func handleQuery(w http.ResponseWriter, r *http.Request) {
 		if offset < 0 {
 			offset = 0 // 默认值
 		}
+
+		if cursorOffset, err := utils.DecodeCursor(requestData.Cursor); err == nil {
+			offset = cursorOffset
+		}
 	}
 
 	// 检查表名是否提供
@@ -145,8 +392,19 @@ func handleQuery(w http.ResponseWriter, r *http.Request) {
 		tableName = "kv_7" // 默认表名
 	}
 
-	// 创建上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// 表名直接拼接到SQL中，需要与SQL控制台一样禁止表函数和白名单以外的系统表
+	table, err := tablePolicy.CheckTableName(tableName)
+	if err != nil {
+		detail, details := err.Error(), interface{}(nil)
+		if sqlErr, ok := err.(*sqlparse.Error); ok {
+			detail, details = sqlErr.Message, map[string]interface{}{"position": sqlErr.Pos, "table": tableName}
+		}
+		utils.RespondWithErrorCode(w, http.StatusBadRequest, utils.CodeInvalidQuery, "表名无效: "+detail, details)
+		return
+	}
+
+	// 创建上下文，客户端断开时查询随之取消
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
 
 	// 执行查询
@@ -154,58 +412,86 @@ func handleQuery(w http.ResponseWriter, r *http.Request) {
 		tableName, limit, offset, countRequested, startTime, endTime)
 
 	// 如果是kv_7表并且请求参数包含start_time或end_time，则调用增强的查询方法
-	if tableName == "kv_7" && (startTime != "" || endTime != "" || countRequested) {
+	if table.Database == "" && table.Name == "kv_7" && (startTime != "" || endTime != "" || countRequested) {
 		// 调用封装的方法处理kv_7表的高级查询
-		results, totalCount, err := database.QueryKV7WithFilters(ctx, limit, offset, countRequested, startTime, endTime)
+		rows, totalCount, err := database.QueryKV7WithFilters(ctx, limit, offset, countRequested, startTime, endTime)
 		if err != nil {
 			log.Printf("查询失败: %v", err)
-			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("查询执行失败: %v", err))
+			respondWithError(w, controllers.DataErrorStatus(err), fmt.Sprintf("查询执行失败: %v", err))
 			return
 		}
+		defer rows.Close()
 
-		// 构建响应
-		response := map[string]interface{}{
-			"success": true,
-			"data":    results,
-			"limit":   limit,
-			"offset":  offset,
+		columns, err := rowstream.Columns(rows)
+		if err != nil {
+			log.Printf("获取列信息失败: %v", err)
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("获取列信息失败: %v", err))
+			return
 		}
 
-		// 只有当请求要求获取总数时才返回总数
-		if countRequested {
-			response["total"] = totalCount
-			response["count"] = totalCount
-			response["dbTotalCount"] = totalCount
+		// 逐行编码写出，格式由Accept请求头决定
+		// 只有当请求要求获取总数时才返回总数，否则按当前页是否取满判断是否有下一页
+		written, err := rowstream.Stream(rowstream.NewEncoder(w, rowstream.Negotiate(r)), rows, columns, 0, -1, func(written, read int, err error) map[string]interface{} {
+			total := -1
+			if countRequested {
+				total = totalCount
+			}
+			return utils.Trailer(w, utils.NewPagination(total, offset, limit, written), utils.Meta{}, controllers.DataErrorStatus(err), err)
+		})
+		if err != nil {
+			log.Printf("遍历结果集失败: %v", err)
+			return
 		}
-
-		respondWithJSON(w, http.StatusOK, response)
+		log.Printf("查询kv_7成功，返回 %d 条记录，总数 %d", written, totalCount)
 		return
 	}
 
 	// 否则执行普通查询
-	results, err := database.QueryLogs(ctx, tableName, limit)
+	rows, err := database.QueryLogs(ctx, table.QuotedName(), limit)
 	if err != nil {
 		log.Printf("查询失败: %v", err)
-		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("查询执行失败: %v", err))
+		respondWithError(w, controllers.DataErrorStatus(err), fmt.Sprintf("查询执行失败: %v", err))
 		return
 	}
+	defer rows.Close()
 
-	// 返回结果
-	respondWithJSON(w, http.StatusOK, results)
-}
+	columns, err := rowstream.Columns(rows)
+	if err != nil {
+		log.Printf("获取列信息失败: %v", err)
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("获取列信息失败: %v", err))
+		return
+	}
 
-// 处理健康检查请求
-func handleHealth(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		respondWithError(w, http.StatusMethodNotAllowed, "仅支持GET请求")
+	// 逐行编码写出，格式由Accept请求头决定
+	written, err := rowstream.Stream(rowstream.NewEncoder(w, rowstream.Negotiate(r)), rows, columns, 0, -1, func(written, read int, err error) map[string]interface{} {
+		return utils.Trailer(w, nil, utils.Meta{}, controllers.DataErrorStatus(err), err)
+	})
+	if err != nil {
+		log.Printf("遍历结果集失败: %v", err)
 		return
 	}
+	log.Printf("查询成功，返回 %d 条记录", written)
+}
+
+// 返回所有错误码及各语言的说明
+func handleErrorCatalog(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, utils.ErrorCatalog())
+}
 
+// 处理健康检查请求
+func handleHealth(w http.ResponseWriter, r *http.Request) {
 	status := map[string]interface{}{
 		"status": "ok",
 		"time":   time.Now().Format(time.RFC3339),
 	}
 
+	// mock和fixture数据源不连接数据库
+	if database.CurrentSource() != database.SourceClickHouse {
+		status["database"] = "未使用"
+		respondWithJSON(w, http.StatusOK, status)
+		return
+	}
+
 	// 检查数据库连接
 	db := database.GetDB()
 	if db == nil {
@@ -220,23 +506,20 @@ func handleHealth(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// 写入队列状态
+	if stats := models.IngestQueueStats(); stats != nil {
+		status["ingest_queue"] = stats
+	}
+
 	respondWithJSON(w, http.StatusOK, status)
 }
 
-// 返回JSON响应
+// 返回JSON响应，与控制器相同，使用统一的响应结构
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
-	response, err := json.Marshal(payload)
-	if err != nil {
-		log.Printf("无法序列化响应: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	w.Write(response)
+	utils.RespondWithJSON(w, code, payload)
 }
 
-// 返回错误响应
+// 返回错误响应，错误码由状态码决定
 func respondWithError(w http.ResponseWriter, code int, message string) {
-	respondWithJSON(w, code, map[string]string{"error": message})
+	utils.RespondWithError(w, code, message)
 }
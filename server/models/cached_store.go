@@ -0,0 +1,85 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"server/database"
+)
+
+// CachedStore 按TTL缓存Aggregate结果的LogStore，其余方法直接调用内部的存储
+// 查询前时间范围截断到分钟并作为缓存键的一部分，使默认的“最近24小时”请求可以命中缓存，
+// 用于项目、类别、级别下拉框等每次打开都会扫描kv_7的分组统计
+type CachedStore struct {
+	LogStore
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]aggregateCacheEntry
+}
+
+// aggregateCacheEntry 缓存的统计结果
+type aggregateCacheEntry struct {
+	rows    []AggregateRow
+	expires time.Time
+}
+
+// NewCachedStore 创建缓存store统计结果的存储，ttl为0时不缓存
+func NewCachedStore(store LogStore, ttl time.Duration) *CachedStore {
+	return &CachedStore{LogStore: store, ttl: ttl, entries: make(map[string]aggregateCacheEntry)}
+}
+
+// DiscoveryCacheTTL 下拉框取值的缓存有效期，通过DISCOVERY_CACHE_TTL_SECONDS配置，默认60秒
+func DiscoveryCacheTTL() time.Duration {
+	if v := os.Getenv("DISCOVERY_CACHE_TTL_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return time.Duration(n) * time.Second
+		}
+	}
+	return time.Minute
+}
+
+// Aggregate 实现LogStore，缓存中的结果是共享的，调用方不能修改
+func (s *CachedStore) Aggregate(ctx context.Context, options database.QueryOptions, agg Aggregation) ([]AggregateRow, error) {
+	if s.ttl <= 0 {
+		return s.LogStore.Aggregate(ctx, options, agg)
+	}
+
+	options.StartTime = options.StartTime.Truncate(time.Minute)
+	options.EndTime = options.EndTime.Truncate(time.Minute)
+	key, err := json.Marshal(struct {
+		Options     database.QueryOptions
+		Aggregation Aggregation
+	}{options, agg})
+	if err != nil {
+		return nil, fmt.Errorf("生成缓存键失败: %w", err)
+	}
+
+	s.mu.Lock()
+	entry, ok := s.entries[string(key)]
+	s.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.rows, nil
+	}
+
+	rows, err := s.LogStore.Aggregate(ctx, options, agg)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	for k, e := range s.entries {
+		if now.After(e.expires) {
+			delete(s.entries, k)
+		}
+	}
+	s.entries[string(key)] = aggregateCacheEntry{rows: rows, expires: now.Add(s.ttl)}
+	s.mu.Unlock()
+
+	return rows, nil
+}
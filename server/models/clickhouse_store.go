@@ -0,0 +1,412 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"server/database"
+)
+
+// ClickHouseStore 基于test_db.kv_7的日志存储
+type ClickHouseStore struct{}
+
+// NewClickHouseStore 创建ClickHouse日志存储，连接在每次查询时通过database.Conn获取
+func NewClickHouseStore() *ClickHouseStore {
+	return &ClickHouseStore{}
+}
+
+// clickhouseWhere 构建包含时间范围和其他筛选条件的WHERE子句
+func clickhouseWhere(options database.QueryOptions) (string, []interface{}, error) {
+	conditions, args, err := buildFilterConditions(options)
+	if err != nil {
+		return "", nil, err
+	}
+	if !options.StartTime.IsZero() {
+		conditions = append(conditions, "data_time >= ?")
+		args = append(args, options.StartTime)
+	}
+	if !options.EndTime.IsZero() {
+		conditions = append(conditions, "data_time <= ?")
+		args = append(args, options.EndTime)
+	}
+	if len(conditions) == 0 {
+		return "WHERE 1=1", args, nil
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args, nil
+}
+
+// Query 实现LogStore
+func (s *ClickHouseStore) Query(ctx context.Context, options database.QueryOptions, columns []string) ([]KV7Record, error) {
+	stream, err := s.Stream(ctx, options, columns)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	records := []KV7Record{}
+	for stream.Next() {
+		records = append(records, *stream.Record())
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Stream 实现LogStore，逐行读取查询结果
+func (s *ClickHouseStore) Stream(ctx context.Context, options database.QueryOptions, columns []string) (RecordStream, error) {
+	columns, err := queryColumns(columns)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := database.Conn()
+	if err != nil {
+		return nil, err
+	}
+
+	where, args, err := clickhouseWhere(options)
+	if err != nil {
+		return nil, err
+	}
+	orderBy, desc := sortOrder(options)
+	orderDir := "ASC"
+	if desc {
+		orderDir = "DESC"
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM test_db.kv_7 %s ORDER BY %s %s",
+		strings.Join(columns, ", "), where, orderBy, orderDir)
+	if options.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, options.Limit, max(options.Offset, 0))
+	}
+	log.Printf("数据查询SQL: %s, 参数: %v", query, args)
+
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询日志失败: %w", err)
+	}
+	stream := &rowsStream{rows: rows}
+	stream.dest = stream.record.Pointers(columns)
+	return stream, nil
+}
+
+// rowsStream 逐行扫描ClickHouse结果集的RecordStream
+type rowsStream struct {
+	rows   *database.Rows
+	record KV7Record
+	dest   []interface{}
+	err    error
+}
+
+func (s *rowsStream) Next() bool {
+	if s.err != nil || !s.rows.Next() {
+		if s.err == nil && s.rows.Err() != nil {
+			s.err = fmt.Errorf("读取行数据失败: %w", s.rows.Err())
+		}
+		return false
+	}
+	if err := s.rows.Scan(s.dest...); err != nil {
+		s.err = fmt.Errorf("扫描行数据失败: %w", err)
+		return false
+	}
+	return true
+}
+
+func (s *rowsStream) Record() *KV7Record {
+	return &s.record
+}
+
+func (s *rowsStream) Err() error {
+	return s.err
+}
+
+func (s *rowsStream) Close() error {
+	return s.rows.Close()
+}
+
+// Count 实现LogStore
+func (s *ClickHouseStore) Count(ctx context.Context, options database.QueryOptions) (int, error) {
+	conn, err := database.Conn()
+	if err != nil {
+		return 0, err
+	}
+
+	where, args, err := clickhouseWhere(options)
+	if err != nil {
+		return 0, err
+	}
+	query := "SELECT COUNT(*) FROM test_db.kv_7 " + where
+	log.Printf("计数查询SQL: %s, 参数: %v", query, args)
+
+	var total int
+	if err := conn.QueryRowContext(ctx, query, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("查询记录总数失败: %w", err)
+	}
+	return total, nil
+}
+
+// Aggregate 实现LogStore
+func (s *ClickHouseStore) Aggregate(ctx context.Context, options database.QueryOptions, agg Aggregation) ([]AggregateRow, error) {
+	if err := agg.validate(); err != nil {
+		return nil, err
+	}
+	conn, err := database.Conn()
+	if err != nil {
+		return nil, err
+	}
+
+	where, args, err := clickhouseWhere(options)
+	if err != nil {
+		return nil, err
+	}
+	if agg.SkipEmpty {
+		for _, column := range agg.GroupBy {
+			where += fmt.Sprintf(" AND %s != ''", column)
+		}
+	}
+
+	// 分组值统一转换为字符串，与MemoryStore按字符串比较的语义一致
+	var selects, groupBy, orderBy []string
+	if agg.Interval > 0 {
+		selects = append(selects, fmt.Sprintf("toStartOfInterval(data_time, INTERVAL %d SECOND) AS bucket", int64(agg.Interval.Seconds())))
+		groupBy = append(groupBy, "bucket")
+		orderBy = append(orderBy, "bucket")
+	}
+	for i, column := range agg.GroupBy {
+		selects = append(selects, fmt.Sprintf("toString(%s) AS key%d", column, i))
+		groupBy = append(groupBy, fmt.Sprintf("key%d", i))
+	}
+	selects = append(selects, "count() AS count")
+	orderBy = append(orderBy, "count DESC")
+	for i := range agg.GroupBy {
+		orderBy = append(orderBy, fmt.Sprintf("key%d", i))
+	}
+	for _, column := range agg.Avg {
+		selects = append(selects, fmt.Sprintf("avg(%s)", column))
+	}
+	for _, column := range agg.Uniq {
+		selects = append(selects, fmt.Sprintf("uniqExact(%s)", column))
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM test_db.kv_7 %s", strings.Join(selects, ", "), where)
+	if len(groupBy) > 0 {
+		query += " GROUP BY " + strings.Join(groupBy, ", ") + " ORDER BY " + strings.Join(orderBy, ", ")
+	}
+	if agg.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", agg.Limit)
+	}
+
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("统计查询失败: %w", err)
+	}
+	defer rows.Close()
+
+	result := []AggregateRow{}
+	for rows.Next() {
+		row := AggregateRow{Keys: make([]string, len(agg.GroupBy)), Avg: make([]float64, len(agg.Avg)), Uniq: make([]uint64, len(agg.Uniq))}
+		var dest []interface{}
+		if agg.Interval > 0 {
+			dest = append(dest, &row.Time)
+		}
+		for i := range row.Keys {
+			dest = append(dest, &row.Keys[i])
+		}
+		dest = append(dest, &row.Count)
+		for i := range row.Avg {
+			dest = append(dest, &row.Avg[i])
+		}
+		for i := range row.Uniq {
+			dest = append(dest, &row.Uniq[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("读取统计结果失败: %w", err)
+		}
+		// 没有记录时ClickHouse的avg返回nan
+		for i, v := range row.Avg {
+			if math.IsNaN(v) {
+				row.Avg[i] = 0
+			}
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取统计结果失败: %w", err)
+	}
+	return result, nil
+}
+
+// Get 实现LogStore
+func (s *ClickHouseStore) Get(ctx context.Context, id string) (*KV7Record, error) {
+	conn, err := database.Conn()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := KV7Columns()
+	query := fmt.Sprintf("SELECT %s FROM test_db.kv_7 WHERE id = ? LIMIT 1", strings.Join(columns, ", "))
+
+	var r KV7Record
+	err = conn.QueryRowContext(ctx, query, id).Scan(r.Pointers(columns)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrLogNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取完整记录失败: %w", err)
+	}
+	return &r, nil
+}
+
+// Insert 实现LogStore，通过ClickHouse批量写入接口插入记录
+func (s *ClickHouseStore) Insert(ctx context.Context, records []KV7Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	conn, err := database.Conn()
+	if err != nil {
+		return err
+	}
+
+	// 批量写入同样登记到正在执行的查询列表，query_id写入响应的meta
+	query := fmt.Sprintf("INSERT INTO test_db.kv_7 (%s)", strings.Join(KV7Columns(), ", "))
+	ctx, done := database.TrackQuery(ctx, query)
+	defer done()
+
+	// clickhouse-go在事务中预编译INSERT语句时会使用批量写入
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启批量写入失败: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("准备批量写入失败: %w", err)
+	}
+	defer stmt.Close()
+
+	for i := range records {
+		if _, err := stmt.ExecContext(ctx, records[i].Values()...); err != nil {
+			tx.Rollback()
+			// 追加只在客户端转换列值，失败说明记录本身无法写入
+			return fmt.Errorf("%w: 追加第%d条记录失败: %v", database.ErrBatchRejected, i, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交批量写入失败: %w", err)
+	}
+
+	log.Printf("成功写入 %d 条kv_7记录", len(records))
+	return nil
+}
+
+// Tail 实现LogStore
+// 窗口内已推送过的ID在数据库侧排除，避免窗口内记录过多时反复读到同一页
+func (s *ClickHouseStore) Tail(ctx context.Context, options database.QueryOptions, cursor *TailCursor, limit int) ([]KV7Record, error) {
+	conn, err := database.Conn()
+	if err != nil {
+		return nil, err
+	}
+
+	conditions, args, err := buildFilterConditions(options)
+	if err != nil {
+		return nil, err
+	}
+	conditions = append([]string{"write_time >= ?"}, conditions...)
+	args = append([]interface{}{cursor.from()}, args...)
+
+	if len(cursor.seen) > 0 {
+		placeholders := make([]string, 0, len(cursor.seen))
+		for id := range cursor.seen {
+			placeholders = append(placeholders, "?")
+			args = append(args, id)
+		}
+		conditions = append(conditions, fmt.Sprintf("id NOT IN (%s)", strings.Join(placeholders, ", ")))
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM test_db.kv_7
+		WHERE %s
+		ORDER BY write_time, id
+		LIMIT ?
+	`, strings.Join(tailColumns, ", "), strings.Join(conditions, " AND "))
+	args = append(args, limit)
+
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询新日志失败: %w", err)
+	}
+	defer rows.Close()
+
+	var records []KV7Record
+	for rows.Next() {
+		var record KV7Record
+		if err := rows.Scan(record.Pointers(tailColumns)...); err != nil {
+			return nil, fmt.Errorf("扫描行数据失败: %w", err)
+		}
+		if cursor.advance(&record) {
+			records = append(records, record)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取结果集失败: %w", err)
+	}
+
+	cursor.prune()
+	return records, nil
+}
+
+// Fields 实现LogStore，字段来自system.columns
+func (s *ClickHouseStore) Fields(ctx context.Context) ([]LogField, error) {
+	conn, err := database.Conn()
+	if err != nil {
+		return nil, err
+	}
+
+	dbName, table := database.LogsTable()
+	rows, err := conn.QueryContext(ctx, `
+		SELECT name, type, comment
+		FROM system.columns
+		WHERE database = ? AND table = ?
+		ORDER BY position
+	`, dbName, table)
+	if err != nil {
+		return nil, fmt.Errorf("查询表结构失败: %w", err)
+	}
+	defer rows.Close()
+
+	var fields []LogField
+	for rows.Next() {
+		var f LogField
+		if err := rows.Scan(&f.Name, &f.Type, &f.Comment); err != nil {
+			return nil, fmt.Errorf("读取表结构失败: %w", err)
+		}
+		fields = append(fields, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取表结构失败: %w", err)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("表 %s.%s 不存在或没有字段", dbName, table)
+	}
+	return fields, nil
+}
+
+// FieldStats 实现LogStore，一次扫描统计所有字段
+func (s *ClickHouseStore) FieldStats(ctx context.Context, options LogFieldsOptions, fields []LogField) (uint64, error) {
+	conn, err := database.Conn()
+	if err != nil {
+		return 0, err
+	}
+	dbName, table := database.LogsTable()
+	return collectFieldStats(ctx, conn, dbName+"."+table, fields, options)
+}
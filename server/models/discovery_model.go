@@ -0,0 +1,87 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"server/database"
+)
+
+// maxDistinctValues 每个字段最多返回的不同值个数
+const maxDistinctValues = 1000
+
+// DistinctValue 字段的一个取值及其在时间范围内的日志条数
+type DistinctValue struct {
+	Value string `json:"value"`
+	Text  string `json:"text"`
+	Count uint64 `json:"count"`
+}
+
+// levelTexts 日志级别的显示名称
+var levelTexts = map[string]string{
+	"ERROR": "错误日志",
+	"WARN":  "警告日志",
+	"INFO":  "信息日志",
+	"DEBUG": "调试日志",
+}
+
+// LogTypeLevel 将日志查询的log_type参数转换为日志级别
+// log_type为/api/logs/types返回的级别(如ERROR)，也兼容旧版前端传入的显示名称(如错误日志)；
+// 全部日志以及页面访问日志等不对应级别的名称返回空字符串，表示不按级别筛选
+func LogTypeLevel(logType string) string {
+	logType = strings.TrimSpace(logType)
+	for level, text := range levelTexts {
+		if text == logType {
+			return level
+		}
+	}
+	if strings.HasSuffix(logType, "日志") {
+		return ""
+	}
+	return logType
+}
+
+// GetProjects 获取时间范围内有日志的项目(app_id)及日志条数
+func GetProjects(ctx context.Context, store LogStore, startTime, endTime time.Time) ([]DistinctValue, error) {
+	return getDistinctValues(ctx, store, "app_id", startTime, endTime)
+}
+
+// GetLogCategories 获取时间范围内出现的日志类别(category)及日志条数
+func GetLogCategories(ctx context.Context, store LogStore, startTime, endTime time.Time) ([]DistinctValue, error) {
+	return getDistinctValues(ctx, store, "category", startTime, endTime)
+}
+
+// GetLogLevels 获取时间范围内出现的日志级别(level)及日志条数
+func GetLogLevels(ctx context.Context, store LogStore, startTime, endTime time.Time) ([]DistinctValue, error) {
+	values, err := getDistinctValues(ctx, store, "level", startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, v := range values {
+		if text, ok := levelTexts[v.Value]; ok {
+			values[i].Text = text
+		}
+	}
+	return values, nil
+}
+
+// getDistinctValues 按日志条数降序返回字段的取值，store为CachedStore时结果按TTL缓存
+func getDistinctValues(ctx context.Context, store LogStore, column string, startTime, endTime time.Time) ([]DistinctValue, error) {
+	rows, err := store.Aggregate(ctx, database.QueryOptions{StartTime: startTime, EndTime: endTime}, Aggregation{
+		GroupBy:   []string{column},
+		SkipEmpty: true,
+		Limit:     maxDistinctValues,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("查询%s取值失败: %w", column, err)
+	}
+
+	values := make([]DistinctValue, 0, len(rows))
+	for _, row := range rows {
+		values = append(values, DistinctValue{Value: row.Keys[0], Text: row.Keys[0], Count: row.Count})
+	}
+	return values, nil
+}
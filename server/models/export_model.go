@@ -0,0 +1,105 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"server/database"
+)
+
+// defaultExportColumns 未指定列时导出的基础列，之后是应用的语义字段
+var defaultExportColumns = []string{"data_time", "id", "user_id", "platform", "os", "action"}
+
+// ExportColumn 导出的一列，Header为表头中使用的名称
+type ExportColumn struct {
+	Column string `json:"column"`
+	Header string `json:"header"`
+	Type   string `json:"type"`
+}
+
+// ResolveExportColumns 将请求的列名解析为kv_7的列，支持别名和字段映射中的语义名
+// names为空时导出基础列和应用的全部语义字段
+func ResolveExportColumns(names []string, appID string) ([]ExportColumn, error) {
+	var columns []ExportColumn
+	if len(names) == 0 {
+		for _, name := range defaultExportColumns {
+			columns = append(columns, ExportColumn{Column: name, Header: name, Type: KV7ColumnType(name)})
+		}
+		for _, m := range fieldMappings.Effective(appID) {
+			columns = append(columns, ExportColumn{Column: m.Column, Header: m.Name, Type: KV7ColumnType(m.Column)})
+		}
+		return columns, nil
+	}
+
+	schema := kv7Schema{appID: appID}
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		column, columnType, ok := schema.Resolve(name)
+		if !ok {
+			return nil, &ValidationError{Field: "columns", Reason: fmt.Sprintf("未知字段 %q", name)}
+		}
+		if seen[name] {
+			return nil, &ValidationError{Field: "columns", Reason: fmt.Sprintf("字段 %q 重复", name)}
+		}
+		seen[name] = true
+		columns = append(columns, ExportColumn{Column: column, Header: name, Type: columnType})
+	}
+	return columns, nil
+}
+
+// LogStream 逐行读取日志，用于大批量导出，内存占用与总行数无关(内存存储除外)
+type LogStream struct {
+	stream  RecordStream
+	columns []string
+	scanned atomic.Int64
+}
+
+// OpenLogStream 按筛选条件打开日志流，options.Limit为0表示不限制行数
+func OpenLogStream(ctx context.Context, store LogStore, options database.QueryOptions, columns []ExportColumn) (*LogStream, error) {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Column
+	}
+
+	stream, err := store.Stream(ctx, options, names)
+	if err != nil {
+		return nil, fmt.Errorf("查询导出数据失败: %w", err)
+	}
+	return &LogStream{stream: stream, columns: names}, nil
+}
+
+// Next 读取下一行，没有更多数据或出错时返回false，错误通过Err获取
+func (s *LogStream) Next() bool {
+	if !s.stream.Next() {
+		return false
+	}
+	s.scanned.Add(1)
+	return true
+}
+
+// Scanned 返回已读取的行数，可在其他goroutine中调用
+func (s *LogStream) Scanned() int64 {
+	return s.scanned.Load()
+}
+
+// Values 返回当前行各列的值，切片在下一次调用时复用
+func (s *LogStream) Values(values []interface{}) []interface{} {
+	values = values[:0]
+	record := s.stream.Record()
+	for _, column := range s.columns {
+		v, _ := record.Get(column)
+		values = append(values, v)
+	}
+	return values
+}
+
+// Err 返回读取过程中的错误
+func (s *LogStream) Err() error {
+	return s.stream.Err()
+}
+
+// Close 关闭日志流
+func (s *LogStream) Close() error {
+	return s.stream.Close()
+}
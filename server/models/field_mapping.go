@@ -0,0 +1,334 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// DefaultMappingApp 默认映射对应的app_id，未单独配置的应用使用默认映射
+const DefaultMappingApp = "*"
+
+// 字段语义类型
+var fieldMappingTypes = map[string]bool{
+	"string":   true,
+	"number":   true,
+	"duration": true,
+	"bytes":    true,
+	"enum":     true,
+	"boolean":  true,
+}
+
+// FieldMappingTypes 返回支持的字段语义类型，按名称排序
+func FieldMappingTypes() []string {
+	types := make([]string, 0, len(fieldMappingTypes))
+	for t := range fieldMappingTypes {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// mappableColumnPattern 允许配置映射的匿名列
+var mappableColumnPattern = regexp.MustCompile(`^(d|v|info|ud|uv|sd|sv)[0-9]+$`)
+
+// fieldNamePattern 语义字段名只允许小写字母、数字和下划线
+var fieldNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// FieldMapping 匿名列的语义描述
+type FieldMapping struct {
+	Column      string `json:"column"`
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Unit        string `json:"unit,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// FieldMappingError 字段映射校验错误
+type FieldMappingError struct {
+	Field  string
+	Reason string
+}
+
+func (e *FieldMappingError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Reason)
+}
+
+// defaultFieldMappings 原先硬编码在查询和导出中的列含义
+var defaultFieldMappings = []FieldMapping{
+	{Column: "d1", Name: "message", Type: "string", Description: "消息内容"},
+	{Column: "d38", Name: "network_type", Type: "enum", Description: "网络类型"},
+	{Column: "d40", Name: "region", Type: "string", Description: "地区"},
+	{Column: "v1", Name: "total_time", Type: "duration", Unit: "ms", Description: "总耗时"},
+	{Column: "v2", Name: "dns_time", Type: "duration", Unit: "ms", Description: "DNS耗时"},
+	{Column: "v3", Name: "tcp_time", Type: "duration", Unit: "ms", Description: "TCP连接耗时"},
+	{Column: "v4", Name: "request_time", Type: "duration", Unit: "ms", Description: "请求耗时"},
+	{Column: "v5", Name: "response_time", Type: "duration", Unit: "ms", Description: "响应耗时"},
+}
+
+// FieldMappingRegistry 按app_id管理匿名列的语义映射，持久化到JSON文件
+type FieldMappingRegistry struct {
+	mu   sync.RWMutex
+	path string
+	// apps app_id -> 列名 -> 映射
+	apps map[string]map[string]FieldMapping
+}
+
+// fieldMappings 全局字段映射注册表
+var fieldMappings = loadFieldMappings()
+
+// loadFieldMappings 从FIELD_MAPPING_FILE加载映射，文件不存在时使用默认映射
+func loadFieldMappings() *FieldMappingRegistry {
+	path := os.Getenv("FIELD_MAPPING_FILE")
+	if path == "" {
+		path = filepath.Join("data", "field_mappings.json")
+	}
+
+	registry, err := NewFieldMappingRegistry(path)
+	if err != nil {
+		log.Printf("加载字段映射失败，使用默认映射: %v", err)
+		registry = &FieldMappingRegistry{path: path, apps: map[string]map[string]FieldMapping{}}
+		registry.apps[DefaultMappingApp] = indexFieldMappings(defaultFieldMappings)
+	}
+	return registry
+}
+
+// NewFieldMappingRegistry 从文件创建注册表
+func NewFieldMappingRegistry(path string) (*FieldMappingRegistry, error) {
+	registry := &FieldMappingRegistry{path: path, apps: map[string]map[string]FieldMapping{}}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		registry.apps[DefaultMappingApp] = indexFieldMappings(defaultFieldMappings)
+		return registry, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取字段映射文件失败: %w", err)
+	}
+
+	var stored map[string][]FieldMapping
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("解析字段映射文件失败: %w", err)
+	}
+	for appID, mappings := range stored {
+		for _, m := range mappings {
+			if err := validateFieldMapping(m); err != nil {
+				return nil, fmt.Errorf("应用%s的字段映射无效: %w", appID, err)
+			}
+		}
+		registry.apps[appID] = indexFieldMappings(mappings)
+	}
+	return registry, nil
+}
+
+func indexFieldMappings(mappings []FieldMapping) map[string]FieldMapping {
+	index := make(map[string]FieldMapping, len(mappings))
+	for _, m := range mappings {
+		index[m.Column] = m
+	}
+	return index
+}
+
+// validateFieldMapping 校验单条映射
+func validateFieldMapping(m FieldMapping) error {
+	if !mappableColumnPattern.MatchString(m.Column) || !IsKV7Column(m.Column) {
+		return &FieldMappingError{Field: "column", Reason: fmt.Sprintf("%q 不是可映射的列", m.Column)}
+	}
+	if !fieldNamePattern.MatchString(m.Name) {
+		return &FieldMappingError{Field: "name", Reason: "只能包含小写字母、数字和下划线，且以字母开头"}
+	}
+	if IsKV7Column(m.Name) {
+		return &FieldMappingError{Field: "name", Reason: fmt.Sprintf("%q 与已有列名冲突", m.Name)}
+	}
+	if _, ok := fieldAliases[m.Name]; ok {
+		return &FieldMappingError{Field: "name", Reason: fmt.Sprintf("%q 与内置别名冲突", m.Name)}
+	}
+	if !fieldMappingTypes[m.Type] {
+		return &FieldMappingError{Field: "type", Reason: fmt.Sprintf("不支持的类型 %q", m.Type)}
+	}
+	return nil
+}
+
+// List 返回应用自身配置的映射，按列在表中的顺序排列
+func (reg *FieldMappingRegistry) List(appID string) []FieldMapping {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return sortFieldMappings(reg.apps[appID])
+}
+
+// Apps 返回所有配置了映射的app_id
+func (reg *FieldMappingRegistry) Apps() []string {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	apps := make([]string, 0, len(reg.apps))
+	for appID := range reg.apps {
+		apps = append(apps, appID)
+	}
+	sort.Strings(apps)
+	return apps
+}
+
+// Effective 返回应用实际生效的映射：应用自身的映射覆盖默认映射
+func (reg *FieldMappingRegistry) Effective(appID string) []FieldMapping {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return sortFieldMappings(reg.effective(appID))
+}
+
+func (reg *FieldMappingRegistry) effective(appID string) map[string]FieldMapping {
+	merged := make(map[string]FieldMapping)
+	for column, m := range reg.apps[DefaultMappingApp] {
+		merged[column] = m
+	}
+	if appID != "" && appID != DefaultMappingApp {
+		// 应用映射的语义名覆盖默认映射中同名的字段
+		names := make(map[string]string, len(merged))
+		for column, m := range merged {
+			names[m.Name] = column
+		}
+		for column, m := range reg.apps[appID] {
+			if old, ok := names[m.Name]; ok {
+				delete(merged, old)
+			}
+			merged[column] = m
+		}
+	}
+	return merged
+}
+
+// Resolve 将语义字段名解析为列名
+func (reg *FieldMappingRegistry) Resolve(appID, name string) (string, bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	for column, m := range reg.effective(appID) {
+		if m.Name == name {
+			return column, true
+		}
+	}
+	return "", false
+}
+
+// Column 返回语义字段对应的列名，未配置时返回fallback
+func (reg *FieldMappingRegistry) Column(appID, name, fallback string) string {
+	if column, ok := reg.Resolve(appID, name); ok {
+		return column
+	}
+	return fallback
+}
+
+// Get 获取应用某列的映射
+func (reg *FieldMappingRegistry) Get(appID, column string) (FieldMapping, bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	m, ok := reg.apps[appID][column]
+	return m, ok
+}
+
+// Put 新增或更新映射并持久化
+func (reg *FieldMappingRegistry) Put(appID string, m FieldMapping) error {
+	if appID == "" {
+		return &FieldMappingError{Field: "app_id", Reason: "不能为空"}
+	}
+	if err := validateFieldMapping(m); err != nil {
+		return err
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	for column, existing := range reg.apps[appID] {
+		if column != m.Column && existing.Name == m.Name {
+			return &FieldMappingError{Field: "name", Reason: fmt.Sprintf("%q 已映射到列 %s", m.Name, column)}
+		}
+	}
+
+	previous, existed := reg.apps[appID][m.Column]
+	if reg.apps[appID] == nil {
+		reg.apps[appID] = make(map[string]FieldMapping)
+	}
+	reg.apps[appID][m.Column] = m
+
+	if err := reg.save(); err != nil {
+		// 持久化失败时回滚内存中的修改
+		if existed {
+			reg.apps[appID][m.Column] = previous
+		} else {
+			delete(reg.apps[appID], m.Column)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete 删除映射并持久化，映射不存在时返回false
+func (reg *FieldMappingRegistry) Delete(appID, column string) (bool, error) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	previous, ok := reg.apps[appID][column]
+	if !ok {
+		return false, nil
+	}
+	delete(reg.apps[appID], column)
+	if len(reg.apps[appID]) == 0 {
+		delete(reg.apps, appID)
+	}
+
+	if err := reg.save(); err != nil {
+		if reg.apps[appID] == nil {
+			reg.apps[appID] = make(map[string]FieldMapping)
+		}
+		reg.apps[appID][column] = previous
+		return false, err
+	}
+	return true, nil
+}
+
+// save 先写临时文件再重命名，避免写入中断导致文件损坏，调用方需持有写锁
+func (reg *FieldMappingRegistry) save() error {
+	stored := make(map[string][]FieldMapping, len(reg.apps))
+	for appID, mappings := range reg.apps {
+		stored[appID] = sortFieldMappings(mappings)
+	}
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化字段映射失败: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(reg.path), 0755); err != nil {
+		return fmt.Errorf("创建字段映射目录失败: %w", err)
+	}
+	tmp := reg.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("写入字段映射文件失败: %w", err)
+	}
+	if err := os.Rename(tmp, reg.path); err != nil {
+		return fmt.Errorf("保存字段映射文件失败: %w", err)
+	}
+	return nil
+}
+
+// sortFieldMappings 按列在kv_7中的顺序排列映射
+func sortFieldMappings(mappings map[string]FieldMapping) []FieldMapping {
+	list := make([]FieldMapping, 0, len(mappings))
+	for _, m := range mappings {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return kv7FieldIndex[list[i].Column].Index < kv7FieldIndex[list[j].Column].Index
+	})
+	return list
+}
+
+// FieldMappings 返回全局字段映射注册表
+func FieldMappings() *FieldMappingRegistry {
+	return fieldMappings
+}
@@ -0,0 +1,239 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"server/database"
+)
+
+// 字段统计配置
+const (
+	DefaultFieldTopK = 5
+	MaxFieldTopK     = 20
+)
+
+// fieldLabels 常用列的中文名称，映射字段使用映射中的描述
+var fieldLabels = map[string]string{
+	"data_time":  "数据时间",
+	"write_time": "写入时间",
+	"time_hour":  "小时",
+	"id":         "日志ID",
+	"time":       "时间戳",
+	"app_id":     "应用ID",
+	"platform":   "平台",
+	"user_id":    "用户ID",
+	"version":    "版本",
+	"device_id":  "设备ID",
+	"model":      "设备型号",
+	"os":         "操作系统",
+	"os_ver":     "系统版本",
+	"sdk_ver":    "SDK版本",
+	"category":   "类别",
+	"action":     "操作",
+	"label":      "标签",
+	"state":      "状态",
+	"value":      "数值",
+	"level":      "日志级别",
+}
+
+// LogField 日志表的字段描述
+type LogField struct {
+	Name    string        `json:"key"` // 列名，与前端字段选择器的key一致
+	Type    string        `json:"type"`
+	Comment string        `json:"comment,omitempty"`
+	Label   string        `json:"label,omitempty"`
+	Aliases []string      `json:"aliases,omitempty"` // 可在查询语言中使用的其他名称
+	Mapping *FieldMapping `json:"mapping,omitempty"`
+	Stats   *FieldStats   `json:"stats,omitempty"`
+}
+
+// FieldStats 字段在指定时间范围内的统计
+type FieldStats struct {
+	Cardinality uint64   `json:"cardinality"` // uniq估算的不同值个数
+	NullRatio   float64  `json:"null_ratio"`
+	EmptyRatio  float64  `json:"empty_ratio"`
+	TopValues   []string `json:"top_values"`
+}
+
+// LogFieldsOptions 字段查询选项
+type LogFieldsOptions struct {
+	database.QueryOptions
+	Columns   []string // 为空表示全部列
+	TopK      int
+	WithStats bool
+}
+
+// GetLogFields 从日志存储获取日志表的字段，合并别名和字段映射，
+// 并按需统计时间范围内各字段的基数、空值比例和高频值，返回字段列表和统计的行数
+func GetLogFields(ctx context.Context, store LogStore, options LogFieldsOptions) ([]LogField, uint64, error) {
+	fields, err := store.Fields(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	fields = filterLogFields(fields, options.Columns, options.AppID)
+	decorateLogFields(fields, options.AppID)
+
+	if !options.WithStats || len(fields) == 0 {
+		return fields, 0, nil
+	}
+
+	total, err := store.FieldStats(ctx, options, fields)
+	if err != nil {
+		return nil, 0, err
+	}
+	return fields, total, nil
+}
+
+// fieldTopK 返回高频值个数，未指定时为DefaultFieldTopK，最多MaxFieldTopK
+func fieldTopK(options LogFieldsOptions) int {
+	if options.TopK <= 0 {
+		return DefaultFieldTopK
+	}
+	return min(options.TopK, MaxFieldTopK)
+}
+
+// filterLogFields 只保留请求的列，列名可以是别名或映射名
+func filterLogFields(fields []LogField, columns []string, appID string) []LogField {
+	if len(columns) == 0 {
+		return fields
+	}
+
+	schema := kv7Schema{appID: appID}
+	wanted := make(map[string]bool, len(columns))
+	for _, c := range columns {
+		if column, _, ok := schema.Resolve(c); ok {
+			c = column
+		}
+		wanted[c] = true
+	}
+
+	filtered := fields[:0]
+	for _, f := range fields {
+		if wanted[f.Name] {
+			filtered = append(filtered, f)
+		}
+	}
+	return filtered
+}
+
+// decorateLogFields 补充字段的中文名称、别名和应用的字段映射
+func decorateLogFields(fields []LogField, appID string) {
+	mappings := make(map[string]FieldMapping)
+	for _, m := range fieldMappings.Effective(appID) {
+		mappings[m.Column] = m
+	}
+
+	for i := range fields {
+		f := &fields[i]
+		f.Label = fieldLabels[f.Name]
+
+		for alias, column := range fieldAliases {
+			if column == f.Name {
+				f.Aliases = append(f.Aliases, alias)
+			}
+		}
+		sort.Strings(f.Aliases)
+
+		if m, ok := mappings[f.Name]; ok {
+			mapping := m
+			f.Mapping = &mapping
+			f.Aliases = append(f.Aliases, m.Name)
+			if m.Description != "" {
+				f.Label = m.Description
+			}
+		}
+	}
+}
+
+// collectFieldStats 一次扫描统计所有字段，返回时间范围内的总行数
+func collectFieldStats(ctx context.Context, conn *database.ClickHouseDB, table string, fields []LogField, options LogFieldsOptions) (uint64, error) {
+	topK := fieldTopK(options)
+
+	exprs := []string{"count()"}
+	for _, f := range fields {
+		column := quoteIdentifier(f.Name)
+		exprs = append(exprs,
+			fmt.Sprintf("uniq(%s)", column),
+			fmt.Sprintf("countIf(%s)", nullCondition(column, f.Type)),
+			fmt.Sprintf("countIf(%s)", emptyCondition(column, f.Type)),
+			fmt.Sprintf("arrayMap(x -> toString(x), topK(%d)(%s))", topK, column),
+		)
+	}
+
+	conditions, args, err := buildFilterConditions(options.QueryOptions)
+	if err != nil {
+		return 0, err
+	}
+	if !options.StartTime.IsZero() {
+		conditions = append(conditions, "data_time >= ?")
+		args = append(args, options.StartTime)
+	}
+	if !options.EndTime.IsZero() {
+		conditions = append(conditions, "data_time <= ?")
+		args = append(args, options.EndTime)
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(exprs, ", "), table)
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total uint64
+	cardinality := make([]uint64, len(fields))
+	nulls := make([]uint64, len(fields))
+	empties := make([]uint64, len(fields))
+	tops := make([][]string, len(fields))
+
+	dest := []interface{}{&total}
+	for i := range fields {
+		dest = append(dest, &cardinality[i], &nulls[i], &empties[i], &tops[i])
+	}
+
+	if err := conn.QueryRowContext(ctx, query, args...).Scan(dest...); err != nil {
+		return 0, fmt.Errorf("统计字段失败: %w", err)
+	}
+
+	for i := range fields {
+		stats := &FieldStats{
+			Cardinality: cardinality[i],
+			TopValues:   tops[i],
+		}
+		if total > 0 {
+			stats.NullRatio = float64(nulls[i]) / float64(total)
+			stats.EmptyRatio = float64(empties[i]) / float64(total)
+		}
+		if stats.TopValues == nil {
+			stats.TopValues = []string{}
+		}
+		fields[i].Stats = stats
+	}
+	return total, nil
+}
+
+// nullCondition 只有Nullable列可能为NULL
+func nullCondition(column, columnType string) string {
+	if strings.Contains(columnType, "Nullable(") {
+		return fmt.Sprintf("isNull(%s)", column)
+	}
+	return "0"
+}
+
+// emptyCondition 字符串、数组和Map列统计空值，其余类型没有空值的概念
+func emptyCondition(column, columnType string) string {
+	switch {
+	case strings.Contains(columnType, "String"),
+		strings.HasPrefix(columnType, "Array("),
+		strings.HasPrefix(columnType, "Map("):
+		return fmt.Sprintf("empty(%s)", column)
+	}
+	return "0"
+}
+
+// quoteIdentifier 以反引号引用标识符
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "\\`") + "`"
+}
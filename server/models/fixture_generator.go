@@ -0,0 +1,342 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// FixtureConfig 固定测试数据的生成参数，参数相同时生成的数据完全相同
+type FixtureConfig struct {
+	// Seed 随机种子
+	Seed int64
+	// Count 生成的记录数
+	Count int
+	// BaseTime 数据的截止时间，所有记录都不晚于该时间
+	BaseTime time.Time
+	// Users 用户数，每个用户有固定的应用、平台、设备、网络和地区
+	Users int
+	// ErrorBursts 错误突增的次数，每次在几分钟内集中产生大量相同的错误
+	ErrorBursts int
+}
+
+// DefaultFixtureConfig 返回fixture数据源使用的生成参数
+func DefaultFixtureConfig(baseTime time.Time) FixtureConfig {
+	return FixtureConfig{
+		Seed:        FixtureSeed,
+		Count:       FixtureCount,
+		BaseTime:    baseTime,
+		Users:       50,
+		ErrorBursts: 3,
+	}
+}
+
+// FixtureRecords 按默认参数生成count条固定的测试数据，截止时间为baseTime，按data_time倒序排列
+func FixtureRecords(count int, baseTime time.Time) []KV7Record {
+	config := DefaultFixtureConfig(baseTime)
+	config.Count = count
+	return GenerateFixtures(config)
+}
+
+// fixtureUser 生成数据中的一个用户，同一用户的会话使用相同的设备和网络
+type fixtureUser struct {
+	id       string
+	appID    string
+	platform string
+	model    string
+	osVer    string
+	version  string
+	deviceID string
+	network  string
+	region   string
+}
+
+// fixtureStep 用户路径中的一步
+type fixtureStep struct {
+	category string
+	action   string
+	label    string
+	page     string
+	message  string
+}
+
+// fixtureJourneys 用户路径模板，每个会话按其中一条路径依次产生日志，PERF_NET_SSE为接口请求
+var fixtureJourneys = [][]fixtureStep{
+	{
+		{"PAGE_VIEW", "page_load", "UI", "/login", "页面访问 /login"},
+		{"USER_ACTION", "login", "Auth", "/login", "用户点击了登录按钮"},
+		{"PERF_NET_SSE", "api_call", "Network", "/api/login", "接口请求 /api/login"},
+		{"PAGE_VIEW", "page_load", "UI", "/home", "页面访问 /home"},
+		{"USER_ACTION", "view", "UI", "/home", "用户页面上正在查看数据报表服务"},
+	},
+	{
+		{"PAGE_VIEW", "page_load", "UI", "/documents", "页面访问 /documents"},
+		{"PERF_NET_SSE", "api_call", "Network", "/api/documents", "接口请求 /api/documents"},
+		{"USER_ACTION", "click", "UI", "/documents", "用户打开了文档"},
+		{"PAGE_VIEW", "page_load", "UI", "/editor", "页面访问 /editor"},
+		{"PERF_NET_SSE", "api_call", "Network", "/api/documents/content", "接口请求 /api/documents/content"},
+		{"USER_ACTION", "submit", "API", "/editor", "用户提交了表单"},
+		{"PERF_NET_SSE", "api_call", "Network", "/api/documents/save", "接口请求 /api/documents/save"},
+	},
+	{
+		{"PAGE_VIEW", "page_load", "UI", "/dashboard", "页面访问 /dashboard"},
+		{"PERF_NET_SSE", "api_call", "Network", "/api/metrics", "接口请求 /api/metrics"},
+		{"USER_ACTION", "view", "Performance", "/dashboard", "用户查看了性能面板"},
+		{"USER_ACTION", "click", "UI", "/dashboard", "用户切换了时间范围"},
+		{"PERF_NET_SSE", "api_call", "Network", "/api/metrics", "接口请求 /api/metrics"},
+	},
+	{
+		{"PAGE_VIEW", "page_load", "UI", "/live", "页面访问 /live"},
+		{"PERF_NET_SSE", "api_call", "Network", "/api/live/token", "接口请求 /api/live/token"},
+		{"USER_ACTION", "click", "UI", "/live", "用户开始播放"},
+		{"USER_ACTION", "view", "UI", "/live", "自定义事件上报"},
+	},
+}
+
+// fixtureErrors 错误日志的消息，错误突增时集中出现其中一条
+var fixtureErrors = []string{
+	"Script error. @ (:0:0)",
+	"TypeError: Cannot read properties of undefined (reading 'id')",
+	"接口请求超时: /api/documents",
+	"WebSocket连接断开",
+}
+
+// fixtureNetworkLatency 各网络类型的基础耗时(毫秒)
+var fixtureNetworkLatency = map[string]int{
+	"WIFI": 20, "5G": 30, "4G": 60, "3G": 150, "NoNetwork": 400, "": 50,
+}
+
+// GenerateFixtures 生成模拟真实使用情况的kv_7数据：
+// 用户会话按路径模板产生页面访问、用户操作和接口请求，接口请求在v1-v5记录总耗时和DNS/TCP/请求/响应耗时，
+// d38为网络类型，d40为地区；部分请求失败并产生错误日志；ErrorBursts次错误突增在几分钟内集中产生相同的错误。
+// 白天的会话多于夜间(按北京时间)。返回的记录按data_time倒序排列，ID为fixture_000000起的序号
+func GenerateFixtures(config FixtureConfig) []KV7Record {
+	if config.Count <= 0 {
+		return []KV7Record{}
+	}
+	g := &fixtureGenerator{
+		r:      rand.New(rand.NewSource(config.Seed)),
+		config: config,
+		span:   time.Duration(config.Count) * fixtureInterval,
+	}
+	g.users = g.newUsers(max(config.Users, 1))
+
+	// 错误突增约占全部数据的十分之一
+	if config.ErrorBursts > 0 {
+		size := max(config.Count/10/config.ErrorBursts, 1)
+		for i := 0; i < config.ErrorBursts; i++ {
+			g.errorBurst(size)
+		}
+	}
+	for session := 0; len(g.records) < config.Count; session++ {
+		g.session(session)
+	}
+
+	sort.SliceStable(g.records, func(i, j int) bool {
+		return g.records[i].DataTime.After(g.records[j].DataTime)
+	})
+	records := g.records[:config.Count]
+	for i := range records {
+		records[i].ID = fmt.Sprintf("fixture_%06d", i)
+	}
+	return records
+}
+
+// fixtureGenerator 保存生成过程中的状态
+type fixtureGenerator struct {
+	r       *rand.Rand
+	config  FixtureConfig
+	span    time.Duration
+	users   []fixtureUser
+	records []KV7Record
+}
+
+func (g *fixtureGenerator) pick(values []string) string {
+	return values[g.r.Intn(len(values))]
+}
+
+// newUsers 生成固定的用户列表
+func (g *fixtureGenerator) newUsers(count int) []fixtureUser {
+	appIDs := []string{"腾讯云前端监控项目Web-?20000.2:demo", "腾讯文档Web-10001", "腾讯云音视频项目-30001"}
+	platforms := []string{"ios", "android", "web"}
+	devices := map[string][]string{
+		"ios":     {"iPhone 12", "iPhone13", "iPhone 13 Pro", "iPhone 14", "iPhone 7"},
+		"android": {"Samsung Galaxy S22", "Google Pixel 7", "Xiaomi 13"},
+		"web":     {"Chrome", "Safari", "Firefox"},
+	}
+	oses := map[string][]string{
+		"ios":     {"16.5", "15.4.1", "15.1", "14.8"},
+		"android": {"13", "12", "11"},
+		"web":     {"Windows 10", "macOS 13", "Linux"},
+	}
+	versions := []string{"4.1.3", "4.2.8", "4.3.5", "4.6.6", "4.8.6"}
+	networks := []string{"WIFI", "WIFI", "WIFI", "5G", "4G", "4G", "3G", "NoNetwork", ""}
+	regions := []string{"中国广东", "中国广东", "中国北京", "中国上海", "中国浙江", "中国香港", ""}
+
+	users := make([]fixtureUser, count)
+	for i := range users {
+		platform := g.pick(platforms)
+		users[i] = fixtureUser{
+			id:       fmt.Sprintf("%d", 17430+i*997%50000),
+			appID:    g.pick(appIDs),
+			platform: platform,
+			model:    g.pick(devices[platform]),
+			osVer:    g.pick(oses[platform]),
+			version:  g.pick(versions),
+			deviceID: fmt.Sprintf("DV2025%04d", i+1),
+			network:  g.pick(networks),
+			region:   g.pick(regions),
+		}
+	}
+	return users
+}
+
+// randomTime 在数据的时间范围内选择一个时间，北京时间夜间被选中的概率较低
+func (g *fixtureGenerator) randomTime() time.Time {
+	for {
+		t := g.config.BaseTime.Add(-time.Duration(g.r.Int63n(int64(g.span))))
+		hour := (t.UTC().Hour() + 8) % 24
+		weight := 1.0
+		if hour < 7 || hour >= 23 {
+			weight = 0.2
+		}
+		if g.r.Float64() < weight {
+			return t.Truncate(time.Second)
+		}
+	}
+}
+
+// record 创建一条属于用户的基础记录
+func (g *fixtureGenerator) record(u fixtureUser, t time.Time, step fixtureStep) KV7Record {
+	return KV7Record{
+		DataTime: t,
+		// 写入时间晚于上报时间几秒
+		WriteTime: t.Add(time.Duration(1+g.r.Intn(5)) * time.Second),
+		TimeHour:  t.Format("2006-01-02 15"),
+		Time:      t.UnixMilli(),
+		AppID:     u.appID,
+		Platform:  u.platform,
+		UserID:    u.id,
+		Version:   u.version,
+		DeviceID:  u.deviceID,
+		Model:     u.model,
+		OS:        u.platform,
+		OSVer:     u.osVer,
+		SDKVer:    "1.39.1",
+		Category:  step.category,
+		Action:    step.action,
+		Label:     step.label,
+		State:     "success",
+		Level:     "INFO",
+		D1:        step.message,
+		D2:        "页面路径: " + step.page,
+		D3:        fmt.Sprintf("设备信息: %s %s", u.model, u.osVer),
+	}
+}
+
+// add 添加不晚于截止时间的记录
+func (g *fixtureGenerator) add(record KV7Record) {
+	if !record.DataTime.After(g.config.BaseTime) {
+		g.records = append(g.records, record)
+	}
+}
+
+// session 按一条路径模板生成一个用户会话
+func (g *fixtureGenerator) session(n int) {
+	u := g.users[g.r.Intn(len(g.users))]
+	journey := fixtureJourneys[g.r.Intn(len(fixtureJourneys))]
+	start := g.randomTime()
+	sessionID := fmt.Sprintf("session_%06d", n)
+
+	t := start
+	for i, step := range journey {
+		record := g.record(u, t, step)
+		record.EntranceTime = start.Unix()
+		record.EntranceID = sessionID
+		record.Stamp = int32(i + 1)
+		record.Extra = fixtureExtra(step.page, sessionID)
+		record.Value = int32(g.r.Intn(100))
+
+		failed := false
+		if step.category == "PERF_NET_SSE" {
+			failed = g.networkTimings(&record, u)
+		}
+		g.add(record)
+
+		if failed {
+			errorRecord := g.record(u, t.Add(time.Second), fixtureStep{
+				category: "ERROR", action: "error", label: "API", page: step.page,
+				message: fmt.Sprintf("接口请求失败: %s", step.page),
+			})
+			errorRecord.EntranceTime = start.Unix()
+			errorRecord.EntranceID = sessionID
+			errorRecord.Stamp = int32(i + 1)
+			errorRecord.State = "failure"
+			errorRecord.Level = "ERROR"
+			g.add(errorRecord)
+			// 请求失败后用户放弃当前路径
+			return
+		}
+		t = t.Add(time.Duration(5+g.r.Intn(85)) * time.Second)
+	}
+}
+
+// networkTimings 填充接口请求的耗时、网络类型和地区，返回请求是否失败
+// 耗时过长时记录为WARN级别，并额外产生一条WARNING日志
+func (g *fixtureGenerator) networkTimings(record *KV7Record, u fixtureUser) bool {
+	base := fixtureNetworkLatency[u.network]
+	record.D38 = u.network
+	record.D40 = u.region
+	record.V2 = int64(base/2 + g.r.Intn(base+10))
+	record.V3 = int64(base + g.r.Intn(base*2+10))
+	record.V4 = int64(base + 20 + g.r.Intn(120))
+	record.V5 = int64(base/2 + 10 + g.r.Intn(100))
+	record.V1 = record.V2 + record.V3 + record.V4 + record.V5
+
+	// 网络越差失败率越高
+	if g.r.Intn(1000) < base/2+10 {
+		record.State = "failure"
+		record.Level = "ERROR"
+		return true
+	}
+	if record.V1 > 800 {
+		record.Level = "WARN"
+		warning := *record
+		warning.Category = "WARNING"
+		warning.Action = "slow_api"
+		warning.D1 = fmt.Sprintf("接口响应缓慢(%dms): %s", record.V1, record.D1)
+		warning.DataTime = record.DataTime.Add(time.Second)
+		warning.Time = warning.DataTime.UnixMilli()
+		g.add(warning)
+	}
+	return false
+}
+
+// errorBurst 在几分钟内产生size条相同的错误，来自多个用户
+func (g *fixtureGenerator) errorBurst(size int) {
+	start := g.randomTime()
+	message := g.pick(fixtureErrors)
+	for i := 0; i < size; i++ {
+		u := g.users[g.r.Intn(len(g.users))]
+		t := start.Add(time.Duration(g.r.Intn(300)) * time.Second)
+		record := g.record(u, t, fixtureStep{
+			category: "ERROR", action: "error", label: "UI", page: "/documents", message: message,
+		})
+		record.State = "failure"
+		record.Level = "ERROR"
+		record.Extra = fixtureExtra("/documents", "")
+		g.add(record)
+	}
+}
+
+// fixtureExtra extra列中的JSON
+func fixtureExtra(page, session string) string {
+	extra := map[string]string{"page": page}
+	if session != "" {
+		extra["session"] = session
+	}
+	b, _ := json.Marshal(extra)
+	return string(b)
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"server/database"
+)
+
+const (
+	// FixtureSeed 固定测试数据的随机种子，相同的种子和基准时间生成相同的数据
+	FixtureSeed = 20240101
+	// FixtureCount 默认生成的固定测试数据条数
+	FixtureCount = 2000
+	// fixtureInterval 固定测试数据平均每条占用的时间，count条数据分布在count*fixtureInterval的时间范围内
+	fixtureInterval = 3 * time.Minute
+)
+
+// DefaultFixtureBaseTime 固定测试数据默认的基准时间，最新一条记录的时间
+var DefaultFixtureBaseTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// FixtureBaseTime 读取DATA_FIXTURE_BASE_TIME(RFC3339)，为now时使用当前整点，未设置时使用DefaultFixtureBaseTime
+func FixtureBaseTime() (time.Time, error) {
+	value := strings.TrimSpace(os.Getenv("DATA_FIXTURE_BASE_TIME"))
+	switch value {
+	case "":
+		return DefaultFixtureBaseTime, nil
+	case "now":
+		return time.Now().UTC().Truncate(time.Hour), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("DATA_FIXTURE_BASE_TIME格式错误，应为RFC3339: %w", err)
+	}
+	return t, nil
+}
+
+// fixtureFile 固定测试数据文件，由DATA_FIXTURE_FILE配置
+func fixtureFile() string {
+	return strings.TrimSpace(os.Getenv("DATA_FIXTURE_FILE"))
+}
+
+// loadFixtureFile 读取每行一条KV7Record的NDJSON文件
+func loadFixtureFile(path string) ([]KV7Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开固定测试数据文件失败: %w", err)
+	}
+	defer file.Close()
+
+	records := []KV7Record{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var record KV7Record
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			return nil, fmt.Errorf("固定测试数据第%d行格式错误: %w", line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取固定测试数据文件失败: %w", err)
+	}
+	return records, nil
+}
+
+// fixtureSource fixture数据源的SQL控制台数据，从日志存储读取，写入接口写入的记录也可以查到
+type fixtureSource struct {
+	store *MemoryStore
+}
+
+func (s *fixtureSource) SQLRows(query string) []map[string]interface{} {
+	queryLower := strings.ToLower(query)
+	if strings.Contains(queryLower, "show tables") {
+		return []map[string]interface{}{{"name": "kv_7"}}
+	}
+	if strings.Contains(queryLower, "describe") {
+		return mockDescribeRows()
+	}
+
+	// 按data_time倒序取前几行
+	columns := KV7Columns()
+	records, err := s.store.Query(context.Background(), database.QueryOptions{Limit: mockSQLLimit(query)}, columns)
+	if err != nil {
+		log.Printf("读取固定测试数据失败: %v", err)
+		return []map[string]interface{}{}
+	}
+	rows := make([]map[string]interface{}, 0, len(records))
+	for _, record := range records {
+		values := record.Values()
+		row := make(map[string]interface{}, len(columns))
+		for j, column := range columns {
+			row[column] = values[j]
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func (s *fixtureSource) Tables() []string {
+	return []string{"kv_7"}
+}
+
+func (s *fixtureSource) TableFields(table string) []map[string]string {
+	fields := make([]map[string]string, 0, len(kv7Fields))
+	for _, f := range kv7Fields {
+		fields = append(fields, map[string]string{"name": f.Column, "type": f.Type})
+	}
+	return fields
+}
+
+func (s *fixtureSource) ExplainLines(kind string) []string {
+	return mockExplainLines(kind)
+}
+
+func (s *fixtureSource) EstimatedRows() uint64 {
+	count, _ := s.store.Count(context.Background(), database.QueryOptions{})
+	return uint64(count)
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"server/database"
+)
+
+// MaxIngestBatchSize 单次写入允许的最大记录数
+const MaxIngestBatchSize = 10000
+
+// maxFutureSkew 允许data_time超前服务器时间的最大偏差
+const maxFutureSkew = 24 * time.Hour
+
+// ValidationError 表示单条记录的校验错误
+type ValidationError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Reason)
+}
+
+// ValidateKV7Record 校验待写入的kv_7记录
+func ValidateKV7Record(r *KV7Record, now time.Time) *ValidationError {
+	if strings.TrimSpace(r.ID) == "" {
+		return &ValidationError{Field: "id", Reason: "不能为空"}
+	}
+
+	if r.DataTime.IsZero() {
+		return &ValidationError{Field: "data_time", Reason: "不能为空"}
+	}
+
+	if r.DataTime.After(now.Add(maxFutureSkew)) {
+		return &ValidationError{Field: "data_time", Reason: "时间超前服务器时间过多"}
+	}
+
+	return nil
+}
+
+// PrepareKV7Record 填充由服务端生成的字段
+func PrepareKV7Record(r *KV7Record, now time.Time) {
+	r.WriteTime = now
+	r.TimeHour = r.DataTime.Format("2006-01-02 15")
+	if r.Time == 0 {
+		r.Time = r.DataTime.UnixNano() / 1000000
+	}
+}
+
+// ingestQueue 异步写入队列，未启动时为nil
+var ingestQueue *database.IngestQueue
+
+// ingestStore 内存存储不使用落盘队列，异步写入直接写入该存储
+var ingestStore LogStore
+
+// StartIngestQueue 启动kv_7异步写入队列，批次写入store
+// 内存存储的写入不会失败，也不应回放ClickHouse模式遗留的落盘批次，因此只记录store
+func StartIngestQueue(store LogStore) error {
+	if _, ok := store.(*ClickHouseStore); !ok {
+		ingestStore = store
+		return nil
+	}
+
+	queue, err := database.NewIngestQueue(database.DefaultIngestQueueConfig(), func(ctx context.Context, batch *database.IngestBatch) error {
+		return writeKV7Batch(ctx, store, batch)
+	})
+	if err != nil {
+		return err
+	}
+	ingestQueue = queue
+	return nil
+}
+
+// StopIngestQueue 停止写入队列，剩余记录写入或落盘
+func StopIngestQueue() error {
+	if ingestQueue == nil {
+		return nil
+	}
+	return ingestQueue.Close()
+}
+
+// IngestQueueStats 返回写入队列状态
+func IngestQueueStats() map[string]interface{} {
+	if ingestQueue == nil {
+		return nil
+	}
+	return ingestQueue.Stats()
+}
+
+// EnqueueKV7Records 将已校验的记录交给异步写入队列，服务器停止后返回database.ErrQueueClosed
+func EnqueueKV7Records(records []KV7Record) error {
+	if ingestQueue == nil {
+		if ingestStore != nil {
+			return ingestStore.Insert(context.Background(), records)
+		}
+		return fmt.Errorf("异步写入队列未启动")
+	}
+	queued := make([]interface{}, len(records))
+	for i := range records {
+		queued[i] = &records[i]
+	}
+	return ingestQueue.Enqueue(queued...)
+}
+
+// writeKV7Batch 解码队列批次并写入日志存储
+func writeKV7Batch(ctx context.Context, store LogStore, batch *database.IngestBatch) error {
+	records := make([]KV7Record, len(batch.Records))
+	for i, raw := range batch.Records {
+		if err := json.Unmarshal(raw, &records[i]); err != nil {
+			return fmt.Errorf("%w: 解码批次 %s 第%d条记录失败: %v", database.ErrBatchRejected, batch.ID, i, err)
+		}
+	}
+	return store.Insert(ctx, records)
+}
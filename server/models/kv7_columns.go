@@ -0,0 +1,193 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"server/rowstream"
+)
+
+// kv7Field 描述KV7Record字段与kv_7表列的对应关系
+type kv7Field struct {
+	Column string
+	Index  int
+	Type   string
+}
+
+// kv7Fields 按结构体定义顺序排列的全部列
+var kv7Fields = buildKV7Fields()
+
+// kv7FieldIndex 列名到字段描述的索引
+var kv7FieldIndex = func() map[string]kv7Field {
+	index := make(map[string]kv7Field, len(kv7Fields))
+	for _, f := range kv7Fields {
+		index[f.Column] = f
+	}
+	return index
+}()
+
+// kv7Converters 与kv7Fields对应的JSON转换函数，与SQL结果使用相同的rowstream规则
+var kv7Converters = func() []rowstream.Converter {
+	converters := make([]rowstream.Converter, len(kv7Fields))
+	for i, f := range kv7Fields {
+		converters[i] = rowstream.ConverterFor(f.Type)
+	}
+	return converters
+}()
+
+// buildKV7Fields 通过json标签解析KV7Record对应的列名和ClickHouse类型
+func buildKV7Fields() []kv7Field {
+	t := reflect.TypeOf(KV7Record{})
+	fields := make([]kv7Field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		column := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if column == "" || column == "-" {
+			continue
+		}
+
+		var chType string
+		switch sf.Type {
+		case reflect.TypeOf(time.Time{}):
+			chType = "DateTime"
+		case reflect.TypeOf(int64(0)):
+			chType = "Int64"
+		case reflect.TypeOf(int32(0)):
+			chType = "Int32"
+		default:
+			chType = "String"
+		}
+
+		fields = append(fields, kv7Field{Column: column, Index: i, Type: chType})
+	}
+	return fields
+}
+
+// KV7Columns 返回kv_7表全部列名，顺序与KV7Record字段定义一致
+func KV7Columns() []string {
+	columns := make([]string, len(kv7Fields))
+	for i, f := range kv7Fields {
+		columns[i] = f.Column
+	}
+	return columns
+}
+
+// IsKV7Column 判断列名是否属于kv_7表
+func IsKV7Column(column string) bool {
+	_, ok := kv7FieldIndex[column]
+	return ok
+}
+
+// KV7ColumnType 返回列的ClickHouse类型，未知列返回空字符串
+func KV7ColumnType(column string) string {
+	return kv7FieldIndex[column].Type
+}
+
+// KV7ResultColumns 返回响应中columns字段的列信息，columns为空时为日志列表读取的列
+func KV7ResultColumns(columns []string) []rowstream.Column {
+	if len(columns) == 0 {
+		columns = logListColumns
+	}
+	result := make([]rowstream.Column, len(columns))
+	for i, column := range columns {
+		result[i] = rowstream.Column{Name: column, Type: KV7ColumnType(column)}
+	}
+	return result
+}
+
+// MarshalJSON 按列的ClickHouse类型转换后输出，Int64为十进制字符串、DateTime为RFC3339，
+// 与/api/query、/api/sql/execute等接口中同一列的格式一致
+func (r KV7Record) MarshalJSON() ([]byte, error) {
+	v := reflect.ValueOf(&r).Elem()
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range kv7Fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		value, err := json.Marshal(kv7Converters[i](v.Field(f.Index).Interface()))
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(strconv.Quote(f.Column))
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON 与MarshalJSON对应，整数列同时接受数字和十进制字符串，未知的键忽略
+func (r *KV7Record) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(r).Elem()
+	for column, value := range raw {
+		f, ok := kv7FieldIndex[column]
+		if !ok {
+			continue
+		}
+		field := v.Field(f.Index)
+		if field.Kind() == reflect.Int64 || field.Kind() == reflect.Int32 {
+			if text := bytes.TrimSpace(value); len(text) > 0 && text[0] == '"' {
+				var s string
+				if err := json.Unmarshal(text, &s); err != nil {
+					return fmt.Errorf("列%s: %w", column, err)
+				}
+				n, err := strconv.ParseInt(s, 10, field.Type().Bits())
+				if err != nil {
+					return fmt.Errorf("列%s的值%q不是有效的整数", column, s)
+				}
+				field.SetInt(n)
+				continue
+			}
+		}
+		if err := json.Unmarshal(value, field.Addr().Interface()); err != nil {
+			return fmt.Errorf("列%s: %w", column, err)
+		}
+	}
+	return nil
+}
+
+// Values 按KV7Columns的顺序返回记录的全部列值
+func (r *KV7Record) Values() []interface{} {
+	v := reflect.ValueOf(r).Elem()
+	values := make([]interface{}, len(kv7Fields))
+	for i, f := range kv7Fields {
+		values[i] = v.Field(f.Index).Interface()
+	}
+	return values
+}
+
+// Pointers 返回指定列对应字段的指针，用于rows.Scan
+func (r *KV7Record) Pointers(columns []string) []interface{} {
+	v := reflect.ValueOf(r).Elem()
+	ptrs := make([]interface{}, len(columns))
+	for i, column := range columns {
+		if f, ok := kv7FieldIndex[column]; ok {
+			ptrs[i] = v.Field(f.Index).Addr().Interface()
+		} else {
+			// 未知列扫描到占位变量中
+			var discard interface{}
+			ptrs[i] = &discard
+		}
+	}
+	return ptrs
+}
+
+// Get 获取指定列的值
+func (r *KV7Record) Get(column string) (interface{}, bool) {
+	f, ok := kv7FieldIndex[column]
+	if !ok {
+		return nil, false
+	}
+	return reflect.ValueOf(r).Elem().Field(f.Index).Interface(), true
+}
@@ -3,10 +3,8 @@ package models
 import (
 	"context"
 	"fmt"
-	"log"
-	"math/rand"
-	"os"
-	"strings"
+	"math"
+	"sort"
 	"time"
 
 	"server/database"
@@ -222,249 +220,61 @@ type UserDistribution struct {
 	Percent float64 `json:"percent"`
 }
 
-// GetRecentKV7Data 获取最近的数据记录
-func GetRecentKV7Data(options database.QueryOptions) ([]KV7Record, error) {
-	conn := database.GetClickHouseConn()
-
-	// 由于字段太多，查询时仅选择核心字段，降低传输量
-	query := `
-	SELECT 
-		data_time, write_time, time_hour, id, time, extra, 
-		entrance_time, entrance_id, stamp, app_id, platform, 
-		user_id, version, build_id, device_id, model, os, os_ver, 
-		sdk_ver, category, action, label, state, value
-	FROM test_db.kv_7
-	WHERE data_time BETWEEN ? AND ?
-	`
-
-	// 应用筛选条件
-	args := []interface{}{options.StartTime, options.EndTime}
-
-	if options.Category != "" {
-		query += " AND category = ?"
-		args = append(args, options.Category)
-	}
-
-	if options.Action != "" {
-		query += " AND action = ?"
-		args = append(args, options.Action)
-	}
-
-	if options.Platform != "" {
-		query += " AND platform = ?"
-		args = append(args, options.Platform)
-	}
-
-	if options.AppID != "" {
-		query += " AND app_id = ?"
-		args = append(args, options.AppID)
-	}
-
-	if options.UserID != "" {
-		query += " AND user_id = ?"
-		args = append(args, options.UserID)
-	}
-
-	if options.OS != "" {
-		query += " AND os = ?"
-		args = append(args, options.OS)
-	}
-
-	if options.Version != "" {
-		query += " AND version = ?"
-		args = append(args, options.Version)
-	}
-
-	// 添加排序和分页
-	sortField := options.SortBy
-	if sortField == "" {
-		sortField = "data_time"
-	}
-
-	sortOrder := options.SortOrder
-	if sortOrder == "" {
-		sortOrder = "DESC"
-	}
-
-	query += fmt.Sprintf(" ORDER BY %s %s LIMIT ? OFFSET ?", sortField, sortOrder)
-	args = append(args, options.Limit, options.Offset)
-
-	// 执行查询
-	rows, err := conn.Query(query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("查询KV7数据失败: %w", err)
-	}
-	defer rows.Close()
-
-	var records []KV7Record
-	for rows.Next() {
-		var r KV7Record
-		err := rows.Scan(
-			&r.DataTime, &r.WriteTime, &r.TimeHour, &r.ID, &r.Time, &r.Extra,
-			&r.EntranceTime, &r.EntranceID, &r.Stamp, &r.AppID, &r.Platform,
-			&r.UserID, &r.Version, &r.BuildID, &r.DeviceID, &r.Model, &r.OS, &r.OSVer,
-			&r.SDKVer, &r.Category, &r.Action, &r.Label, &r.State, &r.Value,
-		)
-		if err != nil {
-			log.Printf("扫描行数据失败: %v", err)
-			continue
-		}
-		records = append(records, r)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("读取结果集失败: %w", err)
-	}
-
-	return records, nil
-}
-
-// GetFullKV7Record 获取单条完整记录（包含所有字段）
-func GetFullKV7Record(id string) (*KV7Record, error) {
-	conn := database.GetClickHouseConn()
-
-	query := `
-	SELECT 
-		data_time, write_time, time_hour, id, time, extra, 
-		entrance_time, entrance_id, stamp, app_id, platform, 
-		user_id, version, build_id, device_id, model, os, os_ver, 
-		sdk_ver, category, action, label, state, value,
-		d1, d2, d3, d4, d5, d6, d7, d8, d9, d10,
-		d11, d12, d13, d14, d15, d16, d17, d18, d19, d20,
-		d21, d22, d23, d24, d25, d26, d27, d28, d29, d30,
-		d31, d32, d33, d34, d35, d36, d37, d38, d39, d40,
-		v1, v2, v3, v4, v5, v6, v7, v8, v9, v10,
-		v11, v12, v13, v14, v15, v16, v17, v18, v19, v20,
-		v21, v22, v23, v24, v25, v26, v27, v28, v29, v30,
-		v31, v32, v33, v34, v35, v36, v37, v38, v39, v40,
-		info1, info2, info3, info4, info5, info6, info7, info8, info9, info10,
-		ud1, ud2, ud3, ud4, ud5, ud6, ud7, ud8, ud9, ud10,
-		ud11, ud12, ud13, ud14, ud15, ud16, ud17, ud18, ud19, ud20,
-		uv1, uv2, uv3, uv4, uv5, uv6, uv7, uv8, uv9, uv10,
-		sd1, sd2, sd3, sd4, sd5, sd6, sd7, sd8, sd9, sd10,
-		sd11, sd12, sd13, sd14, sd15, sd16, sd17, sd18, sd19, sd20,
-		sv1, sv2, sv3, sv4, sv5, sv6, sv7, sv8, sv9, sv10
-	FROM test_db.kv_7
-	WHERE id = ?
-	LIMIT 1
-	`
-
-	row := conn.QueryRow(query, id)
-
-	var r KV7Record
-	err := row.Scan(
-		&r.DataTime, &r.WriteTime, &r.TimeHour, &r.ID, &r.Time, &r.Extra,
-		&r.EntranceTime, &r.EntranceID, &r.Stamp, &r.AppID, &r.Platform,
-		&r.UserID, &r.Version, &r.BuildID, &r.DeviceID, &r.Model, &r.OS, &r.OSVer,
-		&r.SDKVer, &r.Category, &r.Action, &r.Label, &r.State, &r.Value,
-		&r.D1, &r.D2, &r.D3, &r.D4, &r.D5, &r.D6, &r.D7, &r.D8, &r.D9, &r.D10,
-		&r.D11, &r.D12, &r.D13, &r.D14, &r.D15, &r.D16, &r.D17, &r.D18, &r.D19, &r.D20,
-		&r.D21, &r.D22, &r.D23, &r.D24, &r.D25, &r.D26, &r.D27, &r.D28, &r.D29, &r.D30,
-		&r.D31, &r.D32, &r.D33, &r.D34, &r.D35, &r.D36, &r.D37, &r.D38, &r.D39, &r.D40,
-		&r.V1, &r.V2, &r.V3, &r.V4, &r.V5, &r.V6, &r.V7, &r.V8, &r.V9, &r.V10,
-		&r.V11, &r.V12, &r.V13, &r.V14, &r.V15, &r.V16, &r.V17, &r.V18, &r.V19, &r.V20,
-		&r.V21, &r.V22, &r.V23, &r.V24, &r.V25, &r.V26, &r.V27, &r.V28, &r.V29, &r.V30,
-		&r.V31, &r.V32, &r.V33, &r.V34, &r.V35, &r.V36, &r.V37, &r.V38, &r.V39, &r.V40,
-		&r.Info1, &r.Info2, &r.Info3, &r.Info4, &r.Info5, &r.Info6, &r.Info7, &r.Info8, &r.Info9, &r.Info10,
-		&r.UD1, &r.UD2, &r.UD3, &r.UD4, &r.UD5, &r.UD6, &r.UD7, &r.UD8, &r.UD9, &r.UD10,
-		&r.UD11, &r.UD12, &r.UD13, &r.UD14, &r.UD15, &r.UD16, &r.UD17, &r.UD18, &r.UD19, &r.UD20,
-		&r.UV1, &r.UV2, &r.UV3, &r.UV4, &r.UV5, &r.UV6, &r.UV7, &r.UV8, &r.UV9, &r.UV10,
-		&r.SD1, &r.SD2, &r.SD3, &r.SD4, &r.SD5, &r.SD6, &r.SD7, &r.SD8, &r.SD9, &r.SD10,
-		&r.SD11, &r.SD12, &r.SD13, &r.SD14, &r.SD15, &r.SD16, &r.SD17, &r.SD18, &r.SD19, &r.SD20,
-		&r.SV1, &r.SV2, &r.SV3, &r.SV4, &r.SV5, &r.SV6, &r.SV7, &r.SV8, &r.SV9, &r.SV10,
-	)
-
-	if err != nil {
-		return nil, fmt.Errorf("获取完整记录失败: %w", err)
-	}
-
-	return &r, nil
-}
-
 // GetEventAnalytics 获取事件分析数据
-func GetEventAnalytics(startTime, endTime time.Time) ([]AnalyticsResult, error) {
-	conn := database.GetClickHouseConn()
-
-	query := `
-	SELECT 
-		category, action, COUNT(*) as count
-	FROM test_db.kv_7
-	WHERE data_time BETWEEN ? AND ?
-	GROUP BY category, action
-	ORDER BY count DESC
-	LIMIT 100
-	`
-
-	rows, err := conn.Query(query, startTime, endTime)
+func GetEventAnalytics(ctx context.Context, store LogStore, startTime, endTime time.Time) ([]AnalyticsResult, error) {
+	rows, err := store.Aggregate(ctx, database.QueryOptions{StartTime: startTime, EndTime: endTime}, Aggregation{
+		GroupBy: []string{"category", "action"},
+		Limit:   100,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("查询事件分析数据失败: %w", err)
 	}
-	defer rows.Close()
-
-	var results []AnalyticsResult
-	for rows.Next() {
-		var r AnalyticsResult
-		err := rows.Scan(&r.Category, &r.Action, &r.Count)
-		if err != nil {
-			log.Printf("扫描事件分析数据失败: %v", err)
-			continue
-		}
-		results = append(results, r)
-	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("读取结果集失败: %w", err)
+	results := make([]AnalyticsResult, 0, len(rows))
+	for _, row := range rows {
+		results = append(results, AnalyticsResult{
+			Category: row.Keys[0],
+			Action:   row.Keys[1],
+			Count:    int(row.Count),
+		})
 	}
-
 	return results, nil
 }
 
-// GetUserDistribution 获取用户设备分布数据
-func GetUserDistribution(startTime, endTime time.Time) ([]UserDistribution, error) {
-	conn := database.GetClickHouseConn()
+// GetUserDistribution 获取用户设备分布数据，按各操作系统的用户数降序排列
+// percent为该操作系统的用户数占时间范围内全部用户数的百分比，保留两位小数
+func GetUserDistribution(ctx context.Context, store LogStore, startTime, endTime time.Time) ([]UserDistribution, error) {
+	options := database.QueryOptions{StartTime: startTime, EndTime: endTime}
 
-	query := `
-	WITH total AS (
-		SELECT COUNT(DISTINCT user_id) as total_users
-		FROM test_db.kv_7
-		WHERE data_time BETWEEN ? AND ?
-	)
-	SELECT 
-		os, 
-		COUNT(DISTINCT user_id) as users,
-		ROUND(COUNT(DISTINCT user_id) * 100.0 / total_users, 2) as percent
-	FROM test_db.kv_7, total
-	WHERE data_time BETWEEN ? AND ?
-	GROUP BY os, total_users
-	ORDER BY users DESC
-	`
-
-	rows, err := conn.Query(query, startTime, endTime, startTime, endTime)
+	totals, err := store.Aggregate(ctx, options, Aggregation{Uniq: []string{"user_id"}})
+	if err != nil {
+		return nil, fmt.Errorf("查询用户总数失败: %w", err)
+	}
+	rows, err := store.Aggregate(ctx, options, Aggregation{GroupBy: []string{"os"}, Uniq: []string{"user_id"}})
 	if err != nil {
 		return nil, fmt.Errorf("查询用户分布数据失败: %w", err)
 	}
-	defer rows.Close()
 
-	var results []UserDistribution
-	for rows.Next() {
-		var r UserDistribution
-		err := rows.Scan(&r.OS, &r.Count, &r.Percent)
-		if err != nil {
-			log.Printf("扫描用户分布数据失败: %v", err)
-			continue
+	totalUsers := totals[0].Uniq[0]
+	results := make([]UserDistribution, 0, len(rows))
+	for _, row := range rows {
+		r := UserDistribution{OS: row.Keys[0], Count: int(row.Uniq[0])}
+		if totalUsers > 0 {
+			r.Percent = math.Round(float64(row.Uniq[0])*10000/float64(totalUsers)) / 100
 		}
 		results = append(results, r)
 	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("读取结果集失败: %w", err)
-	}
-
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Count != results[j].Count {
+			return results[i].Count > results[j].Count
+		}
+		return results[i].OS < results[j].OS
+	})
 	return results, nil
 }
 
 // GetRecentRecords 获取最近的数据记录
-func GetRecentRecords(startTime, endTime time.Time, category, action, platform string, limit int) ([]KV7Record, error) {
+func GetRecentRecords(ctx context.Context, store LogStore, startTime, endTime time.Time, category, action, platform string, limit int) ([]KV7Record, error) {
 	// 创建查询选项
 	options := database.QueryOptions{
 		StartTime: startTime,
@@ -474,304 +284,17 @@ func GetRecentRecords(startTime, endTime time.Time, category, action, platform s
 		Action:    action,
 		Platform:  platform,
 	}
-
-	// 调用已有的查询函数
-	records, _, err := QueryKV7Logs(options)
-	return records, err
-}
-
-// GenerateMockData 生成模拟KV7数据
-func GenerateMockData(count int) []KV7Record {
-	if count <= 0 {
-		count = 50
-	}
-
-	records := make([]KV7Record, 0, count)
-	now := time.Now()
-
-	// 模拟数据选项
-	platforms := []string{"iOS", "Android", "Web", "macOS", "Windows"}
-	categories := []string{"ERROR", "WARNING", "INFO", "DEBUG", "USER_ACTION", "PERFORMANCE"}
-	actions := []string{"click", "view", "login", "submit", "error", "api_call", "page_load"}
-	oses := []string{"iOS 16", "Android 13", "Windows 10", "macOS 13", "Linux"}
-	appIDs := []string{"腾讯云前端监控项目Web-?20000.2:demo", "腾讯文档Web-10001", "腾讯云音视频项目-30001"}
-	labels := []string{"UI", "Network", "Database", "Auth", "API", "Performance"}
-	states := []string{"success", "failure", "pending", "timeout"}
-	messages := []string{
-		"用户点击了登录按钮",
-		"页面加载完成",
-		"用户提交了表单",
-		"页面访问",
-		"自定义事件上报",
-		"用户页面上正在查看数据报表服务",
-		"Script error. @ (:0:0)",
-		"JSON对象: {\"id\":\"YRKRY18EXMI120000\",\"uid\":\"1743066412\",\"version\":\"1.39.1\"}",
-		"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko)",
-		"aegis.report 页面访问",
-		"用户登录成功,设备ID: DV20252703",
-	}
-	deviceIDs := []string{"DV20252701", "DV20252702", "DV20252703", "DV20252704", "DV20252705"}
-	models := []string{"iPhone 14", "Samsung Galaxy S22", "Google Pixel 7", "MacBook Pro", "ThinkPad X1"}
-	osVersions := []string{"16.5", "13.0", "10.0.19045", "13.3", "5.15.0"}
-	userIDs := []string{"17430", "66417", "66412", "66399", "65500"}
-
-	for i := 0; i < count; i++ {
-		// 生成随机时间（过去7天内）
-		randomTime := now.Add(-time.Duration(rand.Intn(7*24)) * time.Hour)
-		randomTime = randomTime.Add(-time.Duration(rand.Intn(60)) * time.Minute)
-		randomTime = randomTime.Add(-time.Duration(rand.Intn(60)) * time.Second)
-
-		// 生成小时字符串
-		timeHour := randomTime.Format("2006-01-02 15")
-
-		platform := platforms[rand.Intn(len(platforms))]
-		category := categories[rand.Intn(len(categories))]
-		action := actions[rand.Intn(len(actions))]
-		os := oses[rand.Intn(len(oses))]
-		appID := appIDs[rand.Intn(len(appIDs))]
-		label := labels[rand.Intn(len(labels))]
-		state := states[rand.Intn(len(states))]
-		message := messages[rand.Intn(len(messages))]
-		deviceID := deviceIDs[rand.Intn(len(deviceIDs))]
-		model := models[rand.Intn(len(models))]
-		osVer := osVersions[rand.Intn(len(osVersions))]
-		userID := userIDs[rand.Intn(len(userIDs))]
-
-		// 创建记录
-		record := KV7Record{
-			DataTime:     randomTime,
-			WriteTime:    randomTime.Add(time.Second),
-			TimeHour:     timeHour,
-			ID:           fmt.Sprintf("log_%d", rand.Int63n(1000000)),
-			Time:         randomTime.UnixNano() / 1000000,
-			Platform:     platform,
-			Category:     category,
-			Action:       action,
-			OS:           os,
-			UserID:       userID,
-			AppID:        appID,
-			Version:      fmt.Sprintf("1.%d.%d", rand.Intn(10), rand.Intn(100)),
-			DeviceID:     deviceID,
-			Model:        model,
-			OSVer:        osVer,
-			Label:        label,
-			State:        state,
-			Value:        int32(rand.Intn(100)),
-			D1:           message,
-			D2:           fmt.Sprintf("页面路径: /%s", strings.ToLower(action)),
-			D3:           fmt.Sprintf("设备信息: %s %s", model, os),
-			Extra:        fmt.Sprintf("额外信息-%d", i),
-			EntranceTime: randomTime.Unix(),
-			EntranceID:   fmt.Sprintf("entrance_%d", rand.Int63n(100000)),
-		}
-
-		records = append(records, record)
+	if options.Limit <= 0 {
+		options.Limit = 100
 	}
 
-	log.Printf("成功生成 %d 条模拟数据", count)
-	return records
+	return store.Query(ctx, options, RecentRecordColumns)
 }
 
-// QueryKV7Logs 查询KV7日志
-func QueryKV7Logs(options database.QueryOptions) ([]KV7Record, int, error) {
-	log.Printf("查询KV7日志: 开始时间=%v, 结束时间=%v, 限制=%d", options.StartTime, options.EndTime, options.Limit)
-
-	// 检查是否使用真实数据库
-	useRealDatabase := os.Getenv("USE_REAL_DATABASE") == "true"
-	log.Printf("使用真实数据库标志: %v", useRealDatabase)
-
-	if !useRealDatabase {
-		log.Println("使用模拟数据...")
-		records := GenerateMockData(options.Limit)
-		return records, len(records), nil
-	}
-
-	// 获取数据库连接
-	conn := database.GetClickHouseConn()
-	if conn == nil {
-		log.Println("无法连接到ClickHouse数据库，将使用模拟数据")
-		records := GenerateMockData(options.Limit)
-		return records, len(records), nil
-	}
-
-	// 测试连接是否有效
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	err := conn.PingContext(ctx)
-	if err != nil {
-		log.Printf("数据库连接测试失败: %v, 降级使用模拟数据", err)
-		records := GenerateMockData(options.Limit)
-		return records, len(records), nil
-	}
-
-	log.Println("数据库连接测试成功，继续查询")
-
-	// 构建查询条件
-	var conditions []string
-	var args []interface{}
-
-	// 添加时间范围条件
-	if !options.StartTime.IsZero() {
-		conditions = append(conditions, "data_time >= ?")
-		args = append(args, options.StartTime)
-	}
-
-	if !options.EndTime.IsZero() {
-		conditions = append(conditions, "data_time <= ?")
-		args = append(args, options.EndTime)
-	}
-
-	// 添加其他筛选条件
-	if options.Category != "" {
-		conditions = append(conditions, "category = ?")
-		args = append(args, options.Category)
-	}
-
-	if options.UserID != "" {
-		conditions = append(conditions, "user_id = ?")
-		args = append(args, options.UserID)
-	}
-
-	if options.AppID != "" {
-		conditions = append(conditions, "app_id = ?")
-		args = append(args, options.AppID)
-	}
-
-	if options.Platform != "" {
-		conditions = append(conditions, "platform = ?")
-		args = append(args, options.Platform)
-	}
-
-	if options.OS != "" {
-		conditions = append(conditions, "os = ?")
-		args = append(args, options.OS)
-	}
-
-	if options.Action != "" {
-		conditions = append(conditions, "action = ?")
-		args = append(args, options.Action)
-	}
-
-	// 处理自定义过滤条件
-	if options.Filter != nil {
-		for key, value := range options.Filter {
-			switch key {
-			case "msg": // 消息过滤 - 使用d1字段
-				conditions = append(conditions, "d1 LIKE ?")
-				args = append(args, "%"+value.(string)+"%")
-			case "device_id": // 设备ID过滤
-				conditions = append(conditions, "device_id = ?")
-				args = append(args, value)
-			case "model": // 设备型号过滤
-				conditions = append(conditions, "model = ?")
-				args = append(args, value)
-			}
-		}
-	}
-
-	// 构建WHERE子句
-	whereClause := ""
-	if len(conditions) > 0 {
-		whereClause = "WHERE " + strings.Join(conditions, " AND ")
-	} else {
-		whereClause = "WHERE 1=1" // 确保WHERE子句存在
-	}
-
-	// 构建排序字段
-	orderBy := "data_time"
-	if options.SortBy != "" {
-		orderBy = options.SortBy
-	}
-
-	// 构建排序方向
-	orderDir := "DESC"
-	if options.SortOrder != "" {
-		orderDir = options.SortOrder
-	}
-
-	// 构建分页
-	limit := 100
-	if options.Limit > 0 {
-		limit = options.Limit
-	}
-
-	offset := 0
-	if options.Offset > 0 {
-		offset = options.Offset
-	}
-
-	// 构建查询语句
-	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM test_db.kv_7 %s", whereClause)
-	log.Printf("计数查询SQL: %s, 参数: %v", countQuery, args)
-
-	dataQuery := fmt.Sprintf(`
-		SELECT 
-			data_time, write_time, time_hour, id, time, 
-			platform, category, action, os, user_id, app_id, version,
-			device_id, model, os_ver, d1, d2, d3, 
-			label, state, value, extra, entrance_time, entrance_id
-		FROM test_db.kv_7 
-		%s
-		ORDER BY %s %s
-		LIMIT ? OFFSET ?
-	`, whereClause, orderBy, orderDir)
-
-	queryArgs := make([]interface{}, len(args))
-	copy(queryArgs, args)
-	queryArgs = append(queryArgs, limit, offset)
-
-	log.Printf("数据查询SQL: %s, 参数: %v", dataQuery, queryArgs)
-
-	// 查询总记录数
-	var total int
-	err = conn.QueryRow(countQuery, args...).Scan(&total)
-	if err != nil {
-		log.Printf("查询记录总数失败: %v, 降级使用模拟数据", err)
-		records := GenerateMockData(options.Limit)
-		return records, len(records), nil
-	}
-
-	log.Printf("查询到记录总数: %d", total)
-
-	// 查询数据
-	rows, err := conn.Query(dataQuery, queryArgs...)
-	if err != nil {
-		log.Printf("查询日志失败: %v, 降级使用模拟数据", err)
-		records := GenerateMockData(options.Limit)
-		return records, len(records), nil
-	}
-	defer rows.Close()
-
-	var records []KV7Record
-	for rows.Next() {
-		var record KV7Record
-		err := rows.Scan(
-			&record.DataTime, &record.WriteTime, &record.TimeHour, &record.ID, &record.Time,
-			&record.Platform, &record.Category, &record.Action, &record.OS, &record.UserID,
-			&record.AppID, &record.Version, &record.DeviceID, &record.Model, &record.OSVer,
-			&record.D1, &record.D2, &record.D3, &record.Label, &record.State, &record.Value,
-			&record.Extra, &record.EntranceTime, &record.EntranceID,
-		)
-		if err != nil {
-			log.Printf("扫描行数据失败: %v, 跳过该记录", err)
-			continue
-		}
-		records = append(records, record)
-	}
-
-	if err := rows.Err(); err != nil {
-		log.Printf("读取行数据错误: %v", err)
-	}
-
-	log.Printf("成功从数据库读取到 %d 条日志记录", len(records))
-
-	// 如果没有查询到数据，返回模拟数据
-	if len(records) == 0 {
-		log.Println("未查询到实际数据，返回模拟数据")
-		records = GenerateMockData(options.Limit)
-		return records, len(records), nil
-	}
-
-	return records, total, nil
+// RecentRecordColumns 最近记录返回的列
+var RecentRecordColumns = []string{
+	"data_time", "write_time", "time_hour", "id", "time",
+	"platform", "category", "action", "os", "user_id", "app_id", "version",
+	"device_id", "model", "os_ver", "d1", "d2", "d3",
+	"label", "state", "value", "extra", "entrance_time", "entrance_id",
 }
@@ -0,0 +1,164 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"server/database"
+)
+
+// ErrLogNotFound 按ID查找的日志不存在
+var ErrLogNotFound = errors.New("日志不存在")
+
+// LogStore 日志存储，ClickHouse和内存两种实现的筛选、排序和统计语义相同
+// 筛选条件包括时间范围、QueryOptions中的各字段、Filter中的msg/device_id/model以及查询语言表达式
+type LogStore interface {
+	// Query 按条件查询一页日志，columns为空时读取列表页使用的列(logListColumns)
+	// 按options.SortBy(默认data_time)和options.SortOrder(默认降序)排序，Limit为0表示不限制行数
+	Query(ctx context.Context, options database.QueryOptions, columns []string) ([]KV7Record, error)
+	// Stream 与Query相同的查询，结果逐行读取，用于导出等行数很多的场景，调用方负责关闭
+	Stream(ctx context.Context, options database.QueryOptions, columns []string) (RecordStream, error)
+	// Count 符合条件的日志条数，不受Limit和Offset影响
+	Count(ctx context.Context, options database.QueryOptions) (int, error)
+	// Aggregate 对符合条件的日志分组统计
+	Aggregate(ctx context.Context, options database.QueryOptions, agg Aggregation) ([]AggregateRow, error)
+	// Get 按ID获取包含全部列的记录，不存在时返回ErrLogNotFound
+	Get(ctx context.Context, id string) (*KV7Record, error)
+	// Insert 写入已校验的记录
+	Insert(ctx context.Context, records []KV7Record) error
+	// Tail 按write_time, id升序读取cursor之后写入的日志并推进cursor，时间范围不参与筛选
+	Tail(ctx context.Context, options database.QueryOptions, cursor *TailCursor, limit int) ([]KV7Record, error)
+	// Fields 日志表的字段名和类型
+	Fields(ctx context.Context) ([]LogField, error)
+	// FieldStats 统计符合条件的日志中各字段的基数、空值比例和高频值，写入fields[i].Stats，返回统计的行数
+	FieldStats(ctx context.Context, options LogFieldsOptions, fields []LogField) (uint64, error)
+}
+
+// RecordStream 逐行读取的日志记录
+type RecordStream interface {
+	// Next 读取下一行，没有更多数据或出错时返回false，错误通过Err获取
+	Next() bool
+	// Record 当前行，只包含读取的列，下一次调用Next时被覆盖
+	Record() *KV7Record
+	// Err 返回读取过程中的错误
+	Err() error
+	// Close 关闭结果集
+	Close() error
+}
+
+// Aggregation 分组统计的参数，相当于
+// SELECT <Interval>, <GroupBy>, count(), avg(<Avg>), uniqExact(<Uniq>) ... GROUP BY ... ORDER BY count DESC, <GroupBy> LIMIT <Limit>
+type Aggregation struct {
+	// GroupBy 分组的列，值按字符串比较；为空且Interval为0时统计全部记录，返回一行
+	GroupBy []string
+	// Interval 不为0时按data_time所在的时间段分组，结果按时间升序
+	Interval time.Duration
+	// Avg 求平均值的列，没有记录时平均值为0
+	Avg []string
+	// Uniq 统计不同值个数的列，空字符串也算一个值
+	Uniq []string
+	// SkipEmpty 排除分组列为空字符串的记录
+	SkipEmpty bool
+	// Limit 最多返回的分组数，0表示不限制
+	Limit int
+}
+
+// AggregateRow 一个分组的统计结果
+type AggregateRow struct {
+	// Time Interval不为0时为时间段的开始时间
+	Time time.Time
+	// Keys 与GroupBy对应的分组值
+	Keys  []string
+	Count uint64
+	// Avg 与Aggregation.Avg对应的平均值
+	Avg []float64
+	// Uniq 与Aggregation.Uniq对应的不同值个数
+	Uniq []uint64
+}
+
+// validate 检查分组、求平均值和统计不同值的列都属于kv_7，这些列会拼接到SQL中
+func (agg Aggregation) validate() error {
+	columns := append(append(append([]string{}, agg.GroupBy...), agg.Avg...), agg.Uniq...)
+	for _, column := range columns {
+		if !IsKV7Column(column) {
+			return fmt.Errorf("未知的统计列 %q", column)
+		}
+	}
+	return nil
+}
+
+// logListColumns 日志列表读取的列，避免传输全部两百多列
+var logListColumns = []string{
+	"data_time", "write_time", "time_hour", "id", "time",
+	"platform", "category", "action", "os", "user_id", "app_id", "version",
+	"level", "d1", "d2", "d3",
+}
+
+// queryColumns 校验要读取的列，为空时返回logListColumns
+func queryColumns(columns []string) ([]string, error) {
+	if len(columns) == 0 {
+		return logListColumns, nil
+	}
+	for _, column := range columns {
+		if !IsKV7Column(column) {
+			return nil, fmt.Errorf("未知的列 %q", column)
+		}
+	}
+	return columns, nil
+}
+
+// sortOrder 返回排序的列和是否降序，排序字段只允许kv_7的列，防止拼接SQL
+func sortOrder(options database.QueryOptions) (string, bool) {
+	column := "data_time"
+	if IsKV7Column(options.SortBy) {
+		column = options.SortBy
+	}
+	return column, !strings.EqualFold(options.SortOrder, "asc")
+}
+
+// mockRecordCount mock数据源启动时生成的随机记录数
+const mockRecordCount = 2000
+
+// NewLogStore 按当前数据源创建日志存储，并准备SQL控制台的模拟数据
+// clickhouse使用ClickHouseStore；mock和fixture使用内存存储，分别装入随机数据和固定的测试数据
+func NewLogStore() (LogStore, error) {
+	switch database.CurrentSource() {
+	case database.SourceMock:
+		mockSource = randomSource{}
+		return NewMemoryStore(GenerateMockData(mockRecordCount)), nil
+	case database.SourceFixture:
+		records, err := loadFixtureRecords()
+		if err != nil {
+			return nil, err
+		}
+		store := NewMemoryStore(records)
+		mockSource = &fixtureSource{store: store}
+		return store, nil
+	}
+	mockSource = nil
+	return NewClickHouseStore(), nil
+}
+
+// loadFixtureRecords 读取DATA_FIXTURE_FILE中的NDJSON记录，未设置时生成固定的测试数据
+func loadFixtureRecords() ([]KV7Record, error) {
+	if path := fixtureFile(); path != "" {
+		records, err := loadFixtureFile(path)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("从%s读取了 %d 条固定测试数据", path, len(records))
+		return records, nil
+	}
+
+	baseTime, err := FixtureBaseTime()
+	if err != nil {
+		return nil, err
+	}
+	records := FixtureRecords(FixtureCount, baseTime)
+	log.Printf("生成了 %d 条固定测试数据，基准时间 %s", len(records), baseTime.Format(time.RFC3339))
+	return records, nil
+}
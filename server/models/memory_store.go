@@ -0,0 +1,456 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"server/database"
+	"server/utils"
+)
+
+// MemoryStore 进程内的日志存储，用于mock和fixture数据源以及不依赖ClickHouse的测试
+// 筛选条件与ClickHouseStore的WHERE子句等价，查询语言表达式通过querylang.Predicate在内存中求值
+type MemoryStore struct {
+	mu      sync.RWMutex
+	records []KV7Record
+	byID    map[string]int
+}
+
+// NewMemoryStore 创建包含records副本的内存存储
+func NewMemoryStore(records []KV7Record) *MemoryStore {
+	s := &MemoryStore{byID: make(map[string]int, len(records))}
+	s.append(records)
+	return s
+}
+
+// append 追加记录，调用方持有写锁
+func (s *MemoryStore) append(records []KV7Record) {
+	for _, r := range records {
+		s.byID[r.ID] = len(s.records)
+		s.records = append(s.records, r)
+	}
+}
+
+// Query 实现LogStore
+func (s *MemoryStore) Query(ctx context.Context, options database.QueryOptions, columns []string) ([]KV7Record, error) {
+	columns, err := queryColumns(columns)
+	if err != nil {
+		return nil, err
+	}
+	matched, err := s.filter(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	column, desc := sortOrder(options)
+	sort.SliceStable(matched, func(i, j int) bool {
+		a, _ := matched[i].Get(column)
+		b, _ := matched[j].Get(column)
+		if desc {
+			return lessValue(b, a)
+		}
+		return lessValue(a, b)
+	})
+
+	start := min(max(options.Offset, 0), len(matched))
+	end := len(matched)
+	if options.Limit > 0 {
+		end = min(start+options.Limit, end)
+	}
+
+	// 与ClickHouseStore一致，只返回读取的列
+	records := make([]KV7Record, 0, end-start)
+	for _, r := range matched[start:end] {
+		records = append(records, projectRecord(r, columns))
+	}
+	return records, nil
+}
+
+// Stream 实现LogStore，一次取出全部符合条件的记录后逐条返回
+func (s *MemoryStore) Stream(ctx context.Context, options database.QueryOptions, columns []string) (RecordStream, error) {
+	records, err := s.Query(ctx, options, columns)
+	if err != nil {
+		return nil, err
+	}
+	return &sliceStream{records: records}, nil
+}
+
+// sliceStream 遍历内存中记录的RecordStream
+type sliceStream struct {
+	records []KV7Record
+	record  KV7Record
+}
+
+func (s *sliceStream) Next() bool {
+	if len(s.records) == 0 {
+		return false
+	}
+	s.record, s.records = s.records[0], s.records[1:]
+	return true
+}
+
+func (s *sliceStream) Record() *KV7Record {
+	return &s.record
+}
+
+func (s *sliceStream) Err() error {
+	return nil
+}
+
+func (s *sliceStream) Close() error {
+	return nil
+}
+
+// Count 实现LogStore
+func (s *MemoryStore) Count(ctx context.Context, options database.QueryOptions) (int, error) {
+	matched, err := s.filter(ctx, options)
+	if err != nil {
+		return 0, err
+	}
+	return len(matched), nil
+}
+
+// Aggregate 实现LogStore
+func (s *MemoryStore) Aggregate(ctx context.Context, options database.QueryOptions, agg Aggregation) ([]AggregateRow, error) {
+	if err := agg.validate(); err != nil {
+		return nil, err
+	}
+	matched, err := s.filter(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	type group struct {
+		row      AggregateRow
+		sums     []float64
+		distinct []map[string]struct{}
+	}
+	newGroup := func(row AggregateRow) *group {
+		g := &group{row: row, sums: make([]float64, len(agg.Avg)), distinct: make([]map[string]struct{}, len(agg.Uniq))}
+		for i := range g.distinct {
+			g.distinct[i] = map[string]struct{}{}
+		}
+		return g
+	}
+	groups := map[string]*group{}
+	var order []*group
+	for _, r := range matched {
+		keys := make([]string, len(agg.GroupBy))
+		skip := false
+		for i, column := range agg.GroupBy {
+			keys[i] = columnString(r, column)
+			skip = skip || (agg.SkipEmpty && keys[i] == "")
+		}
+		if skip {
+			continue
+		}
+		var bucket time.Time
+		if agg.Interval > 0 {
+			bucket = r.DataTime.Truncate(agg.Interval)
+		}
+
+		key := bucket.String() + "\x00" + strings.Join(keys, "\x00")
+		g, ok := groups[key]
+		if !ok {
+			g = newGroup(AggregateRow{Time: bucket, Keys: keys})
+			groups[key] = g
+			order = append(order, g)
+		}
+		g.row.Count++
+		for i, column := range agg.Avg {
+			g.sums[i] += columnFloat(r, column)
+		}
+		for i, column := range agg.Uniq {
+			g.distinct[i][columnString(r, column)] = struct{}{}
+		}
+	}
+
+	// 没有分组时与SQL的聚合一样总是返回一行
+	if len(agg.GroupBy) == 0 && agg.Interval == 0 && len(order) == 0 {
+		order = append(order, newGroup(AggregateRow{Keys: []string{}}))
+	}
+
+	rows := make([]AggregateRow, 0, len(order))
+	for _, g := range order {
+		g.row.Avg = make([]float64, len(agg.Avg))
+		if g.row.Count > 0 {
+			for i, sum := range g.sums {
+				g.row.Avg[i] = sum / float64(g.row.Count)
+			}
+		}
+		g.row.Uniq = make([]uint64, len(agg.Uniq))
+		for i, values := range g.distinct {
+			g.row.Uniq[i] = uint64(len(values))
+		}
+		rows = append(rows, g.row)
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if agg.Interval > 0 && !a.Time.Equal(b.Time) {
+			return a.Time.Before(b.Time)
+		}
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		for k := range a.Keys {
+			if a.Keys[k] != b.Keys[k] {
+				return a.Keys[k] < b.Keys[k]
+			}
+		}
+		return false
+	})
+	if agg.Limit > 0 && len(rows) > agg.Limit {
+		rows = rows[:agg.Limit]
+	}
+	return rows, nil
+}
+
+// Get 实现LogStore
+func (s *MemoryStore) Get(ctx context.Context, id string) (*KV7Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i, ok := s.byID[id]
+	if !ok {
+		return nil, ErrLogNotFound
+	}
+	record := s.records[i]
+	return &record, nil
+}
+
+// Insert 实现LogStore
+func (s *MemoryStore) Insert(ctx context.Context, records []KV7Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.append(records)
+	return nil
+}
+
+// Tail 实现LogStore，时间范围不参与筛选，与ClickHouseStore一致
+func (s *MemoryStore) Tail(ctx context.Context, options database.QueryOptions, cursor *TailCursor, limit int) ([]KV7Record, error) {
+	options.StartTime, options.EndTime = time.Time{}, time.Time{}
+	match, err := recordMatcher(options)
+	if err != nil {
+		return nil, err
+	}
+
+	from := cursor.from()
+	s.mu.RLock()
+	matched := []*KV7Record{}
+	for i := range s.records {
+		r := &s.records[i]
+		if _, ok := cursor.seen[r.ID]; ok || r.WriteTime.Before(from) || !match(r) {
+			continue
+		}
+		record := *r
+		matched = append(matched, &record)
+	}
+	utils.AddRowsRead(ctx, int64(len(s.records)))
+	s.mu.RUnlock()
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if !a.WriteTime.Equal(b.WriteTime) {
+			return a.WriteTime.Before(b.WriteTime)
+		}
+		return a.ID < b.ID
+	})
+	if limit > 0 && len(matched) > limit {
+		matched = matched[:limit]
+	}
+
+	var records []KV7Record
+	for _, r := range matched {
+		if cursor.advance(r) {
+			records = append(records, projectRecord(r, tailColumns))
+		}
+	}
+	cursor.prune()
+	return records, nil
+}
+
+// Fields 实现LogStore，字段来自KV7Record的定义
+func (s *MemoryStore) Fields(ctx context.Context) ([]LogField, error) {
+	fields := make([]LogField, 0, len(kv7Fields))
+	for _, f := range kv7Fields {
+		fields = append(fields, LogField{Name: f.Column, Type: f.Type})
+	}
+	return fields, nil
+}
+
+// FieldStats 实现LogStore，基数为精确值，高频值按出现次数降序、值升序排列
+// KV7Record的列都不是Nullable，NullRatio总是0
+func (s *MemoryStore) FieldStats(ctx context.Context, options LogFieldsOptions, fields []LogField) (uint64, error) {
+	matched, err := s.filter(ctx, options.QueryOptions)
+	if err != nil {
+		return 0, err
+	}
+
+	topK := fieldTopK(options)
+	total := uint64(len(matched))
+	for i := range fields {
+		counts := make(map[string]uint64)
+		var empties uint64
+		for _, r := range matched {
+			value := columnString(r, fields[i].Name)
+			counts[value]++
+			if value == "" && emptyCondition(fields[i].Name, fields[i].Type) != "0" {
+				empties++
+			}
+		}
+
+		values := make([]string, 0, len(counts))
+		for value := range counts {
+			values = append(values, value)
+		}
+		sort.Slice(values, func(a, b int) bool {
+			if counts[values[a]] != counts[values[b]] {
+				return counts[values[a]] > counts[values[b]]
+			}
+			return values[a] < values[b]
+		})
+
+		stats := &FieldStats{
+			Cardinality: uint64(len(counts)),
+			TopValues:   values[:min(topK, len(values))],
+		}
+		if total > 0 {
+			stats.EmptyRatio = float64(empties) / float64(total)
+		}
+		fields[i].Stats = stats
+	}
+	return total, nil
+}
+
+// filter 返回符合筛选条件的记录副本，扫描的记录数计入响应的rows_read
+func (s *MemoryStore) filter(ctx context.Context, options database.QueryOptions) ([]*KV7Record, error) {
+	match, err := recordMatcher(options)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matched := []*KV7Record{}
+	for i := range s.records {
+		if match(&s.records[i]) {
+			r := s.records[i]
+			matched = append(matched, &r)
+		}
+	}
+	utils.AddRowsRead(ctx, int64(len(s.records)))
+	return matched, nil
+}
+
+// recordMatcher 返回与clickhouseWhere相同语义的判断函数
+func recordMatcher(options database.QueryOptions) (func(r *KV7Record) bool, error) {
+	predicate, err := CompileLogPredicate(options.Query, options.AppID)
+	if err != nil {
+		return nil, err
+	}
+
+	equals := map[string]string{
+		"category": options.Category,
+		"level":    options.Level,
+		"user_id":  options.UserID,
+		"app_id":   options.AppID,
+		"platform": options.Platform,
+		"os":       options.OS,
+		"action":   options.Action,
+		"version":  options.Version,
+	}
+	var message string
+	for key, value := range options.Filter {
+		switch key {
+		case "msg":
+			message = fmt.Sprint(value)
+		case "device_id", "model":
+			equals[key] = fmt.Sprint(value)
+		}
+	}
+
+	return func(r *KV7Record) bool {
+		if !options.StartTime.IsZero() && r.DataTime.Before(options.StartTime) {
+			return false
+		}
+		if !options.EndTime.IsZero() && r.DataTime.After(options.EndTime) {
+			return false
+		}
+		for column, value := range equals {
+			if value != "" && columnString(r, column) != value {
+				return false
+			}
+		}
+		if message != "" && !strings.Contains(r.D1, message) {
+			return false
+		}
+		return predicate(r)
+	}, nil
+}
+
+// projectRecord 返回只包含指定列的记录副本
+func projectRecord(r *KV7Record, columns []string) KV7Record {
+	var projected KV7Record
+	src := reflect.ValueOf(r).Elem()
+	dst := reflect.ValueOf(&projected).Elem()
+	for _, column := range columns {
+		if f, ok := kv7FieldIndex[column]; ok {
+			dst.Field(f.Index).Set(src.Field(f.Index))
+		}
+	}
+	return projected
+}
+
+// columnString 读取记录的列值并格式化为字符串，与ClickHouse的toString一致
+func columnString(r *KV7Record, column string) string {
+	value, ok := r.Get(column)
+	if !ok {
+		return ""
+	}
+	if t, ok := value.(time.Time); ok {
+		return t.Format("2006-01-02 15:04:05")
+	}
+	return fmt.Sprint(value)
+}
+
+// columnFloat 读取记录的数值列，字符串列按数值解析，失败时为0
+func columnFloat(r *KV7Record, column string) float64 {
+	value, _ := r.Get(column)
+	switch v := value.(type) {
+	case int64:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case string:
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	}
+	return 0
+}
+
+// lessValue 比较同一列的两个值
+func lessValue(a, b interface{}) bool {
+	switch x := a.(type) {
+	case time.Time:
+		y, _ := b.(time.Time)
+		return x.Before(y)
+	case int64:
+		y, _ := b.(int64)
+		return x < y
+	case int32:
+		y, _ := b.(int32)
+		return x < y
+	case string:
+		y, _ := b.(string)
+		return x < y
+	}
+	return false
+}
@@ -0,0 +1,364 @@
+package models
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MockSource mock和fixture数据源下SQL控制台使用的数据，数据源为clickhouse时不使用
+// 日志查询和统计由内存中的LogStore提供，见NewLogStore
+type MockSource interface {
+	// SQLRows SQL控制台语句的结果
+	SQLRows(query string) []map[string]interface{}
+	// Tables SQL控制台可见的表
+	Tables() []string
+	// TableFields 表的字段名和类型
+	TableFields(table string) []map[string]string
+	// ExplainLines EXPLAIN PLAN或EXPLAIN PIPELINE的输出
+	ExplainLines(kind string) []string
+	// EstimatedRows EXPLAIN ESTIMATE预估读取的行数
+	EstimatedRows() uint64
+}
+
+// mockSource 当前数据源的SQL控制台数据，由NewLogStore设置
+var mockSource MockSource
+
+// Mock 返回当前数据源的模拟数据，数据源为clickhouse时返回nil
+func Mock() MockSource {
+	return mockSource
+}
+
+// randomSource mock数据源的SQL控制台数据，每次请求随机生成
+type randomSource struct{}
+
+func (randomSource) SQLRows(query string) []map[string]interface{} {
+	return mockSQLRows(query)
+}
+
+func (randomSource) Tables() []string {
+	return []string{"kv_7", "system.query_log", "system.tables", "system.columns"}
+}
+
+func (randomSource) TableFields(table string) []map[string]string {
+	return []map[string]string{
+		{"name": "data_time", "type": "DateTime"},
+		{"name": "write_time", "type": "DateTime"},
+		{"name": "time_hour", "type": "String"},
+		{"name": "id", "type": "String"},
+		{"name": "time", "type": "Int64"},
+		{"name": "extra", "type": "String"},
+		{"name": "entrance_time", "type": "Int64"},
+		{"name": "app_id", "type": "LowCardinality(String)"},
+		{"name": "platform", "type": "LowCardinality(String)"},
+		{"name": "user_id", "type": "String"},
+		{"name": "category", "type": "LowCardinality(String)"},
+		{"name": "action", "type": "String"},
+		{"name": "os", "type": "LowCardinality(String)"},
+	}
+}
+
+func (randomSource) ExplainLines(kind string) []string {
+	return mockExplainLines(kind)
+}
+
+func (randomSource) EstimatedRows() uint64 {
+	return 24576
+}
+
+// mockExplainLines 模拟的EXPLAIN输出，kind为plan或pipeline
+func mockExplainLines(kind string) []string {
+	if kind == "pipeline" {
+		return []string{
+			"(Expression)",
+			"ExpressionTransform",
+			"  (Limit)",
+			"  Limit",
+			"    (ReadFromMergeTree)",
+			"    MergeTreeInOrder 0 → 1",
+		}
+	}
+	return []string{
+		"Expression ((Projection + Before ORDER BY))",
+		"  Limit (preliminary LIMIT (without OFFSET))",
+		"    ReadFromMergeTree (test_db.kv_7)",
+	}
+}
+
+// mockSQLLimitPattern 模拟数据按语句中的LIMIT决定行数
+var mockSQLLimitPattern = regexp.MustCompile(`(?i)limit\s+(\d+)`)
+
+// mockSQLLimit 返回模拟数据的行数，默认50行，最多100行
+func mockSQLLimit(query string) int {
+	rowCount := 50
+	if matches := mockSQLLimitPattern.FindStringSubmatch(query); len(matches) > 1 {
+		if limit, err := strconv.Atoi(matches[1]); err == nil && limit > 0 {
+			rowCount = min(limit, 100)
+		}
+	}
+	return rowCount
+}
+
+// GenerateMockData 生成模拟KV7数据
+func GenerateMockData(count int) []KV7Record {
+	if count <= 0 {
+		count = 50
+	}
+
+	records := make([]KV7Record, 0, count)
+	now := time.Now()
+
+	// 模拟数据选项
+	platforms := []string{"iOS", "Android", "Web", "macOS", "Windows"}
+	categories := []string{"ERROR", "WARNING", "INFO", "DEBUG", "USER_ACTION", "PERFORMANCE"}
+	actions := []string{"click", "view", "login", "submit", "error", "api_call", "page_load"}
+	oses := []string{"iOS 16", "Android 13", "Windows 10", "macOS 13", "Linux"}
+	appIDs := []string{"腾讯云前端监控项目Web-?20000.2:demo", "腾讯文档Web-10001", "腾讯云音视频项目-30001"}
+	labels := []string{"UI", "Network", "Database", "Auth", "API", "Performance"}
+	states := []string{"success", "failure", "pending", "timeout"}
+	messages := []string{
+		"用户点击了登录按钮",
+		"页面加载完成",
+		"用户提交了表单",
+		"页面访问",
+		"自定义事件上报",
+		"用户页面上正在查看数据报表服务",
+		"Script error. @ (:0:0)",
+		"JSON对象: {\"id\":\"YRKRY18EXMI120000\",\"uid\":\"1743066412\",\"version\":\"1.39.1\"}",
+		"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko)",
+		"aegis.report 页面访问",
+		"用户登录成功,设备ID: DV20252703",
+	}
+	deviceIDs := []string{"DV20252701", "DV20252702", "DV20252703", "DV20252704", "DV20252705"}
+	models := []string{"iPhone 14", "Samsung Galaxy S22", "Google Pixel 7", "MacBook Pro", "ThinkPad X1"}
+	osVersions := []string{"16.5", "13.0", "10.0.19045", "13.3", "5.15.0"}
+	userIDs := []string{"17430", "66417", "66412", "66399", "65500"}
+	levels := []string{"INFO", "WARN", "ERROR", "DEBUG"}
+
+	for i := 0; i < count; i++ {
+		// 生成随机时间（过去7天内）
+		randomTime := now.Add(-time.Duration(rand.Intn(7*24)) * time.Hour)
+		randomTime = randomTime.Add(-time.Duration(rand.Intn(60)) * time.Minute)
+		randomTime = randomTime.Add(-time.Duration(rand.Intn(60)) * time.Second)
+
+		// 生成小时字符串
+		timeHour := randomTime.Format("2006-01-02 15")
+
+		platform := platforms[rand.Intn(len(platforms))]
+		category := categories[rand.Intn(len(categories))]
+		action := actions[rand.Intn(len(actions))]
+		os := oses[rand.Intn(len(oses))]
+		appID := appIDs[rand.Intn(len(appIDs))]
+		label := labels[rand.Intn(len(labels))]
+		state := states[rand.Intn(len(states))]
+		message := messages[rand.Intn(len(messages))]
+		deviceID := deviceIDs[rand.Intn(len(deviceIDs))]
+		model := models[rand.Intn(len(models))]
+		osVer := osVersions[rand.Intn(len(osVersions))]
+		userID := userIDs[rand.Intn(len(userIDs))]
+
+		// 创建记录
+		record := KV7Record{
+			DataTime:     randomTime,
+			WriteTime:    randomTime.Add(time.Second),
+			TimeHour:     timeHour,
+			ID:           fmt.Sprintf("log_%d", rand.Int63n(1000000)),
+			Time:         randomTime.UnixNano() / 1000000,
+			Platform:     platform,
+			Category:     category,
+			Action:       action,
+			OS:           os,
+			UserID:       userID,
+			AppID:        appID,
+			Version:      fmt.Sprintf("1.%d.%d", rand.Intn(10), rand.Intn(100)),
+			DeviceID:     deviceID,
+			Model:        model,
+			OSVer:        osVer,
+			Label:        label,
+			State:        state,
+			Value:        int32(rand.Intn(100)),
+			Level:        levels[rand.Intn(len(levels))],
+			D1:           message,
+			D2:           fmt.Sprintf("页面路径: /%s", strings.ToLower(action)),
+			D3:           fmt.Sprintf("设备信息: %s %s", model, os),
+			Extra:        fmt.Sprintf("额外信息-%d", i),
+			EntranceTime: randomTime.Unix(),
+			EntranceID:   fmt.Sprintf("entrance_%d", rand.Int63n(100000)),
+		}
+
+		records = append(records, record)
+	}
+
+	log.Printf("成功生成 %d 条模拟数据", count)
+	return records
+}
+
+// mockDescribeRows kv_7表的DESCRIBE结果
+func mockDescribeRows() []map[string]interface{} {
+	rows := make([]map[string]interface{}, 0, len(kv7Fields))
+	for _, f := range kv7Fields {
+		rows = append(rows, map[string]interface{}{
+			"name":               f.Column,
+			"type":               f.Type,
+			"default_type":       "",
+			"default_expression": "",
+		})
+	}
+	return rows
+}
+
+// mockSQLRows 按语句的大致类型生成模拟的SQL控制台结果
+func mockSQLRows(query string) []map[string]interface{} {
+	queryLower := strings.ToLower(query)
+
+	// 模拟SHOW TABLES结果
+	if strings.Contains(queryLower, "show tables") {
+		return []map[string]interface{}{
+			{"name": "kv_7"},
+			{"name": "system.query_log"},
+			{"name": "system.tables"},
+			{"name": "system.columns"},
+		}
+	}
+
+	// 模拟DESCRIBE结果
+	if strings.Contains(queryLower, "describe") {
+		return mockDescribeRows()
+	}
+
+	// 模拟SELECT查询结果
+	mockResults := []map[string]interface{}{}
+
+	// 根据查询类型生成不同的模拟数据
+	if strings.Contains(queryLower, "count") && strings.Contains(queryLower, "group by") {
+		// 模拟分组计数结果
+		if strings.Contains(queryLower, "platform") {
+			mockResults = []map[string]interface{}{
+				{"platform": "iOS", "count": 1250},
+				{"platform": "Android", "count": 980},
+				{"platform": "Web", "count": 780},
+				{"platform": "macOS", "count": 320},
+				{"platform": "Windows", "count": 175},
+			}
+		} else if strings.Contains(queryLower, "os") {
+			mockResults = []map[string]interface{}{
+				{"os": "iOS 16", "count": 850},
+				{"os": "iOS 15", "count": 320},
+				{"os": "Android 13", "count": 650},
+				{"os": "Android 12", "count": 280},
+				{"os": "macOS 13", "count": 220},
+				{"os": "Windows 11", "count": 120},
+				{"os": "Windows 10", "count": 65},
+			}
+		} else {
+			mockResults = []map[string]interface{}{
+				{"category": "PAGE_VIEW", "count": 1800},
+				{"category": "USER_ACTION", "count": 950},
+				{"category": "ERROR", "count": 120},
+				{"category": "WARNING", "count": 310},
+				{"category": "INFO", "count": 520},
+			}
+		}
+	} else {
+		// 默认模拟kv_7表数据查询结果
+		now := time.Now()
+
+		// 生成模拟数据行数
+		rowCount := mockSQLLimit(query)
+
+		// 生成rowCount行模拟数据
+		for i := 0; i < rowCount; i++ {
+			eventTime := now.Add(time.Duration(-i) * time.Hour)
+
+			// 随机数据
+			platforms := []string{"iOS", "Android", "Web", "macOS", "Windows"}
+			categories := []string{"PAGE_VIEW", "USER_ACTION", "ERROR", "WARNING", "INFO", "ANALYTICS"}
+			actions := []string{"login", "click", "view", "create", "delete", "update", "share", "submit"}
+			oses := []string{"iOS 16", "iOS 15", "Android 13", "Android 12", "macOS 13", "Windows 11", "Windows 10"}
+			models := []string{"iPhone 13", "iPhone 14", "iPhone 15", "Samsung Galaxy S22", "Google Pixel 7", "iPad Pro", "MacBook Pro", "Surface Pro"}
+			networks := []string{"Wi-Fi", "4G", "5G", "3G", "Ethernet"}
+
+			platformIdx := i % len(platforms)
+			categoryIdx := (i * 3) % len(categories)
+			actionIdx := (i * 7) % len(actions)
+			osIdx := (i * 5) % len(oses)
+			modelIdx := (i * 9) % len(models)
+			networkIdx := (i * 11) % len(networks)
+
+			result := map[string]interface{}{
+				"data_time":     eventTime.Format(time.RFC3339),
+				"write_time":    eventTime.Add(2 * time.Second).Format(time.RFC3339),
+				"time_hour":     fmt.Sprintf("%02d", eventTime.Hour()),
+				"id":            fmt.Sprintf("ev_%d", 100+i),
+				"time":          eventTime.Unix(),
+				"extra":         fmt.Sprintf("extra_data_%d", i),
+				"entrance_time": eventTime.Unix() - int64(i*60),
+				"entrance_id":   fmt.Sprintf("entrance_%d", i),
+				"stamp":         int32(i * 10),
+				"app_id":        fmt.Sprintf("app_%d", i%5+1),
+				"platform":      platforms[platformIdx],
+				"user_id":       fmt.Sprintf("user_%d", 1000+i),
+				"version":       fmt.Sprintf("1.%d.%d", 2+i%3, 30+i*5),
+				"build_id":      fmt.Sprintf("build_%d", 2000+i),
+				"device_id":     fmt.Sprintf("device_%d", 3000+i),
+				"model":         models[modelIdx],
+				"os":            oses[osIdx],
+				"os_ver":        fmt.Sprintf("%d.%d.%d", 10+i%3, 5+i%5, i%10),
+				"sdk_ver":       fmt.Sprintf("sdk_%d.%d", 3+i%2, 0+i%5),
+				"category":      categories[categoryIdx],
+				"action":        actions[actionIdx],
+				"label":         fmt.Sprintf("label_%d", i),
+				"state":         fmt.Sprintf("state_%d", i%3),
+				"value":         int32(10 + i*5),
+				"d38":           networks[networkIdx], // 网络类型
+				"d39":           fmt.Sprintf("carrier_%d", i%6),
+				"d40":           fmt.Sprintf("region_%d", i%8),
+			}
+
+			// 添加d1-d37字段
+			for j := 1; j <= 37; j++ {
+				if j != 38 && j != 39 && j != 40 { // d38-d40已添加
+					result[fmt.Sprintf("d%d", j)] = fmt.Sprintf("d%d_value_%d", j, i)
+				}
+			}
+
+			// 添加v1-v40字段
+			for j := 1; j <= 40; j++ {
+				result[fmt.Sprintf("v%d", j)] = int64(j * (i + 100))
+			}
+
+			// 添加info1-info10字段
+			for j := 1; j <= 10; j++ {
+				result[fmt.Sprintf("info%d", j)] = fmt.Sprintf("info%d_data_%d", j, i)
+			}
+
+			// 添加ud1-ud20字段
+			for j := 1; j <= 20; j++ {
+				result[fmt.Sprintf("ud%d", j)] = fmt.Sprintf("ud%d_data_%d", j, i)
+			}
+
+			// 添加uv1-uv10字段
+			for j := 1; j <= 10; j++ {
+				result[fmt.Sprintf("uv%d", j)] = int64(j * (i + 200))
+			}
+
+			// 添加sd1-sd20字段
+			for j := 1; j <= 20; j++ {
+				result[fmt.Sprintf("sd%d", j)] = fmt.Sprintf("sd%d_data_%d", j, i)
+			}
+
+			// 添加sv1-sv10字段
+			for j := 1; j <= 10; j++ {
+				result[fmt.Sprintf("sv%d", j)] = int64(j * (i + 300))
+			}
+
+			mockResults = append(mockResults, result)
+		}
+	}
+
+	return mockResults
+}
@@ -0,0 +1,335 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 保存的查询的长度限制
+const (
+	maxSavedQueryName        = 100
+	maxSavedQueryDescription = 1000
+	maxSavedQueryTags        = 20
+)
+
+// QueryParameterTypes 保存的查询可声明的参数类型
+var QueryParameterTypes = []string{"String", "Int64", "UInt64", "Float64", "Date", "DateTime"}
+
+// queryParameterNamePattern 参数名与SQL中的 {name} 对应
+var queryParameterNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+
+// QueryParameter 保存的查询中的参数，执行时作为ClickHouse查询参数绑定
+type QueryParameter struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Default     string `json:"default,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// SavedQuery 命名保存的SQL查询
+type SavedQuery struct {
+	ID          string           `json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Query       string           `json:"query"`
+	Tags        []string         `json:"tags"`
+	Parameters  []QueryParameter `json:"parameters"`
+	Owner       string           `json:"owner"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// SavedQueryError 保存的查询校验错误
+type SavedQueryError struct {
+	Field  string
+	Reason string
+}
+
+func (e *SavedQueryError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Reason)
+}
+
+// ValidateParameterValue 按参数类型校验参数值，实际的类型转换由ClickHouse完成
+func ValidateParameterValue(typ, value string) error {
+	var err error
+	switch typ {
+	case "Int64":
+		_, err = strconv.ParseInt(value, 10, 64)
+	case "UInt64":
+		_, err = strconv.ParseUint(value, 10, 64)
+	case "Float64":
+		_, err = strconv.ParseFloat(value, 64)
+	case "Date":
+		_, err = time.Parse("2006-01-02", value)
+	case "DateTime":
+		if _, err = time.Parse("2006-01-02 15:04:05", value); err != nil {
+			_, err = time.Parse("2006-01-02", value)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%q 不是有效的%s", value, typ)
+	}
+	return nil
+}
+
+// validateSavedQuery 校验并规范化保存的查询，SQL本身由控制器按访问策略检查
+func validateSavedQuery(q *SavedQuery) error {
+	q.Name = strings.TrimSpace(q.Name)
+	if q.Name == "" || len([]rune(q.Name)) > maxSavedQueryName {
+		return &SavedQueryError{Field: "name", Reason: fmt.Sprintf("不能为空且不超过%d个字符", maxSavedQueryName)}
+	}
+	if len([]rune(q.Description)) > maxSavedQueryDescription {
+		return &SavedQueryError{Field: "description", Reason: fmt.Sprintf("不能超过%d个字符", maxSavedQueryDescription)}
+	}
+	if strings.TrimSpace(q.Query) == "" {
+		return &SavedQueryError{Field: "query", Reason: "不能为空"}
+	}
+
+	tags := make([]string, 0, len(q.Tags))
+	seen := map[string]bool{}
+	for _, tag := range q.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > maxSavedQueryTags {
+		return &SavedQueryError{Field: "tags", Reason: fmt.Sprintf("不能超过%d个", maxSavedQueryTags)}
+	}
+	q.Tags = tags
+
+	if q.Parameters == nil {
+		q.Parameters = []QueryParameter{}
+	}
+	names := map[string]bool{}
+	for i := range q.Parameters {
+		p := &q.Parameters[i]
+		if !queryParameterNamePattern.MatchString(p.Name) {
+			return &SavedQueryError{Field: "parameters", Reason: fmt.Sprintf("%q 不是有效的参数名", p.Name)}
+		}
+		if names[p.Name] {
+			return &SavedQueryError{Field: "parameters", Reason: fmt.Sprintf("参数%s重复", p.Name)}
+		}
+		names[p.Name] = true
+		if p.Type == "" {
+			p.Type = "String"
+		}
+		if !isQueryParameterType(p.Type) {
+			return &SavedQueryError{Field: "parameters", Reason: fmt.Sprintf("参数%s的类型%s不受支持，可选%s", p.Name, p.Type, strings.Join(QueryParameterTypes, "、"))}
+		}
+		if p.Default != "" {
+			if err := ValidateParameterValue(p.Type, p.Default); err != nil {
+				return &SavedQueryError{Field: "parameters", Reason: fmt.Sprintf("参数%s的默认值%v", p.Name, err)}
+			}
+		}
+	}
+	return nil
+}
+
+func isQueryParameterType(typ string) bool {
+	for _, t := range QueryParameterTypes {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// SavedQueryStore 保存的查询，持久化到JSON文件
+// 查询ID是随机生成的，知道ID即可查看，用于通过链接分享；只有创建者可以修改和删除
+type SavedQueryStore struct {
+	mu      sync.RWMutex
+	path    string
+	queries map[string]SavedQuery
+}
+
+// savedQueries 全局保存的查询
+var savedQueries = loadSavedQueries()
+
+// loadSavedQueries 从SQL_SAVED_QUERY_FILE加载保存的查询
+func loadSavedQueries() *SavedQueryStore {
+	path := os.Getenv("SQL_SAVED_QUERY_FILE")
+	if path == "" {
+		path = filepath.Join("data", "saved_queries.json")
+	}
+
+	store, err := NewSavedQueryStore(path)
+	if err != nil {
+		log.Printf("加载保存的查询失败: %v", err)
+		store = &SavedQueryStore{path: path, queries: map[string]SavedQuery{}}
+	}
+	return store
+}
+
+// NewSavedQueryStore 从文件创建保存的查询存储，文件不存在时为空
+func NewSavedQueryStore(path string) (*SavedQueryStore, error) {
+	store := &SavedQueryStore{path: path, queries: map[string]SavedQuery{}}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取保存的查询文件失败: %w", err)
+	}
+
+	var stored []SavedQuery
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("解析保存的查询文件失败: %w", err)
+	}
+	for _, q := range stored {
+		store.queries[q.ID] = q
+	}
+	return store, nil
+}
+
+// List 返回用户保存的查询，tag非空时只返回带该标签的查询，按更新时间倒序
+func (s *SavedQueryStore) List(owner, tag string) []SavedQuery {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := []SavedQuery{}
+	for _, q := range s.queries {
+		if q.Owner != owner || (tag != "" && !hasTag(q.Tags, tag)) {
+			continue
+		}
+		list = append(list, q)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].UpdatedAt.After(list[j].UpdatedAt) })
+	return list
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Get 按ID获取保存的查询
+func (s *SavedQueryStore) Get(id string) (SavedQuery, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	q, ok := s.queries[id]
+	return q, ok
+}
+
+// Create 校验并保存新的查询，生成ID和时间
+func (s *SavedQueryStore) Create(q SavedQuery) (SavedQuery, error) {
+	if err := validateSavedQuery(&q); err != nil {
+		return q, err
+	}
+	id, err := newSavedQueryID()
+	if err != nil {
+		return q, fmt.Errorf("生成查询ID失败: %w", err)
+	}
+	q.ID = id
+	q.CreatedAt = time.Now()
+	q.UpdatedAt = q.CreatedAt
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queries[q.ID] = q
+	if err := s.save(); err != nil {
+		delete(s.queries, q.ID)
+		return q, err
+	}
+	return q, nil
+}
+
+// Update 更新保存的查询，ID、创建者和创建时间保持不变
+func (s *SavedQueryStore) Update(id string, q SavedQuery) (SavedQuery, error) {
+	if err := validateSavedQuery(&q); err != nil {
+		return q, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, ok := s.queries[id]
+	if !ok {
+		return q, &SavedQueryError{Field: "id", Reason: "查询不存在"}
+	}
+	q.ID = previous.ID
+	q.Owner = previous.Owner
+	q.CreatedAt = previous.CreatedAt
+	q.UpdatedAt = time.Now()
+
+	s.queries[id] = q
+	if err := s.save(); err != nil {
+		s.queries[id] = previous
+		return q, err
+	}
+	return q, nil
+}
+
+// Delete 删除保存的查询，不存在时返回false
+func (s *SavedQueryStore) Delete(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, ok := s.queries[id]
+	if !ok {
+		return false, nil
+	}
+	delete(s.queries, id)
+	if err := s.save(); err != nil {
+		s.queries[id] = previous
+		return false, err
+	}
+	return true, nil
+}
+
+// save 先写临时文件再重命名，调用方需持有写锁
+func (s *SavedQueryStore) save() error {
+	stored := make([]SavedQuery, 0, len(s.queries))
+	for _, q := range s.queries {
+		stored = append(stored, q)
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].CreatedAt.Before(stored[j].CreatedAt) })
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化保存的查询失败: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("创建保存的查询目录失败: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("写入保存的查询文件失败: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("保存查询文件失败: %w", err)
+	}
+	return nil
+}
+
+// newSavedQueryID 生成随机的查询ID，分享链接依赖ID不可猜测
+func newSavedQueryID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// SavedQueries 返回全局保存的查询存储
+func SavedQueries() *SavedQueryStore {
+	return savedQueries
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"server/querylang"
+)
+
+// fieldAliases 查询语言中可用的字段别名
+var fieldAliases = map[string]string{
+	"msg": "d1",
+	"uid": "user_id",
+}
+
+// kv7Schema 将查询语言中的字段解析为kv_7的列
+// 依次匹配内置别名、列名和应用的字段映射，如 region 解析为 d40
+type kv7Schema struct {
+	appID string
+}
+
+// Resolve 实现querylang.Schema
+func (s kv7Schema) Resolve(field string) (string, string, bool) {
+	if column, ok := fieldAliases[field]; ok {
+		field = column
+	} else if !IsKV7Column(field) {
+		if column, ok := fieldMappings.Resolve(s.appID, field); ok {
+			field = column
+		}
+	}
+	f, ok := kv7FieldIndex[field]
+	if !ok {
+		return "", "", false
+	}
+	return f.Column, f.Type, true
+}
+
+// CompileLogQuery 将日志查询语言编译为kv_7的WHERE条件，appID用于解析字段映射
+// 不带字段的条件匹配消息内容，语法错误以*querylang.Error返回，包含出错位置
+func CompileLogQuery(q string, appID string) (string, []interface{}, error) {
+	compiler := &querylang.Compiler{
+		Schema:       kv7Schema{appID: appID},
+		DefaultField: fieldMappings.Column(appID, "message", "d1"),
+	}
+	return compiler.Compile(q)
+}
+
+// CompileLogPredicate 将日志查询语言编译为内存中的判断函数，供MemoryStore使用
+// 字段解析和默认字段与CompileLogQuery相同
+func CompileLogPredicate(q string, appID string) (querylang.Predicate, error) {
+	compiler := &querylang.Compiler{
+		Schema:       kv7Schema{appID: appID},
+		DefaultField: fieldMappings.Column(appID, "message", "d1"),
+	}
+	return compiler.Predicate(q)
+}
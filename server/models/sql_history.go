@@ -0,0 +1,222 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultSQLHistoryLimit 每个用户保留的历史记录条数
+const defaultSQLHistoryLimit = 500
+
+// SQLHistoryEntry SQL控制台的一次执行记录
+type SQLHistoryEntry struct {
+	User         string            `json:"user"`
+	Query        string            `json:"query"`
+	Params       map[string]string `json:"params,omitempty"`
+	SavedQueryID string            `json:"saved_query_id,omitempty"`
+	ExecutedAt   time.Time         `json:"executed_at"`
+	DurationMs   int64             `json:"duration_ms"`
+	Rows         int               `json:"rows"`
+	Error        string            `json:"error,omitempty"`
+	// Mock 数据库不可用，返回的是模拟数据
+	Mock bool `json:"mock,omitempty"`
+}
+
+// SQLHistoryStore 按用户保存SQL执行历史，追加写入JSON Lines文件
+// 每个用户只保留最近limit条，文件中的过期记录在行数超过保留总数的两倍时压缩
+type SQLHistoryStore struct {
+	mu    sync.Mutex
+	path  string
+	limit int
+	users map[string][]SQLHistoryEntry
+	// lines 文件中的记录行数
+	lines int
+}
+
+// sqlHistory 全局SQL执行历史
+var sqlHistory = loadSQLHistory()
+
+// loadSQLHistory 从SQL_HISTORY_FILE加载历史，SQL_HISTORY_LIMIT设置每个用户保留的条数
+func loadSQLHistory() *SQLHistoryStore {
+	path := os.Getenv("SQL_HISTORY_FILE")
+	if path == "" {
+		path = filepath.Join("data", "sql_history.jsonl")
+	}
+	limit := defaultSQLHistoryLimit
+	if v := os.Getenv("SQL_HISTORY_LIMIT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limit = n
+		} else {
+			log.Printf("SQL_HISTORY_LIMIT无效，使用默认值%d", defaultSQLHistoryLimit)
+		}
+	}
+
+	store, err := NewSQLHistoryStore(path, limit)
+	if err != nil {
+		log.Printf("加载SQL执行历史失败: %v", err)
+		store = &SQLHistoryStore{path: path, limit: limit, users: map[string][]SQLHistoryEntry{}}
+	}
+	return store
+}
+
+// NewSQLHistoryStore 从文件创建历史存储，无法解析的行会被跳过
+func NewSQLHistoryStore(path string, limit int) (*SQLHistoryStore, error) {
+	store := &SQLHistoryStore{path: path, limit: limit, users: map[string][]SQLHistoryEntry{}}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("打开SQL执行历史文件失败: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		store.lines++
+		var entry SQLHistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		store.append(entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取SQL执行历史文件失败: %w", err)
+	}
+
+	if store.lines > store.total() {
+		if err := store.compact(); err != nil {
+			log.Printf("压缩SQL执行历史失败: %v", err)
+		}
+	}
+	return store, nil
+}
+
+// append 在内存中追加记录并只保留最近limit条，调用方需持有锁
+func (s *SQLHistoryStore) append(entry SQLHistoryEntry) {
+	entries := append(s.users[entry.User], entry)
+	if len(entries) > s.limit {
+		entries = append([]SQLHistoryEntry(nil), entries[len(entries)-s.limit:]...)
+	}
+	s.users[entry.User] = entries
+}
+
+// total 内存中的记录总数，调用方需持有锁
+func (s *SQLHistoryStore) total() int {
+	n := 0
+	for _, entries := range s.users {
+		n += len(entries)
+	}
+	return n
+}
+
+// Record 记录一次执行
+func (s *SQLHistoryStore) Record(entry SQLHistoryEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("序列化SQL执行记录失败: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.append(entry)
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("创建SQL执行历史目录失败: %w", err)
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("打开SQL执行历史文件失败: %w", err)
+	}
+	_, err = f.Write(append(data, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("写入SQL执行历史失败: %w", err)
+	}
+	s.lines++
+
+	if s.lines > 2*s.total() {
+		return s.compact()
+	}
+	return nil
+}
+
+// List 按时间倒序返回用户的执行历史及总条数
+func (s *SQLHistoryStore) List(user string, limit, offset int) ([]SQLHistoryEntry, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := s.users[user]
+	total := len(entries)
+	list := []SQLHistoryEntry{}
+	for i := total - 1 - offset; i >= 0 && len(list) < limit; i-- {
+		list = append(list, entries[i])
+	}
+	return list, total
+}
+
+// Clear 清空用户的执行历史
+func (s *SQLHistoryStore) Clear(user string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, ok := s.users[user]
+	if !ok {
+		return nil
+	}
+	delete(s.users, user)
+	if err := s.compact(); err != nil {
+		s.users[user] = previous
+		return err
+	}
+	return nil
+}
+
+// compact 只保留内存中的记录重写文件，调用方需持有锁
+func (s *SQLHistoryStore) compact() error {
+	var entries []SQLHistoryEntry
+	for _, list := range s.users {
+		entries = append(entries, list...)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].ExecutedAt.Before(entries[j].ExecutedAt) })
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return fmt.Errorf("序列化SQL执行记录失败: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("创建SQL执行历史目录失败: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("写入SQL执行历史文件失败: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("保存SQL执行历史文件失败: %w", err)
+	}
+	s.lines = len(entries)
+	return nil
+}
+
+// SQLHistory 返回全局SQL执行历史
+func SQLHistory() *SQLHistoryStore {
+	return sqlHistory
+}
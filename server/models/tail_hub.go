@@ -0,0 +1,183 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"server/database"
+)
+
+// 实时追踪订阅配置
+const (
+	tailSubscriptionBuffer = 64  // 每个订阅者最多缓冲的未消费批次数
+	tailBatchLimit         = 500 // 单次轮询最多读取的记录数
+)
+
+// TailHub 管理实时追踪订阅，筛选条件相同的订阅者共享同一个上游轮询
+type TailHub struct {
+	store    LogStore
+	interval time.Duration
+
+	mu      sync.Mutex
+	pollers map[string]*tailPoller
+}
+
+// tailPoller 针对一组筛选条件轮询日志存储并广播给所有订阅者
+type tailPoller struct {
+	key     string
+	options database.QueryOptions
+	subs    map[*TailSubscription]struct{}
+	cancel  context.CancelFunc
+}
+
+// TailSubscription 表示一个订阅，新日志按批次从C读取
+type TailSubscription struct {
+	C       chan []KV7Record
+	Key     string
+	dropped int64
+	poller  *tailPoller
+}
+
+// Dropped 返回因订阅者消费过慢而丢弃的批次数
+func (s *TailSubscription) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// NewTailHub 创建从store轮询新日志的实时追踪订阅中心
+func NewTailHub(store LogStore, interval time.Duration) *TailHub {
+	return &TailHub{
+		store:    store,
+		interval: interval,
+		pollers:  make(map[string]*tailPoller),
+	}
+}
+
+// TailFilterKey 计算筛选条件的规范化键，时间范围和分页参数不参与比较
+func TailFilterKey(options database.QueryOptions) string {
+	filterOnly := database.QueryOptions{
+		Platform: options.Platform,
+		OS:       options.OS,
+		UserID:   options.UserID,
+		Category: options.Category,
+		Level:    options.Level,
+		Action:   options.Action,
+		AppID:    options.AppID,
+		Version:  options.Version,
+		Filter:   options.Filter,
+		Query:    options.Query,
+	}
+	// encoding/json对map按键排序，结果是稳定的
+	data, err := json.Marshal(filterOnly)
+	if err != nil {
+		return fmt.Sprintf("%+v", filterOnly)
+	}
+	return string(data)
+}
+
+// Subscribe 订阅符合筛选条件的新日志
+func (h *TailHub) Subscribe(options database.QueryOptions) *TailSubscription {
+	key := TailFilterKey(options)
+	sub := &TailSubscription{
+		C:   make(chan []KV7Record, tailSubscriptionBuffer),
+		Key: key,
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	poller, ok := h.pollers[key]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		poller = &tailPoller{
+			key:     key,
+			options: options,
+			subs:    make(map[*TailSubscription]struct{}),
+			cancel:  cancel,
+		}
+		h.pollers[key] = poller
+		go h.poll(ctx, poller)
+		log.Printf("创建实时追踪轮询: %s", key)
+	}
+
+	poller.subs[sub] = struct{}{}
+	sub.poller = poller
+	return sub
+}
+
+// Unsubscribe 取消订阅，最后一个订阅者离开时停止对应的轮询
+func (h *TailHub) Unsubscribe(sub *TailSubscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	poller := sub.poller
+	if poller == nil {
+		return
+	}
+	delete(poller.subs, sub)
+	sub.poller = nil
+
+	if len(poller.subs) == 0 {
+		poller.cancel()
+		delete(h.pollers, poller.key)
+		log.Printf("停止实时追踪轮询: %s", poller.key)
+	}
+}
+
+// Stats 返回当前轮询数和订阅数
+func (h *TailHub) Stats() map[string]int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	subscribers := 0
+	for _, p := range h.pollers {
+		subscribers += len(p.subs)
+	}
+	return map[string]int{
+		"pollers":     len(h.pollers),
+		"subscribers": subscribers,
+	}
+}
+
+// poll 周期性查询新日志并广播
+func (h *TailHub) poll(ctx context.Context, poller *tailPoller) {
+	cursor := NewTailCursor(time.Now().Add(-h.interval))
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		queryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		records, err := h.store.Tail(queryCtx, poller.options, cursor, tailBatchLimit)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("实时追踪轮询失败: %v", err)
+			}
+			continue
+		}
+		if len(records) == 0 {
+			continue
+		}
+
+		h.mu.Lock()
+		for sub := range poller.subs {
+			select {
+			case sub.C <- records:
+			default:
+				// 订阅者消费过慢时丢弃该批次，避免拖慢其他订阅者
+				atomic.AddInt64(&sub.dropped, 1)
+			}
+		}
+		h.mu.Unlock()
+	}
+}
@@ -0,0 +1,149 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"server/database"
+)
+
+// tailColumns 实时追踪返回的列，与QueryLogs保持一致
+var tailColumns = []string{
+	"data_time", "write_time", "time_hour", "id", "time",
+	"platform", "category", "action", "os", "user_id", "app_id", "version",
+	"level", "d1", "d2", "d3",
+}
+
+// buildFilterConditions 根据查询选项构建除时间范围外的筛选条件
+func buildFilterConditions(options database.QueryOptions) ([]string, []interface{}, error) {
+	var conditions []string
+	var args []interface{}
+
+	if options.Category != "" {
+		conditions = append(conditions, "category = ?")
+		args = append(args, options.Category)
+	}
+
+	if options.Level != "" {
+		conditions = append(conditions, "level = ?")
+		args = append(args, options.Level)
+	}
+
+	if options.UserID != "" {
+		conditions = append(conditions, "user_id = ?")
+		args = append(args, options.UserID)
+	}
+
+	if options.AppID != "" {
+		conditions = append(conditions, "app_id = ?")
+		args = append(args, options.AppID)
+	}
+
+	if options.Platform != "" {
+		conditions = append(conditions, "platform = ?")
+		args = append(args, options.Platform)
+	}
+
+	if options.OS != "" {
+		conditions = append(conditions, "os = ?")
+		args = append(args, options.OS)
+	}
+
+	if options.Action != "" {
+		conditions = append(conditions, "action = ?")
+		args = append(args, options.Action)
+	}
+
+	if options.Version != "" {
+		conditions = append(conditions, "version = ?")
+		args = append(args, options.Version)
+	}
+
+	// 处理自定义过滤条件
+	for key, value := range options.Filter {
+		switch key {
+		case "msg": // 消息过滤 - d1包含该子串，%、_等字符按原样匹配，与MemoryStore的strings.Contains一致
+			conditions = append(conditions, "position(d1, ?) > 0")
+			args = append(args, fmt.Sprint(value))
+		case "device_id": // 设备ID过滤
+			conditions = append(conditions, "device_id = ?")
+			args = append(args, value)
+		case "model": // 设备型号过滤
+			conditions = append(conditions, "model = ?")
+			args = append(args, value)
+		}
+	}
+
+	// 查询语言表达式
+	if options.Query != "" {
+		where, queryArgs, err := CompileLogQuery(options.Query, options.AppID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if where != "" {
+			conditions = append(conditions, where)
+			args = append(args, queryArgs...)
+		}
+	}
+
+	return conditions, args, nil
+}
+
+// tailLag 轮询时回看的时间窗口，通过TAIL_LAG_SECONDS配置，默认10秒
+// write_time在组装记录时设置，异步队列、NDJSON批次和较慢的写入会在水位线越过其write_time之后才提交，
+// 因此每次从水位线之前tailLag处开始查询，再按ID去重
+var tailLag = func() time.Duration {
+	if v := os.Getenv("TAIL_LAG_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return time.Duration(n) * time.Second
+		}
+	}
+	return 10 * time.Second
+}()
+
+// TailCursor 记录实时追踪的写入时间水位线，以及回看窗口内已推送的日志ID
+type TailCursor struct {
+	Watermark time.Time
+	since     time.Time
+	lag       time.Duration
+	// seen 回看窗口内已推送的日志ID及其write_time
+	seen map[string]time.Time
+}
+
+// NewTailCursor 创建从指定时间开始的追踪游标，早于since的记录不会推送
+func NewTailCursor(since time.Time) *TailCursor {
+	return &TailCursor{Watermark: since, since: since, lag: tailLag, seen: make(map[string]time.Time)}
+}
+
+// from 本次轮询的起始写入时间：水位线减去回看窗口，但不早于since
+func (c *TailCursor) from() time.Time {
+	from := c.Watermark.Add(-c.lag)
+	if from.Before(c.since) {
+		return c.since
+	}
+	return from
+}
+
+// advance 记录推送的日志并推进水位线，回看窗口内已推送过的返回false
+func (c *TailCursor) advance(record *KV7Record) bool {
+	if _, ok := c.seen[record.ID]; ok {
+		return false
+	}
+	c.seen[record.ID] = record.WriteTime
+	if record.WriteTime.After(c.Watermark) {
+		c.Watermark = record.WriteTime
+	}
+	return true
+}
+
+// prune 早于下次起始时间的记录不会再被查到，不需要继续记录
+func (c *TailCursor) prune() {
+	from := c.from()
+	for id, writeTime := range c.seen {
+		if writeTime.Before(from) {
+			delete(c.seen, id)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package querylang
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema 将查询中的字段名解析为表的列名和ClickHouse类型
+type Schema interface {
+	Resolve(field string) (column string, columnType string, ok bool)
+}
+
+// Compiler 将语法树编译为参数化的ClickHouse WHERE条件
+type Compiler struct {
+	Schema Schema
+	// DefaultField 不带字段的条件匹配的字段，按包含关系匹配
+	DefaultField string
+}
+
+// dateTimeLayouts 支持的时间格式
+var dateTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// Compile 解析并编译查询字符串，返回WHERE条件（不含WHERE关键字）和参数
+// 空查询返回空字符串
+func (c *Compiler) Compile(input string) (string, []interface{}, error) {
+	node, err := Parse(input)
+	if err != nil {
+		return "", nil, err
+	}
+	if node == nil {
+		return "", nil, nil
+	}
+
+	var args []interface{}
+	sql, err := c.compile(node, &args)
+	if err != nil {
+		return "", nil, err
+	}
+	return sql, args, nil
+}
+
+func (c *Compiler) compile(node Node, args *[]interface{}) (string, error) {
+	switch n := node.(type) {
+	case *BinaryExpr:
+		left, err := c.compile(n.Left, args)
+		if err != nil {
+			return "", err
+		}
+		right, err := c.compile(n.Right, args)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("(%s %s %s)", left, n.Op, right), nil
+
+	case *NotExpr:
+		inner, err := c.compile(n.Expr, args)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("NOT (%s)", inner), nil
+
+	case *TermExpr:
+		return c.compileTerm(n, args)
+	}
+	return "", fmt.Errorf("未知的语法树节点: %T", node)
+}
+
+func (c *Compiler) compileTerm(t *TermExpr, args *[]interface{}) (string, error) {
+	column, op, arg, err := c.resolveTerm(t)
+	if err != nil {
+		return "", err
+	}
+	*args = append(*args, arg)
+	return fmt.Sprintf("%s %s ?", column, op), nil
+}
+
+// resolveTerm 校验单个条件，返回列名、SQL运算符(=、!=、>、>=、<、<=或LIKE)和转换后的参数
+func (c *Compiler) resolveTerm(t *TermExpr) (string, string, interface{}, error) {
+	field := t.Field
+	contains := false
+	if field == "" {
+		if c.DefaultField == "" {
+			return "", "", nil, &Error{Pos: t.ValuePos, Message: "条件缺少字段名"}
+		}
+		field = c.DefaultField
+		contains = true
+	}
+
+	column, columnType, ok := c.Schema.Resolve(field)
+	if !ok {
+		return "", "", nil, &Error{Pos: t.FieldPos, Message: fmt.Sprintf("未知字段 %q", field)}
+	}
+
+	// 引号内外的*都是通配符，\*匹配字面的*
+	value, wildcard := unescapeValue(t.Value)
+	baseType := baseColumnType(columnType)
+
+	// 字符串匹配：包含通配符或不带字段时使用LIKE
+	if t.Op == ":" && (wildcard || contains) {
+		if baseType != "String" {
+			return "", "", nil, &Error{Pos: t.ValuePos, Message: fmt.Sprintf("字段 %s 不支持通配符匹配", field)}
+		}
+		pattern := likePattern(t.Value, wildcard)
+		if contains {
+			pattern = "%" + pattern + "%"
+		}
+		return column, "LIKE", pattern, nil
+	}
+
+	op := t.Op
+	switch op {
+	case ":", "=":
+		op = "="
+	case ">", ">=", "<", "<=", "!=":
+	default:
+		return "", "", nil, &Error{Pos: t.ValuePos, Message: fmt.Sprintf("不支持的运算符 %q", op)}
+	}
+
+	arg, err := convertValue(value, baseType)
+	if err != nil {
+		return "", "", nil, &Error{Pos: t.ValuePos, Message: fmt.Sprintf("字段 %s: %v", field, err)}
+	}
+	return column, op, arg, nil
+}
+
+// baseColumnType 将ClickHouse类型归一为String、Int、Float、DateTime几类
+func baseColumnType(columnType string) string {
+	t := columnType
+	for _, wrapper := range []string{"LowCardinality(", "Nullable("} {
+		for strings.HasPrefix(t, wrapper) {
+			t = strings.TrimSuffix(strings.TrimPrefix(t, wrapper), ")")
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(t, "Int"), strings.HasPrefix(t, "UInt"):
+		return "Int"
+	case strings.HasPrefix(t, "Float"), strings.HasPrefix(t, "Decimal"):
+		return "Float"
+	case strings.HasPrefix(t, "DateTime"), t == "Date", t == "Date32":
+		return "DateTime"
+	}
+	return "String"
+}
+
+// convertValue 按列类型转换比较值
+func convertValue(value string, baseType string) (interface{}, error) {
+	switch baseType {
+	case "Int":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("需要整数，实际为 %q", value)
+		}
+		return n, nil
+	case "Float":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("需要数值，实际为 %q", value)
+		}
+		return f, nil
+	case "DateTime":
+		for _, layout := range dateTimeLayouts {
+			if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("无法识别的时间 %q", value)
+	}
+	return value, nil
+}
+
+// unescapeValue 去除转义，返回实际值以及是否包含未转义的通配符*
+func unescapeValue(raw string) (string, bool) {
+	var sb strings.Builder
+	wildcard := false
+	runes := []rune(raw)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '\\' && i+1 < len(runes) {
+			i++
+			sb.WriteRune(runes[i])
+			continue
+		}
+		if runes[i] == '*' {
+			wildcard = true
+		}
+		sb.WriteRune(runes[i])
+	}
+	return sb.String(), wildcard
+}
+
+// likePattern 将值转换为LIKE模式并转义LIKE的特殊字符，wildcard为true时*转换为%
+func likePattern(raw string, wildcard bool) string {
+	var sb strings.Builder
+	runes := []rune(raw)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == '\\' && i+1 < len(runes) {
+			i++
+			r = runes[i]
+			if r == '%' || r == '_' || r == '\\' {
+				sb.WriteRune('\\')
+			}
+			sb.WriteRune(r)
+			continue
+		}
+		switch r {
+		case '*':
+			if wildcard {
+				sb.WriteRune('%')
+			} else {
+				sb.WriteRune(r)
+			}
+		case '%', '_', '\\':
+			sb.WriteRune('\\')
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
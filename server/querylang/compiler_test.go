@@ -0,0 +1,200 @@
+package querylang
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testSchema 测试用的kv_7列子集
+type testSchema map[string]string
+
+func (s testSchema) Resolve(field string) (string, string, bool) {
+	columnType, ok := s[field]
+	return field, columnType, ok
+}
+
+func newTestCompiler() *Compiler {
+	return &Compiler{
+		Schema: testSchema{
+			"level":     "LowCardinality(String)",
+			"platform":  "String",
+			"action":    "String",
+			"d1":        "String",
+			"v1":        "Int64",
+			"data_time": "DateTime",
+		},
+		DefaultField: "d1",
+	}
+}
+
+// testRecord 按列名取值的内存记录
+type testRecord map[string]interface{}
+
+func (r testRecord) Get(column string) (interface{}, bool) {
+	v, ok := r[column]
+	return v, ok
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		query string
+		sql   string
+		args  []interface{}
+	}{
+		{
+			query: `level:ERROR AND platform:(iOS OR Android) AND NOT action:view AND d1:"timeout*" AND v1>500`,
+			sql:   "((((level = ? AND (platform = ? OR platform = ?)) AND NOT (action = ?)) AND d1 LIKE ?) AND v1 > ?)",
+			args:  []interface{}{"ERROR", "iOS", "Android", "view", "timeout%", int64(500)},
+		},
+		{
+			query: `platform:iOS or platform:Android`,
+			sql:   "(platform = ? OR platform = ?)",
+			args:  []interface{}{"iOS", "Android"},
+		},
+		{
+			query: `level:ERROR and not action:view`,
+			sql:   "(level = ? AND NOT (action = ?))",
+			args:  []interface{}{"ERROR", "view"},
+		},
+		{
+			query: `level:ERROR Or level:WARN`,
+			sql:   "(level = ? OR level = ?)",
+			args:  []interface{}{"ERROR", "WARN"},
+		},
+		{
+			query: `level:ERROR platform:iOS`,
+			sql:   "(level = ? AND platform = ?)",
+			args:  []interface{}{"ERROR", "iOS"},
+		},
+		{
+			query: `d1:timeout*`,
+			sql:   "d1 LIKE ?",
+			args:  []interface{}{"timeout%"},
+		},
+		{
+			query: `d1:"connection *timeout"`,
+			sql:   "d1 LIKE ?",
+			args:  []interface{}{"connection %timeout"},
+		},
+		{
+			query: `d1:"a\*b"`,
+			sql:   "d1 = ?",
+			args:  []interface{}{"a*b"},
+		},
+		{
+			query: `d1:a\*b*`,
+			sql:   "d1 LIKE ?",
+			args:  []interface{}{"a*b%"},
+		},
+		{
+			query: `d1:"50%_*"`,
+			sql:   "d1 LIKE ?",
+			args:  []interface{}{`50\%\_%`},
+		},
+		{
+			query: `d1:"timeout"`,
+			sql:   "d1 = ?",
+			args:  []interface{}{"timeout"},
+		},
+		{
+			query: `timeout`,
+			sql:   "d1 LIKE ?",
+			args:  []interface{}{"%timeout%"},
+		},
+		{
+			query: `"or"`,
+			sql:   "d1 LIKE ?",
+			args:  []interface{}{"%or%"},
+		},
+		{
+			query: `v1>=100 AND v1<500 AND v1!=200`,
+			sql:   "((v1 >= ? AND v1 < ?) AND v1 != ?)",
+			args:  []interface{}{int64(100), int64(500), int64(200)},
+		},
+		{
+			query: "",
+			sql:   "",
+			args:  nil,
+		},
+	}
+
+	c := newTestCompiler()
+	for _, tt := range tests {
+		sql, args, err := c.Compile(tt.query)
+		if err != nil {
+			t.Errorf("%s: 编译失败: %v", tt.query, err)
+			continue
+		}
+		if sql != tt.sql {
+			t.Errorf("%s:\n  SQL为 %s\n  期望 %s", tt.query, sql, tt.sql)
+		}
+		if !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%s: 参数为 %#v，期望 %#v", tt.query, args, tt.args)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		pos   int
+	}{
+		{`level:`, 6},
+		{`level:ERROR AND`, 15},
+		{`(level:ERROR`, 0},
+		{`unknown:x`, 0},
+		{`v1>abc`, 3},
+		{`v1:5*`, 3},
+		{`d1:"timeout`, 3},
+		{`level:ERROR !x`, 12},
+		{`platform:(iOS OR level:ERROR)`, 17},
+	}
+
+	c := newTestCompiler()
+	for _, tt := range tests {
+		_, _, err := c.Compile(tt.query)
+		qerr, ok := err.(*Error)
+		if !ok {
+			t.Errorf("%s: 应返回*Error，实际返回 %v", tt.query, err)
+			continue
+		}
+		if qerr.Pos != tt.pos {
+			t.Errorf("%s: 错误位置为%d，期望%d（%s）", tt.query, qerr.Pos, tt.pos, qerr.Message)
+		}
+	}
+}
+
+func TestPredicate(t *testing.T) {
+	record := testRecord{
+		"level":    "ERROR",
+		"platform": "iOS",
+		"action":   "click",
+		"d1":       "timeout after 5s",
+		"v1":       int64(800),
+	}
+	tests := []struct {
+		query string
+		match bool
+	}{
+		{`level:ERROR AND platform:(iOS OR Android) AND NOT action:view AND d1:"timeout*" AND v1>500`, true},
+		{`d1:"timeout*"`, true},
+		{`d1:"timeout"`, false},
+		{`d1:"timeout\*"`, false},
+		{`platform:Android or platform:iOS`, true},
+		{`not level:ERROR`, false},
+		{`after`, true},
+		{`v1<=500`, false},
+	}
+
+	c := newTestCompiler()
+	for _, tt := range tests {
+		match, err := c.Predicate(tt.query)
+		if err != nil {
+			t.Errorf("%s: 编译失败: %v", tt.query, err)
+			continue
+		}
+		if got := match(record); got != tt.match {
+			t.Errorf("%s: 匹配结果为%v，期望%v", tt.query, got, tt.match)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package querylang
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// TokenType 词法单元类型
+type TokenType int
+
+const (
+	TokenEOF TokenType = iota
+	TokenWord
+	TokenString
+	TokenColon
+	TokenLParen
+	TokenRParen
+	TokenOp
+	TokenAnd
+	TokenOr
+	TokenNot
+)
+
+// String 返回词法单元类型的可读名称，用于错误信息
+func (t TokenType) String() string {
+	switch t {
+	case TokenEOF:
+		return "结束"
+	case TokenWord:
+		return "词"
+	case TokenString:
+		return "字符串"
+	case TokenColon:
+		return "':'"
+	case TokenLParen:
+		return "'('"
+	case TokenRParen:
+		return "')'"
+	case TokenOp:
+		return "比较运算符"
+	case TokenAnd:
+		return "AND"
+	case TokenOr:
+		return "OR"
+	case TokenNot:
+		return "NOT"
+	}
+	return "未知"
+}
+
+// Token 词法单元，Pos为在查询中的字符位置（从0开始）
+type Token struct {
+	Type TokenType
+	Text string
+	Pos  int
+}
+
+// Error 查询语言的解析或编译错误，Pos为出错的字符位置（从0开始）
+type Error struct {
+	Pos     int    `json:"position"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("位置%d: %s", e.Pos, e.Message)
+}
+
+// lexer 将查询字符串切分为词法单元
+type lexer struct {
+	input []rune
+	pos   int
+}
+
+// Lex 对查询字符串进行词法分析
+func Lex(input string) ([]Token, error) {
+	l := &lexer{input: []rune(input)}
+	var tokens []Token
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, tok)
+		if tok.Type == TokenEOF {
+			return tokens, nil
+		}
+	}
+}
+
+// isWordRune 判断字符能否出现在未加引号的词中
+func isWordRune(r rune) bool {
+	if unicode.IsSpace(r) {
+		return false
+	}
+	switch r {
+	case '(', ')', ':', '"', '<', '>', '=', '!':
+		return false
+	}
+	return true
+}
+
+func (l *lexer) next() (Token, error) {
+	for l.pos < len(l.input) && unicode.IsSpace(l.input[l.pos]) {
+		l.pos++
+	}
+	if l.pos >= len(l.input) {
+		return Token{Type: TokenEOF, Pos: l.pos}, nil
+	}
+
+	start := l.pos
+	r := l.input[l.pos]
+	switch r {
+	case '(':
+		l.pos++
+		return Token{Type: TokenLParen, Text: "(", Pos: start}, nil
+	case ')':
+		l.pos++
+		return Token{Type: TokenRParen, Text: ")", Pos: start}, nil
+	case ':':
+		l.pos++
+		return Token{Type: TokenColon, Text: ":", Pos: start}, nil
+	case '<', '>', '=', '!':
+		l.pos++
+		if l.pos < len(l.input) && l.input[l.pos] == '=' {
+			l.pos++
+		}
+		op := string(l.input[start:l.pos])
+		if op == "!" {
+			return Token{}, &Error{Pos: start, Message: "无效的运算符'!'，是否想使用'!='"}
+		}
+		return Token{Type: TokenOp, Text: op, Pos: start}, nil
+	case '"':
+		return l.lexString()
+	}
+
+	// 普通词，反斜杠可以转义任意字符，转义序列原样保留
+	var sb strings.Builder
+	for l.pos < len(l.input) && isWordRune(l.input[l.pos]) {
+		if l.input[l.pos] == '\\' && l.pos+1 < len(l.input) {
+			sb.WriteRune('\\')
+			l.pos++
+		}
+		sb.WriteRune(l.input[l.pos])
+		l.pos++
+	}
+
+	// 运算符不区分大小写，要匹配and、or、not这些词本身需要加引号
+	text := sb.String()
+	switch strings.ToUpper(text) {
+	case "AND":
+		return Token{Type: TokenAnd, Text: text, Pos: start}, nil
+	case "OR":
+		return Token{Type: TokenOr, Text: text, Pos: start}, nil
+	case "NOT":
+		return Token{Type: TokenNot, Text: text, Pos: start}, nil
+	}
+	return Token{Type: TokenWord, Text: text, Pos: start}, nil
+}
+
+// lexString 读取双引号字符串，转义序列原样保留，由编译阶段统一处理
+func (l *lexer) lexString() (Token, error) {
+	start := l.pos
+	l.pos++ // 跳过开头的引号
+
+	var sb strings.Builder
+	for l.pos < len(l.input) {
+		r := l.input[l.pos]
+		switch {
+		case r == '"':
+			l.pos++
+			return Token{Type: TokenString, Text: sb.String(), Pos: start}, nil
+		case r == '\\' && l.pos+1 < len(l.input):
+			sb.WriteRune(r)
+			sb.WriteRune(l.input[l.pos+1])
+			l.pos += 2
+		default:
+			sb.WriteRune(r)
+			l.pos++
+		}
+	}
+	return Token{}, &Error{Pos: start, Message: "字符串缺少结束引号"}
+}
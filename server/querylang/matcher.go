@@ -0,0 +1,191 @@
+package querylang
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Record 按列名读取一条记录的值，用于在内存中求值
+type Record interface {
+	Get(column string) (interface{}, bool)
+}
+
+// Predicate 判断记录是否满足查询条件
+type Predicate func(r Record) bool
+
+// Predicate 解析并编译查询字符串为内存中的判断函数，语义与Compile生成的WHERE条件相同
+// 空查询匹配全部记录
+func (c *Compiler) Predicate(input string) (Predicate, error) {
+	node, err := Parse(input)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return func(Record) bool { return true }, nil
+	}
+	return c.predicate(node)
+}
+
+func (c *Compiler) predicate(node Node) (Predicate, error) {
+	switch n := node.(type) {
+	case *BinaryExpr:
+		left, err := c.predicate(n.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := c.predicate(n.Right)
+		if err != nil {
+			return nil, err
+		}
+		if n.Op == "OR" {
+			return func(r Record) bool { return left(r) || right(r) }, nil
+		}
+		return func(r Record) bool { return left(r) && right(r) }, nil
+
+	case *NotExpr:
+		inner, err := c.predicate(n.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return func(r Record) bool { return !inner(r) }, nil
+
+	case *TermExpr:
+		return c.termPredicate(n)
+	}
+	return nil, fmt.Errorf("未知的语法树节点: %T", node)
+}
+
+func (c *Compiler) termPredicate(t *TermExpr) (Predicate, error) {
+	// 与compileTerm使用相同的校验和参数，保证两种求值方式接受相同的查询
+	column, op, arg, err := c.resolveTerm(t)
+	if err != nil {
+		return nil, err
+	}
+
+	if op == "LIKE" {
+		re := likeRegexp(arg.(string))
+		return func(r Record) bool {
+			v, ok := r.Get(column)
+			return ok && re.MatchString(fmt.Sprint(v))
+		}, nil
+	}
+
+	return func(r Record) bool {
+		v, ok := r.Get(column)
+		if !ok {
+			return false
+		}
+		cmp, ok := compareValues(v, arg)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "=":
+			return cmp == 0
+		case "!=":
+			return cmp != 0
+		case ">":
+			return cmp > 0
+		case ">=":
+			return cmp >= 0
+		case "<":
+			return cmp < 0
+		case "<=":
+			return cmp <= 0
+		}
+		return false
+	}, nil
+}
+
+// likeRegexp 将LIKE模式转换为正则表达式，%匹配任意字符串，_匹配单个字符，\转义
+func likeRegexp(pattern string) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("(?s)^")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; {
+		case r == '\\' && i+1 < len(runes):
+			i++
+			sb.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case r == '%':
+			sb.WriteString(".*")
+		case r == '_':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
+
+// compareValues 比较记录中的值和convertValue转换后的参数，类型不兼容时返回false
+func compareValues(v, arg interface{}) (int, bool) {
+	switch a := arg.(type) {
+	case int64:
+		n, ok := toInt64(v)
+		if !ok {
+			return 0, false
+		}
+		return compareOrdered(n, a), true
+	case float64:
+		f, ok := toFloat64(v)
+		if !ok {
+			return 0, false
+		}
+		return compareOrdered(f, a), true
+	case time.Time:
+		t, ok := v.(time.Time)
+		if !ok {
+			return 0, false
+		}
+		return t.Compare(a), true
+	case string:
+		return strings.Compare(fmt.Sprint(v), a), true
+	}
+	return 0, false
+}
+
+func compareOrdered[T int64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch x := v.(type) {
+	case int64:
+		return x, true
+	case int32:
+		return int64(x), true
+	case int:
+		return int64(x), true
+	case string:
+		n, err := strconv.ParseInt(x, 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case float32:
+		return float64(x), true
+	case string:
+		f, err := strconv.ParseFloat(x, 64)
+		return f, err == nil
+	}
+	if n, ok := toInt64(v); ok {
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,247 @@
+package querylang
+
+import "fmt"
+
+// 解析限制，防止恶意构造的查询耗尽资源
+const (
+	maxDepth = 32
+	maxTerms = 100
+)
+
+// Node 语法树节点
+type Node interface {
+	node()
+}
+
+// BinaryExpr 表示AND/OR组合
+type BinaryExpr struct {
+	Op    string // "AND" 或 "OR"
+	Left  Node
+	Right Node
+}
+
+// NotExpr 表示取反
+type NotExpr struct {
+	Expr Node
+	Pos  int
+}
+
+// TermExpr 表示单个条件，如 level:ERROR、v1>500，或不带字段的全文匹配
+type TermExpr struct {
+	Field    string // 为空表示使用默认字段
+	FieldPos int
+	Op       string // ":" 表示匹配，其余为比较运算符
+	Value    string
+	Quoted   bool
+	ValuePos int
+}
+
+func (*BinaryExpr) node() {}
+func (*NotExpr) node()    {}
+func (*TermExpr) node()   {}
+
+// parser 递归下降解析器
+//
+//	expr    := and ( OR and )*
+//	and     := unary ( [AND] unary )*
+//	unary   := NOT unary | primary
+//	primary := '(' expr ')' | FIELD ':' '(' expr ')' | FIELD ':' value | FIELD op value | value
+type parser struct {
+	tokens []Token
+	pos    int
+	depth  int
+	terms  int
+	// field 字段分组内的当前字段，如 platform:(iOS OR Android)
+	field    string
+	fieldPos int
+}
+
+// Parse 将查询字符串解析为语法树，空查询返回nil
+func Parse(input string) (Node, error) {
+	tokens, err := Lex(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	if p.peek().Type == TokenEOF {
+		return nil, nil
+	}
+
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.Type != TokenEOF {
+		return nil, p.unexpected(tok)
+	}
+	return node, nil
+}
+
+func (p *parser) peek() Token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) advance() Token {
+	tok := p.tokens[p.pos]
+	if tok.Type != TokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) unexpected(tok Token) error {
+	if tok.Type == TokenEOF {
+		return &Error{Pos: tok.Pos, Message: "查询意外结束"}
+	}
+	return &Error{Pos: tok.Pos, Message: fmt.Sprintf("意外的%s %q", tok.Type, tok.Text)}
+}
+
+func (p *parser) enter(pos int) error {
+	p.depth++
+	if p.depth > maxDepth {
+		return &Error{Pos: pos, Message: fmt.Sprintf("嵌套层数超过%d", maxDepth)}
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().Type == TokenOr {
+		p.advance()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: "OR", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok := p.peek()
+		if tok.Type == TokenAnd {
+			p.advance()
+		} else if tok.Type != TokenWord && tok.Type != TokenString &&
+			tok.Type != TokenNot && tok.Type != TokenLParen {
+			// 相邻的条件视为隐式AND
+			return left, nil
+		}
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: "AND", Left: left, Right: right}
+	}
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	if tok := p.peek(); tok.Type == TokenNot {
+		p.advance()
+		if err := p.enter(tok.Pos); err != nil {
+			return nil, err
+		}
+		expr, err := p.parseUnary()
+		p.depth--
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpr{Expr: expr, Pos: tok.Pos}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	tok := p.advance()
+	switch tok.Type {
+	case TokenLParen:
+		return p.parseGroup(tok, p.field, p.fieldPos)
+
+	case TokenWord, TokenString:
+		next := p.peek()
+
+		// FIELD ':' ...
+		if tok.Type == TokenWord && next.Type == TokenColon {
+			if p.field != "" {
+				return nil, &Error{Pos: tok.Pos, Message: "字段分组内不能再指定字段"}
+			}
+			p.advance()
+			value := p.advance()
+			switch value.Type {
+			case TokenLParen:
+				return p.parseGroup(value, tok.Text, tok.Pos)
+			case TokenWord, TokenString:
+				return p.term(tok.Text, tok.Pos, ":", value)
+			}
+			return nil, p.unexpected(value)
+		}
+
+		// FIELD op value
+		if tok.Type == TokenWord && next.Type == TokenOp {
+			if p.field != "" {
+				return nil, &Error{Pos: tok.Pos, Message: "字段分组内不能再指定字段"}
+			}
+			op := p.advance()
+			value := p.advance()
+			if value.Type != TokenWord && value.Type != TokenString {
+				return nil, p.unexpected(value)
+			}
+			return p.term(tok.Text, tok.Pos, op.Text, value)
+		}
+
+		// 不带字段的值：字段分组内使用分组字段，否则使用默认字段
+		return p.term(p.field, p.fieldPos, ":", tok)
+	}
+	return nil, p.unexpected(tok)
+}
+
+// parseGroup 解析括号内的表达式，field不为空时括号内的值都作用于该字段
+func (p *parser) parseGroup(open Token, field string, fieldPos int) (Node, error) {
+	if err := p.enter(open.Pos); err != nil {
+		return nil, err
+	}
+
+	savedField, savedPos := p.field, p.fieldPos
+	p.field, p.fieldPos = field, fieldPos
+	node, err := p.parseOr()
+	p.field, p.fieldPos = savedField, savedPos
+	p.depth--
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.advance(); tok.Type != TokenRParen {
+		if tok.Type == TokenEOF {
+			return nil, &Error{Pos: open.Pos, Message: "括号未闭合"}
+		}
+		return nil, p.unexpected(tok)
+	}
+	return node, nil
+}
+
+func (p *parser) term(field string, fieldPos int, op string, value Token) (Node, error) {
+	p.terms++
+	if p.terms > maxTerms {
+		return nil, &Error{Pos: value.Pos, Message: fmt.Sprintf("条件数量超过%d", maxTerms)}
+	}
+	return &TermExpr{
+		Field:    field,
+		FieldPos: fieldPos,
+		Op:       op,
+		Value:    value.Text,
+		Quoted:   value.Type == TokenString,
+		ValuePos: value.Pos,
+	}, nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"server/models"
+	"server/router"
+)
+
+// 多个接口共用的参数定义，用于OpenAPI文档和请求校验
+
+// timeRangeParams 时间范围
+var timeRangeParams = []router.Parameter{
+	router.Time("start_time", "开始时间"),
+	router.Time("end_time", "结束时间"),
+}
+
+// logFilterParams /api/logs 系列接口的筛选和排序参数，与controllers.parseLogsQueryParams一致
+var logFilterParams = params(timeRangeParams, []router.Parameter{
+	router.String("user_id", "用户ID"),
+	router.String("platform", "平台"),
+	router.String("os", "操作系统"),
+	router.String("category", "日志类别"),
+	router.String("level", "日志级别，如ERROR"),
+	router.String("action", "操作类型"),
+	router.String("project", "项目(app_id)，全部项目表示不筛选"),
+	router.String("log_type", "日志级别，取/api/logs/types返回的value，也接受错误日志等显示名称，全部日志表示不筛选"),
+	router.String("msg_filter", "消息内容"),
+	router.String("device_id", "设备ID"),
+	router.String("model", "设备型号"),
+	router.String("q", "查询语言表达式，如 level:ERROR AND platform:(iOS OR Android)"),
+	router.String("sort_field", "排序字段，kv_7的列名").OneOf(models.KV7Columns()...).DefaultValue("data_time"),
+	router.String("sort_order", "排序方向").OneOf("asc", "desc").DefaultValue("desc"),
+})
+
+// logPageParams /api/logs 的分页参数
+var logPageParams = []router.Parameter{
+	router.Int("page", "页码").Min(1).DefaultValue("1"),
+	router.Int("page_size", "每页条数").Range(1, 100).DefaultValue("20"),
+	router.Cursor("cursor", "分页游标，指定时代替page"),
+}
+
+// tableQueryParams /api/query 和 /api/query/kv7 的分页和时间范围参数
+var tableQueryParams = params(timeRangeParams, []router.Parameter{
+	router.Int("limit", "返回条数").Min(1),
+	router.Int("offset", "跳过条数").Min(0).DefaultValue("0"),
+	router.Cursor("cursor", "分页游标，指定时代替offset"),
+	router.Bool("count", "是否返回总条数").DefaultValue("false"),
+})
+
+// exportParams 同步导出和异步导出任务的参数，与controllers.parseExportRequest一致
+var exportParams = []router.Parameter{
+	router.String("format", "导出格式").OneOf("csv", "ndjson", "parquet", "xlsx").DefaultValue("csv"),
+	router.Bool("gzip", "是否gzip压缩，仅csv和ndjson"),
+	router.Int("limit", "最多导出的行数，0表示不限制").Min(0),
+	router.String("columns", "导出的列，逗号分隔，可使用别名和映射的语义名"),
+}
+
+// documentFields 创建和更新文档的请求体
+var documentFields = []router.Parameter{
+	router.String("title", "标题"),
+	router.String("type", "类型，如document、spreadsheet"),
+	router.String("content", "内容"),
+}
+
+// fieldMappingFields 字段映射的请求体，取值的校验见models.FieldMappingRegistry
+var fieldMappingFields = []router.Parameter{
+	router.String("name", "语义名称，小写字母、数字和下划线，以字母开头").Require(),
+	router.String("type", "值的类型").OneOf(models.FieldMappingTypes()...).Require(),
+	router.String("unit", "单位"),
+	router.String("description", "说明"),
+}
+
+// savedQueryFields 保存和更新查询的请求体
+var savedQueryFields = []router.Parameter{
+	router.String("name", "名称").Require(),
+	router.String("description", "说明"),
+	router.String("query", "SQL语句，可包含{name}或{name:Type}形式的参数").Require(),
+	router.Array("tags", router.TypeString, "标签"),
+	router.Array("parameters", router.TypeObject, "参数定义，包含name、type、default和description"),
+}
+
+// params 合并多组参数
+func params(groups ...[]router.Parameter) []router.Parameter {
+	var all []router.Parameter
+	for _, g := range groups {
+		all = append(all, g...)
+	}
+	return all
+}
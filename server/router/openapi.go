@@ -0,0 +1,279 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"server/utils"
+)
+
+// Info OpenAPI文档的基本信息
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// operation OpenAPI中一个路径的一个方法
+type operation struct {
+	Summary     string                   `json:"summary"`
+	OperationID string                   `json:"operationId"`
+	Tags        []string                 `json:"tags,omitempty"`
+	Parameters  []map[string]interface{} `json:"parameters,omitempty"`
+	RequestBody map[string]interface{}   `json:"requestBody,omitempty"`
+	Responses   map[string]interface{}   `json:"responses"`
+}
+
+// Status 声明成功时的状态码，只用于文档，默认为200
+func (route *Route) Status(code int) *Route {
+	route.status = code
+	return route
+}
+
+// OpenAPI 根据路由表和参数定义生成OpenAPI 3.0文档
+func (rt *Router) OpenAPI(info Info) map[string]interface{} {
+	paths := make(map[string]map[string]*operation)
+	for _, route := range rt.Routes() {
+		if paths[route.Path] == nil {
+			paths[route.Path] = make(map[string]*operation)
+		}
+		paths[route.Path][strings.ToLower(route.Method)] = route.operation()
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    info,
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": map[string]interface{}{
+				"Meta": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"query_id":   map[string]string{"type": "string"},
+						"query_ids":  map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}},
+						"elapsed_ms": map[string]string{"type": "integer"},
+						"rows_read":  map[string]string{"type": "integer"},
+						"source":     map[string]string{"type": "string"},
+						"table_rows": map[string]string{"type": "integer"},
+						"warning":    map[string]string{"type": "string"},
+					},
+				},
+				"Error": errorSchema(map[string]string{}),
+				"ValidationError": errorSchema(map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"in":     map[string]interface{}{"type": "string", "enum": []string{InPath, InQuery, InBody}},
+							"field":  map[string]string{"type": "string"},
+							"reason": map[string]string{"type": "string"},
+							"value":  map[string]string{"type": "string"},
+						},
+					},
+				}),
+			},
+		},
+	}
+}
+
+// errorSchema 错误响应的Schema，details为错误对象中补充信息的Schema
+func errorSchema(details interface{}) map[string]interface{} {
+	var codes []string
+	for _, entry := range utils.ErrorCatalog() {
+		codes = append(codes, string(entry.Code))
+	}
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"error": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"code":    map[string]interface{}{"type": "string", "enum": codes},
+					"message": map[string]string{"type": "string"},
+					"detail":  map[string]string{"type": "string"},
+					"details": details,
+				},
+				"required": []string{"code", "message"},
+			},
+			"meta": map[string]string{"$ref": "#/components/schemas/Meta"},
+		},
+	}
+}
+
+// OpenAPIHandler 返回OpenAPI文档的处理函数，用于 GET /api/openapi.json
+// 文档本身不使用统一的响应结构，不经过utils.RespondWithJSON
+func (rt *Router) OpenAPIHandler(info Info) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(rt.OpenAPI(info))
+	}
+}
+
+// operation 生成路由的OpenAPI描述
+func (route *Route) operation() *operation {
+	op := &operation{
+		Summary:     route.Summary,
+		OperationID: operationID(route.Method, route.Path),
+		Responses:   make(map[string]interface{}),
+	}
+	if parts := splitPath(route.Path); len(parts) > 1 {
+		op.Tags = []string{parts[1]}
+	}
+
+	for _, name := range route.Params {
+		op.Parameters = append(op.Parameters, map[string]interface{}{
+			"name":     name,
+			"in":       InPath,
+			"required": true,
+			"schema":   map[string]string{"type": "string"},
+		})
+	}
+	for _, p := range route.query {
+		param := map[string]interface{}{
+			"name":   p.Name,
+			"in":     InQuery,
+			"schema": p.schema(),
+		}
+		if p.Description != "" {
+			param["description"] = p.description()
+		}
+		if p.Required {
+			param["required"] = true
+		}
+		op.Parameters = append(op.Parameters, param)
+	}
+
+	if route.bodyType != "" {
+		schema := map[string]interface{}{}
+		switch {
+		case len(route.body) > 0:
+			properties := make(map[string]interface{}, len(route.body))
+			var required []string
+			for _, f := range route.body {
+				property := f.schema()
+				if f.Description != "" {
+					property["description"] = f.description()
+				}
+				properties[f.Name] = property
+				if f.Required {
+					required = append(required, f.Name)
+				}
+			}
+			schema["type"] = "object"
+			schema["properties"] = properties
+			if len(required) > 0 {
+				schema["required"] = required
+			}
+		case route.bodyType != "application/json":
+			schema["type"] = "string"
+		}
+		op.RequestBody = map[string]interface{}{
+			"required": true,
+			"content":  map[string]interface{}{route.bodyType: map[string]interface{}{"schema": schema}},
+		}
+	}
+
+	status := route.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	op.Responses[strconv.Itoa(status)] = map[string]string{"description": http.StatusText(status)}
+	if len(route.query) > 0 || len(route.body) > 0 {
+		op.Responses["400"] = errorResponse("请求参数无效", "ValidationError")
+	}
+	if len(route.Params) > 0 {
+		op.Responses["404"] = errorResponse("资源不存在", "Error")
+	}
+	op.Responses["default"] = errorResponse("其他错误", "Error")
+	return op
+}
+
+// schema 生成参数的JSON Schema
+func (p Parameter) schema() map[string]interface{} {
+	schema := map[string]interface{}{}
+	switch p.Type {
+	case TypeTime:
+		schema["type"] = TypeString
+		schema["example"] = "2024-01-01T00:00:00Z"
+	case TypeCursor:
+		schema["type"] = TypeString
+	case TypeArray:
+		schema["type"] = TypeArray
+		items := Parameter{Type: p.Items, Enum: p.Enum}
+		if p.Items == "" {
+			items.Type = TypeString
+		}
+		schema["items"] = items.schema()
+		return schema
+	default:
+		schema["type"] = p.Type
+	}
+	if len(p.Enum) > 0 {
+		schema["enum"] = p.Enum
+	}
+	if p.Minimum != nil {
+		schema["minimum"] = *p.Minimum
+	}
+	if p.Maximum != nil {
+		schema["maximum"] = *p.Maximum
+	}
+	if p.Default != "" {
+		schema["default"] = p.defaultValue()
+	}
+	return schema
+}
+
+// description 参数说明，时间参数补充接受的格式
+func (p Parameter) description() string {
+	if p.Type == TypeTime {
+		return p.Description + "，RFC3339或YYYY-MM-DD HH:MM格式"
+	}
+	return p.Description
+}
+
+// defaultValue 按参数类型转换默认值
+func (p Parameter) defaultValue() interface{} {
+	switch p.Type {
+	case TypeInteger, TypeNumber:
+		if f, err := strconv.ParseFloat(p.Default, 64); err == nil {
+			return f
+		}
+	case TypeBoolean:
+		if b, err := strconv.ParseBool(p.Default); err == nil {
+			return b
+		}
+	}
+	return p.Default
+}
+
+// errorResponse 引用错误响应的Schema
+func errorResponse(description, schema string) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"schema": map[string]string{"$ref": "#/components/schemas/" + schema},
+			},
+		},
+	}
+}
+
+// operationID 由方法和路径生成操作ID，如 GET /api/logs/{id} -> get_logs_by_id
+func operationID(method, path string) string {
+	parts := []string{strings.ToLower(method)}
+	for i, part := range splitPath(path) {
+		if i == 0 && part == "api" {
+			continue
+		}
+		if strings.HasPrefix(part, "{") {
+			part = "by_" + strings.Trim(part, "{}")
+		}
+		parts = append(parts, strings.NewReplacer("-", "_", ".", "_").Replace(part))
+	}
+	return strings.Join(parts, "_")
+}
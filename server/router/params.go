@@ -0,0 +1,253 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"server/utils"
+)
+
+// 参数类型，time为时间字符串，接受的格式见utils.ParseTime；cursor为响应中的pagination.next_cursor
+const (
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	TypeTime    = "time"
+	TypeCursor  = "cursor"
+	TypeArray   = "array"
+	TypeObject  = "object"
+)
+
+// maxListedEnum 错误原因中最多列出的可选值个数
+const maxListedEnum = 10
+
+// 参数位置
+const (
+	InPath  = "path"
+	InQuery = "query"
+	InBody  = "body"
+)
+
+// Parameter 路径参数、查询参数或JSON请求体字段的定义，用于生成OpenAPI文档和校验请求
+type Parameter struct {
+	Name        string
+	In          string
+	Type        string
+	Description string
+	Required    bool
+	Enum        []string
+	Minimum     *float64
+	Maximum     *float64
+	Default     string
+	// Items 数组元素的类型
+	Items string
+}
+
+// String 字符串参数
+func String(name, description string) Parameter {
+	return Parameter{Name: name, Type: TypeString, Description: description}
+}
+
+// Int 整数参数
+func Int(name, description string) Parameter {
+	return Parameter{Name: name, Type: TypeInteger, Description: description}
+}
+
+// Number 数值参数
+func Number(name, description string) Parameter {
+	return Parameter{Name: name, Type: TypeNumber, Description: description}
+}
+
+// Bool 布尔参数，查询参数接受true、false、1、0
+func Bool(name, description string) Parameter {
+	return Parameter{Name: name, Type: TypeBoolean, Description: description}
+}
+
+// Time 时间参数
+func Time(name, description string) Parameter {
+	return Parameter{Name: name, Type: TypeTime, Description: description}
+}
+
+// Cursor 分页游标参数，取值为上一页响应中的pagination.next_cursor
+func Cursor(name, description string) Parameter {
+	return Parameter{Name: name, Type: TypeCursor, Description: description}
+}
+
+// Array 数组字段，只用于请求体
+func Array(name, items, description string) Parameter {
+	return Parameter{Name: name, Type: TypeArray, Items: items, Description: description}
+}
+
+// Object 对象字段，只用于请求体
+func Object(name, description string) Parameter {
+	return Parameter{Name: name, Type: TypeObject, Description: description}
+}
+
+// Require 标记为必填
+func (p Parameter) Require() Parameter {
+	p.Required = true
+	return p
+}
+
+// OneOf 限定取值
+func (p Parameter) OneOf(values ...string) Parameter {
+	p.Enum = values
+	return p
+}
+
+// Min 限定最小值
+func (p Parameter) Min(min float64) Parameter {
+	p.Minimum = &min
+	return p
+}
+
+// Max 限定最大值
+func (p Parameter) Max(max float64) Parameter {
+	p.Maximum = &max
+	return p
+}
+
+// Range 限定取值范围，包含两端
+func (p Parameter) Range(min, max float64) Parameter {
+	return p.Min(min).Max(max)
+}
+
+// DefaultValue 默认值，只用于文档
+func (p Parameter) DefaultValue(value string) Parameter {
+	p.Default = value
+	return p
+}
+
+// checkQuery 校验查询参数或路径参数的值，返回不通过的原因
+func (p Parameter) checkQuery(value string) string {
+	switch p.Type {
+	case TypeInteger:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "必须是整数"
+		}
+		return p.checkRange(float64(n))
+	case TypeNumber:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return "必须是数值"
+		}
+		return p.checkRange(f)
+	case TypeBoolean:
+		switch value {
+		case "true", "false", "1", "0":
+			return ""
+		}
+		return "必须是true或false"
+	case TypeTime:
+		if _, err := utils.ParseTime(value); err != nil {
+			return "必须是RFC3339或YYYY-MM-DD HH:MM格式的时间"
+		}
+		return ""
+	case TypeCursor:
+		if _, err := utils.DecodeCursor(value); err != nil {
+			return "必须是响应中的next_cursor"
+		}
+		return ""
+	}
+	return p.checkEnum(value)
+}
+
+// checkBody 校验JSON请求体字段的值，返回不通过的原因
+func (p Parameter) checkBody(raw json.RawMessage) string {
+	if string(raw) == "null" {
+		if p.Required {
+			return "不能为空"
+		}
+		return ""
+	}
+
+	switch p.Type {
+	case TypeString, TypeTime, TypeCursor:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return "必须是字符串"
+		}
+		if p.Required && s == "" {
+			return "不能为空"
+		}
+		if s == "" {
+			return ""
+		}
+		if p.Type == TypeTime || p.Type == TypeCursor {
+			return p.checkQuery(s)
+		}
+		return p.checkEnum(s)
+	case TypeInteger, TypeNumber:
+		var f float64
+		if err := json.Unmarshal(raw, &f); err != nil {
+			return "必须是数值"
+		}
+		if p.Type == TypeInteger && f != math.Trunc(f) {
+			return "必须是整数"
+		}
+		return p.checkRange(f)
+	case TypeBoolean:
+		var b bool
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return "必须是true或false"
+		}
+	case TypeArray:
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return "必须是数组"
+		}
+		if p.Items == "" {
+			return ""
+		}
+		item := Parameter{Type: p.Items, Enum: p.Enum}
+		for i, v := range items {
+			if reason := item.checkBody(v); reason != "" {
+				return fmt.Sprintf("第%d个元素%s", i+1, reason)
+			}
+		}
+	case TypeObject:
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return "必须是对象"
+		}
+	}
+	return ""
+}
+
+// checkRange 检查数值范围
+func (p Parameter) checkRange(f float64) string {
+	switch {
+	case p.Minimum != nil && p.Maximum != nil && (f < *p.Minimum || f > *p.Maximum):
+		return fmt.Sprintf("必须在%s到%s之间", formatNumber(*p.Minimum), formatNumber(*p.Maximum))
+	case p.Minimum != nil && f < *p.Minimum:
+		return "不能小于" + formatNumber(*p.Minimum)
+	case p.Maximum != nil && f > *p.Maximum:
+		return "不能大于" + formatNumber(*p.Maximum)
+	}
+	return ""
+}
+
+// checkEnum 检查取值是否在允许的范围内
+func (p Parameter) checkEnum(value string) string {
+	if len(p.Enum) == 0 {
+		return ""
+	}
+	for _, v := range p.Enum {
+		if v == value {
+			return ""
+		}
+	}
+	if len(p.Enum) > maxListedEnum {
+		return "不是可选的值，可选值见OpenAPI文档"
+	}
+	return "可选值为" + strings.Join(p.Enum, "、")
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
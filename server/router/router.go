@@ -0,0 +1,274 @@
+// Package router 按请求方法和路径分发请求，支持路径参数和版本前缀
+package router
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+
+	"server/utils"
+)
+
+// Route 一条已注册的路由
+type Route struct {
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Summary string `json:"summary"`
+	// Params 路径参数名，按出现顺序排列
+	Params []string `json:"params,omitempty"`
+
+	segments []segment
+	handler  http.HandlerFunc
+	query    []Parameter
+	bodyType string
+	body     []Parameter
+	status   int
+}
+
+// Query 声明查询参数，用于生成OpenAPI文档和校验请求
+func (route *Route) Query(params ...Parameter) *Route {
+	for _, p := range params {
+		p.In = InQuery
+		route.query = append(route.query, p)
+	}
+	return route
+}
+
+// Body 声明请求体的类型，类型为application/json时fields为顶层字段的定义
+func (route *Route) Body(contentType string, fields ...Parameter) *Route {
+	route.bodyType = contentType
+	for _, f := range fields {
+		f.In = InBody
+		route.body = append(route.body, f)
+	}
+	return route
+}
+
+// Middleware 包装已匹配路由的处理函数，可以读取路由的参数定义
+type Middleware func(route *Route, next http.HandlerFunc) http.HandlerFunc
+
+// segment 路径中的一段，param为true时匹配任意非空值
+type segment struct {
+	value string
+	param bool
+}
+
+// Router 路由表，路径参数写作{name}，如 /api/logs/{id}
+// 固定的段优先于参数，/api/logs/fields 不会匹配到 /api/logs/{id}
+type Router struct {
+	routes      []*Route
+	middlewares []Middleware
+	// aliases 版本前缀到实际前缀的映射，如 /api/v1 -> /api
+	aliases map[string]string
+}
+
+// New 创建空的路由表
+func New() *Router {
+	return &Router{aliases: make(map[string]string)}
+}
+
+// Alias 使prefix开头的请求按target开头的路由处理，用于版本前缀，如 Alias("/api/v1", "/api")
+func (rt *Router) Alias(prefix, target string) {
+	rt.aliases[strings.TrimSuffix(prefix, "/")] = strings.TrimSuffix(target, "/")
+}
+
+// Use 添加中间件，按添加顺序由外到内执行
+func (rt *Router) Use(mw Middleware) {
+	rt.middlewares = append(rt.middlewares, mw)
+}
+
+// Handle 注册路由，同一路径的不同方法分别注册，返回的路由可继续声明参数
+func (rt *Router) Handle(method, path, summary string, handler http.HandlerFunc) *Route {
+	route := &Route{Method: method, Path: path, Summary: summary, handler: handler}
+	for _, part := range splitPath(path) {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			name := part[1 : len(part)-1]
+			route.segments = append(route.segments, segment{value: name, param: true})
+			route.Params = append(route.Params, name)
+			continue
+		}
+		route.segments = append(route.segments, segment{value: part})
+	}
+	for _, existing := range rt.routes {
+		if existing.Method == method && existing.Path == path {
+			panic("路由重复注册: " + method + " " + path)
+		}
+	}
+	rt.routes = append(rt.routes, route)
+	return route
+}
+
+// Get 注册GET路由，同时响应HEAD请求
+func (rt *Router) Get(path, summary string, handler http.HandlerFunc) *Route {
+	return rt.Handle(http.MethodGet, path, summary, handler)
+}
+
+// Post 注册POST路由
+func (rt *Router) Post(path, summary string, handler http.HandlerFunc) *Route {
+	return rt.Handle(http.MethodPost, path, summary, handler)
+}
+
+// Put 注册PUT路由
+func (rt *Router) Put(path, summary string, handler http.HandlerFunc) *Route {
+	return rt.Handle(http.MethodPut, path, summary, handler)
+}
+
+// Delete 注册DELETE路由
+func (rt *Router) Delete(path, summary string, handler http.HandlerFunc) *Route {
+	return rt.Handle(http.MethodDelete, path, summary, handler)
+}
+
+// Routes 返回按路径和方法排序的路由表
+func (rt *Router) Routes() []Route {
+	routes := make([]Route, 0, len(rt.routes))
+	for _, route := range rt.routes {
+		routes = append(routes, *route)
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return methodOrder(routes[i].Method) < methodOrder(routes[j].Method)
+	})
+	return routes
+}
+
+// ServeRoutes 返回路由表，用于 GET /api/routes
+func (rt *Router) ServeRoutes(w http.ResponseWriter, r *http.Request) {
+	aliases := make([]map[string]string, 0, len(rt.aliases))
+	for prefix, target := range rt.aliases {
+		aliases = append(aliases, map[string]string{"prefix": prefix, "target": target})
+	}
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"routes":  rt.Routes(),
+		"aliases": aliases,
+	})
+}
+
+// ServeHTTP 实现http.Handler
+// 路径不存在返回404，路径存在但方法不支持返回405并在Allow头中列出支持的方法
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := rt.resolveAlias(r.URL.Path)
+	parts := splitPath(path)
+
+	method := r.Method
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+
+	var best *Route
+	var bestParams map[string]string
+	var allowed []string
+	for _, route := range rt.routes {
+		params, ok := route.match(parts)
+		if !ok {
+			continue
+		}
+		if route.Method != method {
+			allowed = append(allowed, route.Method)
+			continue
+		}
+		if best == nil || route.moreSpecific(best) {
+			best, bestParams = route, params
+		}
+	}
+
+	if best == nil {
+		if len(allowed) > 0 {
+			sort.Slice(allowed, func(i, j int) bool { return methodOrder(allowed[i]) < methodOrder(allowed[j]) })
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			utils.RespondWithError(w, http.StatusMethodNotAllowed, "允许的方法: "+strings.Join(allowed, ", "))
+			return
+		}
+		utils.RespondWithError(w, http.StatusNotFound, "接口不存在: "+r.URL.Path)
+		return
+	}
+
+	if len(bestParams) > 0 {
+		r = r.WithContext(context.WithValue(r.Context(), paramsKey{}, bestParams))
+	}
+	handler := best.handler
+	for i := len(rt.middlewares) - 1; i >= 0; i-- {
+		handler = rt.middlewares[i](best, handler)
+	}
+	handler(w, r)
+}
+
+// resolveAlias 将版本前缀替换为实际前缀
+func (rt *Router) resolveAlias(path string) string {
+	for prefix, target := range rt.aliases {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return target + strings.TrimPrefix(path, prefix)
+		}
+	}
+	return path
+}
+
+// match 判断路径是否匹配，返回路径参数
+func (route *Route) match(parts []string) (map[string]string, bool) {
+	if len(parts) != len(route.segments) {
+		return nil, false
+	}
+	var params map[string]string
+	for i, seg := range route.segments {
+		if !seg.param {
+			if seg.value != parts[i] {
+				return nil, false
+			}
+			continue
+		}
+		if parts[i] == "" {
+			return nil, false
+		}
+		if params == nil {
+			params = make(map[string]string, len(route.Params))
+		}
+		params[seg.value] = parts[i]
+	}
+	return params, true
+}
+
+// moreSpecific 两条路由都匹配同一路径时，第一个不同的段为固定值的路由优先
+func (route *Route) moreSpecific(other *Route) bool {
+	for i := range route.segments {
+		if route.segments[i].param != other.segments[i].param {
+			return !route.segments[i].param
+		}
+	}
+	return false
+}
+
+// splitPath 按/拆分路径，忽略末尾的/
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return []string{}
+	}
+	return strings.Split(path, "/")
+}
+
+// methodOrder 路由表中方法的排列顺序
+func methodOrder(method string) int {
+	switch method {
+	case http.MethodGet:
+		return 0
+	case http.MethodPost:
+		return 1
+	case http.MethodPut:
+		return 2
+	case http.MethodPatch:
+		return 3
+	case http.MethodDelete:
+		return 4
+	}
+	return 5
+}
+
+type paramsKey struct{}
+
+// Param 返回当前请求的路径参数，不存在时返回空字符串
+func Param(r *http.Request, name string) string {
+	params, _ := r.Context().Value(paramsKey{}).(map[string]string)
+	return params[name]
+}
@@ -0,0 +1,121 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"server/utils"
+)
+
+// maxValidatedBodySize 校验JSON请求体时最多读取的字节数
+const maxValidatedBodySize = 1 << 20
+
+// InvalidParam 一个未通过校验的参数
+type InvalidParam struct {
+	In     string `json:"in"`
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+	Value  string `json:"value,omitempty"`
+}
+
+// Validate 按路由声明的参数校验请求，存在无效参数时返回400(invalid_params)，error.details列出每个无效参数
+// 只校验已声明的查询参数和JSON请求体字段，未声明的参数由处理函数自行处理
+func Validate(route *Route, next http.HandlerFunc) http.HandlerFunc {
+	if len(route.query) == 0 && len(route.body) == 0 {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		invalid := validateQuery(route, r)
+
+		if len(route.body) > 0 {
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxValidatedBodySize+1))
+			switch {
+			case err != nil:
+				invalid = append(invalid, InvalidParam{In: InBody, Field: "body", Reason: "读取请求体失败"})
+			case len(body) > maxValidatedBodySize:
+				invalid = append(invalid, InvalidParam{In: InBody, Field: "body", Reason: "请求体过大"})
+			default:
+				invalid = append(invalid, validateBody(route, body)...)
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+		}
+
+		if len(invalid) > 0 {
+			utils.RespondWithErrorCode(w, http.StatusBadRequest, utils.CodeInvalidParams, "", invalid)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// validateQuery 校验查询参数，同名参数出现多次时逐个校验
+func validateQuery(route *Route, r *http.Request) []InvalidParam {
+	var invalid []InvalidParam
+	values := r.URL.Query()
+	for _, p := range route.query {
+		vs := values[p.Name]
+		if len(vs) == 0 || (len(vs) == 1 && vs[0] == "") {
+			if p.Required {
+				invalid = append(invalid, InvalidParam{In: InQuery, Field: p.Name, Reason: "缺少必填参数"})
+			}
+			continue
+		}
+		for _, v := range vs {
+			if reason := p.checkQuery(v); reason != "" {
+				invalid = append(invalid, InvalidParam{In: InQuery, Field: p.Name, Reason: reason, Value: v})
+				break
+			}
+		}
+	}
+	return invalid
+}
+
+// validateBody 校验JSON请求体的顶层字段
+func validateBody(route *Route, body []byte) []InvalidParam {
+	if len(bytes.TrimSpace(body)) == 0 {
+		for _, f := range route.body {
+			if f.Required {
+				return []InvalidParam{{In: InBody, Field: "body", Reason: "请求体不能为空"}}
+			}
+		}
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		reason := "必须是JSON对象"
+		if !json.Valid(body) {
+			reason = "不是有效的JSON: " + strings.TrimPrefix(err.Error(), "json: ")
+		}
+		return []InvalidParam{{In: InBody, Field: "body", Reason: reason}}
+	}
+
+	var invalid []InvalidParam
+	for _, f := range route.body {
+		raw, ok := fields[f.Name]
+		if !ok {
+			if f.Required {
+				invalid = append(invalid, InvalidParam{In: InBody, Field: f.Name, Reason: "缺少必填字段"})
+			}
+			continue
+		}
+		if reason := f.checkBody(raw); reason != "" {
+			invalid = append(invalid, InvalidParam{In: InBody, Field: f.Name, Reason: reason, Value: compactValue(raw)})
+		}
+	}
+	return invalid
+}
+
+// compactValue 返回字段的原始值，过长时截断
+func compactValue(raw json.RawMessage) string {
+	const maxLen = 100
+	runes := []rune(string(raw))
+	if len(runes) > maxLen {
+		return string(runes[:maxLen]) + "..."
+	}
+	return string(runes)
+}
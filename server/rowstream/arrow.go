@@ -0,0 +1,308 @@
+package rowstream
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+)
+
+// arrowBatchRows 每个Arrow记录批次的行数
+const arrowBatchRows = 4096
+
+// arrowEncoder 输出Arrow IPC流，每arrowBatchRows行写出一个记录批次
+// 附加字段无法放进已发送的schema，以JSON写入X-Result-Meta响应尾部
+type arrowEncoder struct {
+	w       http.ResponseWriter
+	mem     memory.Allocator
+	schema  *arrow.Schema
+	builder *array.RecordBuilder
+	writer  *ipc.Writer
+	// converters 用于编码为字符串的列(Decimal、数组、Map等)，与JSON格式的值一致
+	converters []Converter
+	pending    int
+}
+
+func newArrowEncoder(w http.ResponseWriter) *arrowEncoder {
+	return &arrowEncoder{w: w, mem: memory.DefaultAllocator}
+}
+
+func (e *arrowEncoder) Begin(columns []Column) error {
+	fields := make([]arrow.Field, len(columns))
+	for i, c := range columns {
+		typ, nullable := arrowType(c.Type)
+		fields[i] = arrow.Field{Name: c.Name, Type: typ, Nullable: nullable}
+	}
+	e.schema = arrow.NewSchema(fields, nil)
+	e.converters = Converters(columns)
+	e.builder = array.NewRecordBuilder(e.mem, e.schema)
+
+	e.w.Header().Set("Content-Type", ContentTypeArrow)
+	e.w.Header().Set("Trailer", MetaTrailer)
+	e.w.WriteHeader(http.StatusOK)
+	e.writer = ipc.NewWriter(e.w, ipc.WithSchema(e.schema), ipc.WithAllocator(e.mem))
+	return nil
+}
+
+func (e *arrowEncoder) WriteRow(values []interface{}) error {
+	for i, v := range values {
+		if err := appendArrowValue(e.builder.Field(i), v, e.converters[i]); err != nil {
+			return fmt.Errorf("列%s: %w", e.schema.Field(i).Name, err)
+		}
+	}
+	e.pending++
+	if e.pending >= arrowBatchRows {
+		return e.flush()
+	}
+	return nil
+}
+
+func (e *arrowEncoder) Finish(meta map[string]interface{}) error {
+	defer e.builder.Release()
+
+	err := e.flush()
+	if closeErr := e.writer.Close(); err == nil {
+		err = closeErr
+	}
+
+	normalized := make(map[string]interface{}, len(meta))
+	for k, v := range meta {
+		normalized[k] = normalize(v)
+	}
+	if data, jsonErr := json.Marshal(normalized); jsonErr == nil {
+		e.w.Header().Set(MetaTrailer, string(data))
+	}
+	return err
+}
+
+// flush 写出已缓存的行
+func (e *arrowEncoder) flush() error {
+	if e.pending == 0 {
+		return nil
+	}
+	record := e.builder.NewRecord()
+	defer record.Release()
+	e.pending = 0
+	return e.writer.Write(record)
+}
+
+// arrowType 把ClickHouse类型映射为Arrow类型，不支持的类型(数组、Map、Decimal等)编码为字符串
+// DateTime64按精度使用秒、毫秒、微秒或纳秒的时间戳
+func arrowType(chType string) (arrow.DataType, bool) {
+	typ, nullable := unwrapType(chType)
+	switch {
+	case typ == "Bool":
+		return arrow.FixedWidthTypes.Boolean, nullable
+	case typ == "Int8":
+		return arrow.PrimitiveTypes.Int8, nullable
+	case typ == "Int16":
+		return arrow.PrimitiveTypes.Int16, nullable
+	case typ == "Int32":
+		return arrow.PrimitiveTypes.Int32, nullable
+	case typ == "Int64":
+		return arrow.PrimitiveTypes.Int64, nullable
+	case typ == "UInt8":
+		return arrow.PrimitiveTypes.Uint8, nullable
+	case typ == "UInt16":
+		return arrow.PrimitiveTypes.Uint16, nullable
+	case typ == "UInt32":
+		return arrow.PrimitiveTypes.Uint32, nullable
+	case typ == "UInt64":
+		return arrow.PrimitiveTypes.Uint64, nullable
+	case typ == "Float32":
+		return arrow.PrimitiveTypes.Float32, nullable
+	case typ == "Float64":
+		return arrow.PrimitiveTypes.Float64, nullable
+	case typ == "Date" || typ == "Date32":
+		return arrow.FixedWidthTypes.Date32, nullable
+	case typ == "DateTime" || strings.HasPrefix(typ, "DateTime("):
+		return arrow.FixedWidthTypes.Timestamp_s, nullable
+	case strings.HasPrefix(typ, "DateTime64"):
+		return timestampType(typ), nullable
+	case typ == "String" || typ == "UUID" || strings.HasPrefix(typ, "FixedString(") || strings.HasPrefix(typ, "Enum"):
+		return arrow.BinaryTypes.String, nullable
+	}
+	// 未知类型的值格式化为字符串，可能为空
+	return arrow.BinaryTypes.String, true
+}
+
+// timestampType 返回能容纳DateTime64(precision)的时间戳类型
+func timestampType(typ string) arrow.DataType {
+	precision := 3
+	if _, args := parseType(typ); len(args) > 0 {
+		if p, err := strconv.Atoi(args[0]); err == nil {
+			precision = p
+		}
+	}
+	switch {
+	case precision == 0:
+		return arrow.FixedWidthTypes.Timestamp_s
+	case precision <= 3:
+		return arrow.FixedWidthTypes.Timestamp_ms
+	case precision <= 6:
+		return arrow.FixedWidthTypes.Timestamp_us
+	}
+	return arrow.FixedWidthTypes.Timestamp_ns
+}
+
+// unwrapType 去掉Nullable和LowCardinality包装
+func unwrapType(chType string) (string, bool) {
+	nullable := false
+	for {
+		switch {
+		case strings.HasPrefix(chType, "Nullable(") && strings.HasSuffix(chType, ")"):
+			chType = chType[len("Nullable(") : len(chType)-1]
+			nullable = true
+		case strings.HasPrefix(chType, "LowCardinality(") && strings.HasSuffix(chType, ")"):
+			chType = chType[len("LowCardinality(") : len(chType)-1]
+		default:
+			return chType, nullable
+		}
+	}
+}
+
+// appendArrowValue 把驱动返回的值追加到对应类型的构建器，字符串列的值先按列类型转换
+func appendArrowValue(b array.Builder, v interface{}, convert Converter) error {
+	v = deref(v)
+	if v == nil {
+		b.AppendNull()
+		return nil
+	}
+
+	switch b := b.(type) {
+	case *array.BooleanBuilder:
+		x, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("无法将%T转换为Bool", v)
+		}
+		b.Append(x)
+	case *array.Int8Builder:
+		x, err := toInt64(v)
+		b.Append(int8(x))
+		return err
+	case *array.Int16Builder:
+		x, err := toInt64(v)
+		b.Append(int16(x))
+		return err
+	case *array.Int32Builder:
+		x, err := toInt64(v)
+		b.Append(int32(x))
+		return err
+	case *array.Int64Builder:
+		x, err := toInt64(v)
+		b.Append(x)
+		return err
+	case *array.Uint8Builder:
+		x, err := toUint64(v)
+		b.Append(uint8(x))
+		return err
+	case *array.Uint16Builder:
+		x, err := toUint64(v)
+		b.Append(uint16(x))
+		return err
+	case *array.Uint32Builder:
+		x, err := toUint64(v)
+		b.Append(uint32(x))
+		return err
+	case *array.Uint64Builder:
+		x, err := toUint64(v)
+		b.Append(x)
+		return err
+	case *array.Float32Builder:
+		x, err := toFloat64(v)
+		b.Append(float32(x))
+		return err
+	case *array.Float64Builder:
+		x, err := toFloat64(v)
+		b.Append(x)
+		return err
+	case *array.Date32Builder:
+		t, ok := v.(time.Time)
+		if !ok {
+			return fmt.Errorf("无法将%T转换为Date", v)
+		}
+		b.Append(arrow.Date32FromTime(t))
+	case *array.TimestampBuilder:
+		t, ok := v.(time.Time)
+		if !ok {
+			return fmt.Errorf("无法将%T转换为DateTime", v)
+		}
+		unit := b.Type().(*arrow.TimestampType).Unit
+		ts, err := arrow.TimestampFromTime(t, unit)
+		b.Append(ts)
+		return err
+	case *array.StringBuilder:
+		b.Append(formatValue(convert(v)))
+	default:
+		return fmt.Errorf("不支持的Arrow类型%s", b.Type())
+	}
+	return nil
+}
+
+// formatValue 字符串列的值，转换后不是字符串的值(数组、Map等)编码为JSON
+func formatValue(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case fmt.Stringer:
+		return x.String()
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+func toInt64(v interface{}) (int64, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), nil
+	case reflect.Bool:
+		if rv.Bool() {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("无法将%T转换为整数", v)
+}
+
+func toUint64(v interface{}) (uint64, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return uint64(rv.Int()), nil
+	case reflect.Bool:
+		if rv.Bool() {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("无法将%T转换为无符号整数", v)
+}
+
+func toFloat64(v interface{}) (float64, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	}
+	return 0, fmt.Errorf("无法将%T转换为浮点数", v)
+}
@@ -0,0 +1,387 @@
+package rowstream
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Converter 把驱动返回的值转换为JSON输出的值
+type Converter func(v interface{}) interface{}
+
+// ConverterFor 按ClickHouse列类型返回转换函数，各接口输出同一类型的值时格式一致：
+//   - Int64、UInt64及更宽的整数输出为十进制字符串，与ClickHouse的JSON格式相同，避免在JavaScript中丢失精度
+//   - Decimal输出为字符串，保留全部小数位
+//   - Date、Date32输出为2006-01-02，DateTime输出为RFC3339，DateTime64按列的精度输出小数秒
+//   - UUID、IPv4、IPv6输出为字符串
+//   - Array输出为数组，Map输出为对象(键转为字符串)，具名Tuple输出为对象，其他Tuple输出为数组，元素按各自的类型转换
+//   - Nullable为NULL时输出null，NaN和Inf输出null
+//
+// 类型为空(如模拟数据)或无法识别时按值的Go类型转换
+func ConverterFor(chType string) Converter {
+	convert := baseConverter(strings.TrimSpace(chType))
+	return func(v interface{}) interface{} {
+		v = deref(v)
+		if v == nil {
+			return nil
+		}
+		return convert(v)
+	}
+}
+
+// Converters 返回各列的转换函数
+func Converters(columns []Column) []Converter {
+	converters := make([]Converter, len(columns))
+	for i, c := range columns {
+		converters[i] = ConverterFor(c.Type)
+	}
+	return converters
+}
+
+// ReadMaps 读取整个结果集，每行转换为列名到值的映射，值按列类型转换
+// 用于需要在内存中处理结果的接口，流式输出应使用Stream
+func ReadMaps(rows Rows, columns []Column) ([]map[string]interface{}, error) {
+	converters := Converters(columns)
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	results := []map[string]interface{}{}
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, c := range columns {
+			row[c.Name] = converters[i](values[i])
+		}
+		results = append(results, row)
+	}
+	return results, rows.Err()
+}
+
+// MapColumns 返回已在内存中的行的列，按第一行的键排序，类型未知
+func MapColumns(rows []map[string]interface{}) []Column {
+	columns := []Column{}
+	if len(rows) == 0 {
+		return columns
+	}
+	for name := range rows[0] {
+		columns = append(columns, Column{Name: name})
+	}
+	sort.Slice(columns, func(i, j int) bool { return columns[i].Name < columns[j].Name })
+	return columns
+}
+
+// baseConverter 返回非NULL值的转换函数
+func baseConverter(chType string) Converter {
+	name, args := parseType(chType)
+	switch name {
+	case "Nullable", "LowCardinality":
+		if len(args) == 1 {
+			return ConverterFor(args[0])
+		}
+	case "SimpleAggregateFunction":
+		// SimpleAggregateFunction(func, Type) 的值就是Type的值
+		if len(args) == 2 {
+			return ConverterFor(args[1])
+		}
+	case "Int64", "UInt64", "Int128", "UInt128", "Int256", "UInt256":
+		return formatInteger
+	case "Decimal", "Decimal32", "Decimal64", "Decimal128", "Decimal256":
+		// Decimal(P, S)、Decimal32(S)，最后一个参数为小数位数
+		scale := 0
+		if len(args) > 0 {
+			scale, _ = strconv.Atoi(args[len(args)-1])
+		}
+		return formatDecimal(scale)
+	case "Float32", "Float64":
+		return normalize
+	case "Date", "Date32":
+		return formatTime("2006-01-02")
+	case "DateTime":
+		return formatTime(time.RFC3339)
+	case "DateTime64":
+		precision := 3
+		if len(args) > 0 {
+			if p, err := strconv.Atoi(args[0]); err == nil && p >= 0 && p <= 9 {
+				precision = p
+			}
+		}
+		layout := time.RFC3339
+		if precision > 0 {
+			layout = "2006-01-02T15:04:05." + strings.Repeat("0", precision) + "Z07:00"
+		}
+		return formatTime(layout)
+	case "UUID", "IPv4", "IPv6":
+		return formatString
+	case "Array":
+		if len(args) == 1 {
+			return convertArray(ConverterFor(args[0]))
+		}
+	case "Map":
+		if len(args) == 2 {
+			return convertMap(ConverterFor(args[0]), ConverterFor(args[1]))
+		}
+	case "Tuple":
+		if len(args) > 0 {
+			return convertTuple(args)
+		}
+	}
+	return normalize
+}
+
+// parseType 拆分类型名和括号内的顶层参数，如Map(String, Array(UInt64))拆为Map和[String, Array(UInt64)]
+func parseType(chType string) (string, []string) {
+	open := strings.IndexByte(chType, '(')
+	if open < 0 || !strings.HasSuffix(chType, ")") {
+		return chType, nil
+	}
+
+	var args []string
+	depth, start, quoted := 0, open+1, false
+	inner := chType[:len(chType)-1]
+	for i := start; i < len(inner); i++ {
+		switch c := inner[i]; {
+		case quoted:
+			if c == '\\' {
+				i++
+			} else if c == '\'' {
+				quoted = false
+			}
+		case c == '\'':
+			quoted = true
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			args = append(args, strings.TrimSpace(inner[start:i]))
+			start = i + 1
+		}
+	}
+	if rest := strings.TrimSpace(inner[start:]); rest != "" {
+		args = append(args, rest)
+	}
+	return strings.TrimSpace(chType[:open]), args
+}
+
+// formatInteger 整数输出为十进制字符串
+func formatInteger(v interface{}) interface{} {
+	switch x := v.(type) {
+	case *big.Int:
+		return x.String()
+	case big.Int:
+		return x.String()
+	case string:
+		return x
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10)
+	}
+	return normalize(v)
+}
+
+// formatDecimal Decimal输出为字符串，补齐到列的小数位数
+// 驱动直接返回的Decimal已按driver.Valuer转为字符串，数组和Tuple中的元素仍为decimal.Decimal，
+// 两者都会去掉末尾的0
+func formatDecimal(scale int) Converter {
+	return func(v interface{}) interface{} {
+		var s string
+		switch x := v.(type) {
+		case string:
+			s = x
+		case float64:
+			s = strconv.FormatFloat(x, 'f', -1, 64)
+		case fmt.Stringer:
+			s = x.String()
+		default:
+			return normalize(v)
+		}
+		return padDecimal(s, scale)
+	}
+}
+
+// padDecimal 小数部分不足scale位时补0，不截断
+func padDecimal(s string, scale int) string {
+	if scale <= 0 || strings.ContainsAny(s, "eE") {
+		return s
+	}
+	digits := 0
+	if dot := strings.IndexByte(s, '.'); dot >= 0 {
+		digits = len(s) - dot - 1
+	} else {
+		s += "."
+	}
+	if digits < scale {
+		s += strings.Repeat("0", scale-digits)
+	}
+	return s
+}
+
+// formatString UUID和IP地址输出为字符串
+func formatString(v interface{}) interface{} {
+	switch x := v.(type) {
+	case string:
+		return x
+	case net.IP:
+		return x.String()
+	case fmt.Stringer:
+		return x.String()
+	}
+	return normalize(v)
+}
+
+// formatTime 时间按layout格式化，保留列的时区
+func formatTime(layout string) Converter {
+	return func(v interface{}) interface{} {
+		if t, ok := v.(time.Time); ok {
+			return t.Format(layout)
+		}
+		return normalize(v)
+	}
+}
+
+// convertArray 数组的元素逐个转换，空数组输出[]而不是null
+func convertArray(elem Converter) Converter {
+	return func(v interface{}) interface{} {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return normalize(v)
+		}
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			items[i] = elem(rv.Index(i).Interface())
+		}
+		return items
+	}
+}
+
+// convertMap Map输出为JSON对象，非字符串的键格式化为字符串
+func convertMap(key, value Converter) Converter {
+	return func(v interface{}) interface{} {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Map {
+			return normalize(v)
+		}
+		object := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			object[mapKey(key(iter.Key().Interface()))] = value(iter.Value().Interface())
+		}
+		return object
+	}
+}
+
+// mapKey 转换后的键格式化为字符串
+func mapKey(k interface{}) string {
+	if s, ok := k.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(k)
+	if err != nil {
+		return fmt.Sprint(k)
+	}
+	return string(data)
+}
+
+// convertTuple 驱动在所有元素都有名称时返回map[string]interface{}，否则返回[]interface{}
+func convertTuple(args []string) Converter {
+	names := make([]string, len(args))
+	elems := make([]Converter, len(args))
+	byName := make(map[string]Converter, len(args))
+	for i, arg := range args {
+		typ := arg
+		// 具名元素为"name Type"，类型本身在第一个空格前必然有括号或没有空格
+		if sp := strings.IndexByte(arg, ' '); sp > 0 && !strings.Contains(arg[:sp], "(") {
+			names[i] = strings.Trim(arg[:sp], "`")
+			typ = strings.TrimSpace(arg[sp+1:])
+		}
+		elems[i] = ConverterFor(typ)
+		byName[names[i]] = elems[i]
+	}
+
+	return func(v interface{}) interface{} {
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Map:
+			object := make(map[string]interface{}, rv.Len())
+			iter := rv.MapRange()
+			for iter.Next() {
+				name := fmt.Sprint(iter.Key().Interface())
+				convert, ok := byName[name]
+				if !ok {
+					convert = normalize
+				}
+				object[name] = convert(iter.Value().Interface())
+			}
+			return object
+		case reflect.Slice, reflect.Array:
+			items := make([]interface{}, rv.Len())
+			for i := range items {
+				convert := Converter(normalize)
+				if i < len(elems) {
+					convert = elems[i]
+				}
+				items[i] = convert(rv.Index(i).Interface())
+			}
+			return items
+		}
+		return normalize(v)
+	}
+}
+
+// deref 解引用Nullable列返回的指针，nil指针返回nil
+func deref(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer {
+		return v
+	}
+	if rv.IsNil() {
+		return nil
+	}
+	if _, ok := v.(*big.Int); ok {
+		return v
+	}
+	return rv.Elem().Interface()
+}
+
+// normalize 把类型未知的值转换为适合JSON输出的形式
+// 时间格式化为RFC3339，[]byte转为字符串，NaN和Inf无法用JSON表示，输出为null
+func normalize(v interface{}) interface{} {
+	switch x := v.(type) {
+	case time.Time:
+		return x.Format(time.RFC3339)
+	case *time.Time:
+		if x == nil {
+			return nil
+		}
+		return x.Format(time.RFC3339)
+	case []byte:
+		return string(x)
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return nil
+		}
+	case float32:
+		if math.IsNaN(float64(x)) || math.IsInf(float64(x), 0) {
+			return nil
+		}
+	case net.IP:
+		return x.String()
+	}
+	return v
+}
@@ -0,0 +1,182 @@
+// Package rowstream 把查询结果逐行编码写入HTTP响应，不在内存中保存整个结果集
+// 支持三种格式，按Accept请求头选择：
+//   - application/json（默认）：{"columns": [{"name", "type"}], "data": [{列名: 值}, ...], 其他字段}
+//   - application/vnd.logwatch.columnar+json：{"columns": [{"name", "type"}], "data": [[值, ...], ...], 其他字段}
+//
+// 其他字段由调用方在读取结束后给出，接口中为统一响应结构的pagination、meta和error(见utils.Trailer)
+//   - application/vnd.apache.arrow.stream：Apache Arrow IPC流，其他字段放在X-Result-Meta响应尾部
+//
+// JSON格式中的值按列的ClickHouse类型转换，规则见ConverterFor
+package rowstream
+
+import (
+	"database/sql"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Format 结果的编码格式
+type Format int
+
+const (
+	FormatJSON Format = iota
+	FormatColumnar
+	FormatArrow
+)
+
+// 各格式的Content-Type
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeColumnar = "application/vnd.logwatch.columnar+json"
+	ContentTypeArrow    = "application/vnd.apache.arrow.stream"
+)
+
+// MetaTrailer Arrow格式下附加字段的响应尾部，值为JSON对象
+const MetaTrailer = "X-Result-Meta"
+
+// Negotiate 按Accept请求头选择格式，q值相同时按出现的顺序，没有匹配时使用JSON
+func Negotiate(r *http.Request) Format {
+	best, bestQ := FormatJSON, 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		var format Format
+		switch mediaType {
+		case ContentTypeColumnar:
+			format = FormatColumnar
+		case ContentTypeArrow:
+			format = FormatArrow
+		case ContentTypeJSON:
+			format = FormatJSON
+		default:
+			continue
+		}
+		if q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+	return best
+}
+
+// Column 结果集的列，Type为ClickHouse类型，未知时为空
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Encoder 逐行写出结果集
+// Begin之前没有写入任何内容，出错时调用方仍可返回普通的错误响应；
+// Begin之后响应头已发送，出错时只能在Finish的附加字段中说明
+type Encoder interface {
+	// Begin 发送响应头并写入列信息
+	Begin(columns []Column) error
+	// WriteRow 写入一行，values与列一一对应
+	WriteRow(values []interface{}) error
+	// Finish 写入附加字段(如pagination、meta)并结束响应
+	Finish(meta map[string]interface{}) error
+}
+
+// NewEncoder 创建指定格式的编码器
+func NewEncoder(w http.ResponseWriter, format Format) Encoder {
+	switch format {
+	case FormatColumnar:
+		return newJSONEncoder(w, ContentTypeColumnar, true)
+	case FormatArrow:
+		return newArrowEncoder(w)
+	}
+	return newJSONEncoder(w, ContentTypeJSON, false)
+}
+
+// Rows 查询结果集，*sql.Rows和*database.Rows都满足
+type Rows interface {
+	ColumnTypes() ([]*sql.ColumnType, error)
+	Next() bool
+	Scan(dest ...interface{}) error
+	Err() error
+}
+
+// Columns 返回结果集的列名和ClickHouse类型
+func Columns(rows Rows) ([]Column, error) {
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	columns := make([]Column, len(types))
+	for i, t := range types {
+		columns[i] = Column{Name: t.Name(), Type: t.DatabaseTypeName()}
+	}
+	return columns, nil
+}
+
+// Copy 把结果集写入编码器，跳过前skip行，最多写入limit行(limit<0时不限)
+// 返回写入的行数和读取的总行数，读取会持续到结果集结束以便统计总行数
+func Copy(enc Encoder, rows Rows, columns int, skip, limit int) (written, read int, err error) {
+	values := make([]interface{}, columns)
+	pointers := make([]interface{}, columns)
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	for rows.Next() {
+		read++
+		if read <= skip || (limit >= 0 && written >= limit) {
+			continue
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return written, read, err
+		}
+		if err := enc.WriteRow(values); err != nil {
+			return written, read, err
+		}
+		written++
+	}
+	return written, read, rows.Err()
+}
+
+// WriteMaps 编码已经在内存中的行(如模拟数据)，列按第一行的键排序
+func WriteMaps(enc Encoder, rows []map[string]interface{}, meta map[string]interface{}) error {
+	columns := MapColumns(rows)
+	if err := enc.Begin(columns); err != nil {
+		return err
+	}
+	values := make([]interface{}, len(columns))
+	for _, row := range rows {
+		for i, c := range columns {
+			values[i] = row[c.Name]
+		}
+		if err := enc.WriteRow(values); err != nil {
+			return err
+		}
+	}
+	return enc.Finish(meta)
+}
+
+// Stream 写入列信息和结果集并结束响应，返回写入的行数
+// trailer在读取结束后调用，err为读取中的错误，返回的附加字段写在最后
+func Stream(enc Encoder, rows Rows, columns []Column, skip, limit int, trailer func(written, read int, err error) map[string]interface{}) (int, error) {
+	if err := enc.Begin(columns); err != nil {
+		return 0, err
+	}
+
+	written, read, err := Copy(enc, rows, len(columns), skip, limit)
+	var fields map[string]interface{}
+	if trailer != nil {
+		fields = trailer(written, read, err)
+	}
+
+	if finishErr := enc.Finish(fields); err == nil {
+		err = finishErr
+	}
+	return written, err
+}
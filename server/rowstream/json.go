@@ -0,0 +1,113 @@
+package rowstream
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+// jsonEncoder 输出行对象或列式数组的JSON，值按列类型转换
+// 列信息写在data之前，附加字段写在data之后，JSON对象的键顺序不影响解析
+type jsonEncoder struct {
+	w           http.ResponseWriter
+	buf         *bufio.Writer
+	contentType string
+	// columnar 为true时每行输出为数组，列信息在columns中只写一次
+	columnar   bool
+	columns    []Column
+	converters []Converter
+	rows       int
+}
+
+func newJSONEncoder(w http.ResponseWriter, contentType string, columnar bool) *jsonEncoder {
+	return &jsonEncoder{w: w, contentType: contentType, columnar: columnar}
+}
+
+func (e *jsonEncoder) Begin(columns []Column) error {
+	e.columns = columns
+	e.converters = Converters(columns)
+	data, err := json.Marshal(columns)
+	if err != nil {
+		return err
+	}
+
+	e.w.Header().Set("Content-Type", e.contentType)
+	e.w.WriteHeader(http.StatusOK)
+	e.buf = bufio.NewWriterSize(e.w, 64*1024)
+
+	if err := e.writeString(`{"columns":`); err != nil {
+		return err
+	}
+	if _, err := e.buf.Write(data); err != nil {
+		return err
+	}
+	return e.writeString(`,"data":[`)
+}
+
+func (e *jsonEncoder) WriteRow(values []interface{}) error {
+	var row interface{}
+	if e.columnar {
+		converted := make([]interface{}, len(values))
+		for i, v := range values {
+			converted[i] = e.converters[i](v)
+		}
+		row = converted
+	} else {
+		object := make(map[string]interface{}, len(values))
+		for i, v := range values {
+			object[e.columns[i].Name] = e.converters[i](v)
+		}
+		row = object
+	}
+
+	data, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	if e.rows > 0 {
+		if err := e.buf.WriteByte(','); err != nil {
+			return err
+		}
+	}
+	e.rows++
+	_, err = e.buf.Write(data)
+	return err
+}
+
+func (e *jsonEncoder) Finish(meta map[string]interface{}) error {
+	if err := e.writeString("]"); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(meta))
+	for k := range meta {
+		if k != "columns" && k != "data" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		name, _ := json.Marshal(k)
+		value, err := json.Marshal(normalize(meta[k]))
+		if err != nil {
+			return err
+		}
+		if err := e.writeString(","); err != nil {
+			return err
+		}
+		e.buf.Write(name)
+		e.buf.WriteByte(':')
+		if _, err := e.buf.Write(value); err != nil {
+			return err
+		}
+	}
+	if err := e.writeString("}"); err != nil {
+		return err
+	}
+	return e.buf.Flush()
+}
+
+func (e *jsonEncoder) writeString(s string) error {
+	_, err := e.buf.WriteString(s)
+	return err
+}
@@ -0,0 +1,277 @@
+package sqlparse
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// TokenKind 词法单元类型
+type TokenKind int
+
+const (
+	TokenEOF TokenKind = iota
+	TokenIdent
+	TokenQuotedIdent // `name` 或 "name"
+	TokenNumber
+	TokenString
+	TokenOperator
+	TokenLParen
+	TokenRParen
+	TokenComma
+	TokenDot
+	TokenSemicolon
+)
+
+// Token 词法单元，Pos和End为在SQL中的字节偏移
+type Token struct {
+	Kind TokenKind
+	Text string // 引号标识符和字符串为去除引号和转义后的内容
+	Pos  int
+	End  int
+}
+
+// Is 判断是否为指定关键字（不区分大小写），引号标识符不视为关键字
+func (t Token) Is(keyword string) bool {
+	return t.Kind == TokenIdent && strings.EqualFold(t.Text, keyword)
+}
+
+// Error SQL的解析或策略错误，Pos为出错的字符位置（从0开始）
+type Error struct {
+	Pos     int    `json:"position"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("位置%d: %s", e.Pos, e.Message)
+}
+
+// newError 按字节偏移创建错误，位置转换为字符位置
+func newError(src string, pos int, format string, args ...interface{}) *Error {
+	if pos > len(src) {
+		pos = len(src)
+	}
+	return &Error{Pos: utf8.RuneCountInString(src[:pos]), Message: fmt.Sprintf(format, args...)}
+}
+
+// 多字符运算符，按长度从长到短匹配
+var multiCharOperators = []string{"<=>", "==", "!=", "<>", "<=", ">=", "||", "->", "::"}
+
+// Tokenize 按ClickHouse的词法规则切分SQL，无法识别的字符返回错误
+// 不认识的语法一律拒绝，避免与服务端的解析结果不一致
+func Tokenize(src string) ([]Token, error) {
+	var tokens []Token
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+			i++
+
+		case strings.HasPrefix(src[i:], "--"):
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				i = len(src)
+			} else {
+				i += end + 1
+			}
+
+		case strings.HasPrefix(src[i:], "/*"):
+			end, err := skipBlockComment(src, i)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+
+		case c == '\'':
+			text, end, err := readQuoted(src, i, '\'')
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, Token{Kind: TokenString, Text: text, Pos: i, End: end})
+			i = end
+
+		case c == '`' || c == '"':
+			text, end, err := readQuoted(src, i, c)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, Token{Kind: TokenQuotedIdent, Text: text, Pos: i, End: end})
+			i = end
+
+		case isDigit(c) || (c == '.' && i+1 < len(src) && isDigit(src[i+1]) && !afterOperand(tokens)):
+			end := readNumber(src, i)
+			tokens = append(tokens, Token{Kind: TokenNumber, Text: src[i:end], Pos: i, End: end})
+			i = end
+
+		case isIdentStart(c):
+			end := i + 1
+			for end < len(src) && isIdentPart(src[end]) {
+				end++
+			}
+			tokens = append(tokens, Token{Kind: TokenIdent, Text: src[i:end], Pos: i, End: end})
+			i = end
+
+		case c == '(':
+			tokens = append(tokens, Token{Kind: TokenLParen, Text: "(", Pos: i, End: i + 1})
+			i++
+		case c == ')':
+			tokens = append(tokens, Token{Kind: TokenRParen, Text: ")", Pos: i, End: i + 1})
+			i++
+		case c == ',':
+			tokens = append(tokens, Token{Kind: TokenComma, Text: ",", Pos: i, End: i + 1})
+			i++
+		case c == '.':
+			tokens = append(tokens, Token{Kind: TokenDot, Text: ".", Pos: i, End: i + 1})
+			i++
+		case c == ';':
+			tokens = append(tokens, Token{Kind: TokenSemicolon, Text: ";", Pos: i, End: i + 1})
+			i++
+
+		default:
+			op := ""
+			for _, candidate := range multiCharOperators {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" && strings.IndexByte("+-*/%=<>?:[]{}", c) >= 0 {
+				op = string(c)
+			}
+			if op == "" {
+				r, _ := utf8.DecodeRuneInString(src[i:])
+				return nil, newError(src, i, "无法识别的字符 %q", r)
+			}
+			tokens = append(tokens, Token{Kind: TokenOperator, Text: op, Pos: i, End: i + len(op)})
+			i += len(op)
+		}
+	}
+	tokens = append(tokens, Token{Kind: TokenEOF, Pos: len(src), End: len(src)})
+	return tokens, nil
+}
+
+// skipBlockComment 跳过块注释，ClickHouse的块注释可以嵌套
+func skipBlockComment(src string, start int) (int, error) {
+	depth := 0
+	i := start
+	for i < len(src) {
+		switch {
+		case strings.HasPrefix(src[i:], "/*"):
+			depth++
+			i += 2
+		case strings.HasPrefix(src[i:], "*/"):
+			depth--
+			i += 2
+			if depth == 0 {
+				return i, nil
+			}
+		default:
+			i++
+		}
+	}
+	return 0, newError(src, start, "注释未闭合")
+}
+
+// readQuoted 读取引号包围的内容，支持反斜杠转义和重复引号转义
+func readQuoted(src string, start int, quote byte) (string, int, error) {
+	var sb strings.Builder
+	i := start + 1
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == '\\' && i+1 < len(src):
+			sb.WriteByte(unescape(src[i+1]))
+			i += 2
+		case c == quote && i+1 < len(src) && src[i+1] == quote:
+			sb.WriteByte(quote)
+			i += 2
+		case c == quote:
+			return sb.String(), i + 1, nil
+		default:
+			sb.WriteByte(c)
+			i++
+		}
+	}
+	if quote == '\'' {
+		return "", 0, newError(src, start, "字符串缺少结束引号")
+	}
+	return "", 0, newError(src, start, "标识符缺少结束引号")
+}
+
+func unescape(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	case 'r':
+		return '\r'
+	case '0':
+		return 0
+	}
+	return c
+}
+
+// readNumber 读取数字字面量，包括十六进制、二进制、小数和科学计数法
+func readNumber(src string, start int) int {
+	i := start
+	if strings.HasPrefix(src[i:], "0x") || strings.HasPrefix(src[i:], "0X") ||
+		strings.HasPrefix(src[i:], "0b") || strings.HasPrefix(src[i:], "0B") {
+		i += 2
+		for i < len(src) && isHexDigit(src[i]) {
+			i++
+		}
+		return i
+	}
+	for i < len(src) && isDigit(src[i]) {
+		i++
+	}
+	if i < len(src) && src[i] == '.' {
+		i++
+		for i < len(src) && isDigit(src[i]) {
+			i++
+		}
+	}
+	if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+		j := i + 1
+		if j < len(src) && (src[j] == '+' || src[j] == '-') {
+			j++
+		}
+		if j < len(src) && isDigit(src[j]) {
+			i = j
+			for i < len(src) && isDigit(src[i]) {
+				i++
+			}
+		}
+	}
+	return i
+}
+
+// afterOperand 前一个词法单元是否为操作数，用于区分 .5 和 t.1 这样的元组访问
+func afterOperand(tokens []Token) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	switch tokens[len(tokens)-1].Kind {
+	case TokenIdent, TokenQuotedIdent, TokenNumber, TokenString, TokenRParen:
+		return true
+	}
+	return false
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || isDigit(c) || c == '$'
+}
@@ -0,0 +1,923 @@
+package sqlparse
+
+import (
+	"strconv"
+	"strings"
+)
+
+// StatementKind 语句类型
+type StatementKind int
+
+const (
+	StatementSelect StatementKind = iota + 1
+	StatementShow
+	StatementDescribe
+)
+
+// Statement 解析后的单条只读语句
+type Statement struct {
+	Kind StatementKind
+	// Tables 语句中引用的全部表、表函数和子查询，包括嵌套子查询和IN右侧的表
+	Tables []*TableExpr
+	// Functions 语句中调用的全部函数
+	Functions []FunctionCall
+	// Params 语句中的查询参数，同名参数可出现多次
+	Params []Param
+
+	query      *selectUnion
+	src        string
+	start, end int
+}
+
+// SQL 返回语句文本，不包括结尾的分号和注释
+func (s *Statement) SQL() string {
+	return s.src[s.start:s.end]
+}
+
+// TableExpr 表引用，Name、Function和Subquery三者之一非空
+type TableExpr struct {
+	Database string
+	Name     string
+	Function string
+	Subquery bool
+	// CTE 引用的是WITH定义的子查询而不是真实的表
+	CTE   bool
+	Alias string
+	Pos   int
+}
+
+// QuotedName 返回以反引号引用的 [db.]table，可直接拼接到SQL中
+func (t *TableExpr) QuotedName() string {
+	name := quoteIdentifier(t.Name)
+	if t.Database != "" {
+		name = quoteIdentifier(t.Database) + "." + name
+	}
+	return name
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.NewReplacer("\\", "\\\\", "`", "\\`").Replace(name) + "`"
+}
+
+// FunctionCall 函数调用
+type FunctionCall struct {
+	Name string
+	Pos  int
+}
+
+// Param 查询参数 {name:Type} 或 {name}，值在执行时由ClickHouse按类型绑定
+type Param struct {
+	Name string
+	// Type 语句中声明的类型，{name}形式为空
+	Type string
+	Pos  int
+	end  int
+}
+
+// selectUnion 由UNION、EXCEPT或INTERSECT连接的查询
+type selectUnion struct {
+	selects []*selectQuery
+}
+
+// selectQuery 单个SELECT，Start和End为字节偏移
+type selectQuery struct {
+	start, end int
+	// inner 括号包围的查询
+	inner  *selectUnion
+	top    bool
+	fetch  bool
+	limit  *limitClause
+	offset *offsetClause
+}
+
+// limitClause LIMIT n 或 LIMIT m, n
+type limitClause struct {
+	start, end int
+	// literal 行数和偏移量都是整数字面量，可以直接改写
+	literal bool
+	count   uint64
+	offset  uint64
+}
+
+// offsetClause OFFSET m [ROWS]，start为前一个词法单元的结尾
+type offsetClause struct {
+	start, end int
+	literal    bool
+	value      uint64
+}
+
+// maxNestingDepth 子查询和括号的最大嵌套层数
+const maxNestingDepth = 64
+
+// joinModifiers JOIN前可出现的修饰词
+var joinModifiers = map[string]bool{
+	"GLOBAL": true, "LOCAL": true, "ANY": true, "ALL": true, "ASOF": true, "SEMI": true, "ANTI": true,
+	"INNER": true, "LEFT": true, "RIGHT": true, "FULL": true, "CROSS": true, "OUTER": true,
+	"PASTE": true, "ARRAY": true,
+}
+
+// clauseKeywords 结束当前表达式的子句关键字
+var clauseKeywords = map[string]bool{
+	"FROM": true, "PREWHERE": true, "WHERE": true, "HAVING": true, "QUALIFY": true, "WINDOW": true,
+	"LIMIT": true, "OFFSET": true, "FETCH": true, "SETTINGS": true, "INTO": true,
+}
+
+// showCreateKinds SHOW CREATE后除TABLE、VIEW、DICTIONARY和DATABASE以外的对象类型
+// 不带类型的SHOW CREATE name是表，但这些关键字不能作为未加引号的表名
+var showCreateKinds = map[string]bool{
+	"USER": true, "USERS": true, "ROLE": true, "ROLES": true, "QUOTA": true, "QUOTAS": true,
+	"ROW": true, "POLICY": true, "POLICIES": true, "SETTINGS": true, "PROFILE": true, "PROFILES": true,
+	"MASKING": true, "NAMED": true, "COLLECTION": true, "FUNCTION": true, "TEMPORARY": true,
+	"ACCESS": true, "GRANTS": true, "DATABASES": true, "TABLES": true,
+}
+
+type parser struct {
+	src    string
+	tokens []Token
+	pos    int
+	depth  int
+	// scopes 每层SELECT中WITH定义的子查询名称
+	scopes [][]string
+	stmt   *Statement
+}
+
+// Parse 解析一条ClickHouse只读语句（SELECT、SHOW、DESCRIBE），多条语句或其他语句返回*Error
+func Parse(src string) (*Statement, error) {
+	tokens, err := Tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{src: src, tokens: tokens}
+	if p.peek().Kind == TokenEOF || p.peek().Kind == TokenSemicolon {
+		return nil, newError(src, p.peek().Pos, "SQL语句为空")
+	}
+
+	p.stmt = &Statement{src: src, start: p.peek().Pos}
+	if err := p.parseStatement(); err != nil {
+		return nil, err
+	}
+	p.stmt.end = p.tokens[p.pos-1].End
+
+	if p.peek().Kind == TokenSemicolon {
+		p.next()
+		if tok := p.peek(); tok.Kind != TokenEOF {
+			return nil, p.errorf(tok, "不允许一次执行多条语句")
+		}
+	}
+	if tok := p.peek(); tok.Kind != TokenEOF {
+		return nil, p.errorf(tok, "意外的%s", describeToken(tok))
+	}
+	return p.stmt, nil
+}
+
+// ParseTableName 解析 [db.]table 形式的表名，用于接口参数中直接指定的表
+// 表函数、子查询和表名之后的任何内容都返回*Error
+func ParseTableName(src string) (*TableExpr, error) {
+	tokens, err := Tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{src: src, tokens: tokens, stmt: &Statement{src: src}}
+	if !isName(p.peek()) {
+		return nil, p.errorf(p.peek(), "缺少表名，遇到%s", describeToken(p.peek()))
+	}
+	table, err := p.parseTableName()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.Kind != TokenEOF {
+		return nil, p.errorf(tok, "表名之后不能有%s", describeToken(tok))
+	}
+	table.CTE = false
+	return table, nil
+}
+
+func (p *parser) peek() Token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) peekAt(n int) Token {
+	if p.pos+n >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+n]
+}
+
+func (p *parser) next() Token {
+	tok := p.tokens[p.pos]
+	if tok.Kind != TokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) errorf(tok Token, format string, args ...interface{}) error {
+	return newError(p.src, tok.Pos, format, args...)
+}
+
+func (p *parser) expect(kind TokenKind, text string) (Token, error) {
+	tok := p.peek()
+	if tok.Kind != kind {
+		return tok, p.errorf(tok, "缺少%s，遇到%s", text, describeToken(tok))
+	}
+	return p.next(), nil
+}
+
+// enter 进入一层嵌套，超过maxNestingDepth时返回错误
+func (p *parser) enter() error {
+	p.depth++
+	if p.depth > maxNestingDepth {
+		return p.errorf(p.peek(), "嵌套层数超过%d", maxNestingDepth)
+	}
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+func (p *parser) parseStatement() error {
+	tok := p.peek()
+	switch {
+	case tok.Kind == TokenLParen || tok.Is("SELECT") || tok.Is("WITH"):
+		p.stmt.Kind = StatementSelect
+		query, err := p.parseUnion()
+		if err != nil {
+			return err
+		}
+		p.stmt.query = query
+		return nil
+	case tok.Is("SHOW"):
+		p.stmt.Kind = StatementShow
+		return p.parseShow()
+	case tok.Is("DESCRIBE") || tok.Is("DESC"):
+		p.stmt.Kind = StatementDescribe
+		p.next()
+		if p.peek().Is("TABLE") {
+			p.next()
+		}
+		return p.parseTableExpr()
+	case tok.Kind == TokenIdent:
+		return p.errorf(tok, "只允许执行SELECT、SHOW和DESCRIBE语句，不支持%s", strings.ToUpper(tok.Text))
+	}
+	return p.errorf(tok, "无法识别的语句")
+}
+
+// parseUnion 解析由集合运算连接的查询
+func (p *parser) parseUnion() (*selectUnion, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	union := &selectUnion{}
+	for {
+		sel, err := p.parseSelectElement()
+		if err != nil {
+			return nil, err
+		}
+		union.selects = append(union.selects, sel)
+
+		if !p.isSetOperator(p.pos) {
+			return union, nil
+		}
+		p.next()
+		if p.peek().Is("ALL") || p.peek().Is("DISTINCT") {
+			p.next()
+		}
+	}
+}
+
+func (p *parser) parseSelectElement() (*selectQuery, error) {
+	if p.peek().Kind != TokenLParen {
+		return p.parseSelect()
+	}
+	start := p.next()
+	inner, err := p.parseUnion()
+	if err != nil {
+		return nil, err
+	}
+	end, err := p.expect(TokenRParen, ")")
+	if err != nil {
+		return nil, err
+	}
+	return &selectQuery{start: start.Pos, end: end.End, inner: inner}, nil
+}
+
+// parseSelect 解析 [WITH ...] SELECT ... 及其后的子句
+func (p *parser) parseSelect() (*selectQuery, error) {
+	sel := &selectQuery{start: p.peek().Pos}
+	p.scopes = append(p.scopes, nil)
+	defer func() { p.scopes = p.scopes[:len(p.scopes)-1] }()
+
+	if p.peek().Is("WITH") {
+		p.next()
+		if err := p.parseWith(); err != nil {
+			return nil, err
+		}
+	}
+	if tok := p.peek(); !tok.Is("SELECT") {
+		return nil, p.errorf(tok, "缺少SELECT，遇到%s", describeToken(tok))
+	}
+	p.next()
+
+	if p.peek().Is("DISTINCT") {
+		p.next()
+		if p.peek().Is("ON") {
+			p.next()
+			if err := p.parseParen(); err != nil {
+				return nil, err
+			}
+		}
+	} else if p.peek().Is("ALL") {
+		p.next()
+	}
+	if p.peek().Is("TOP") {
+		sel.top = true
+		p.next()
+		if p.peek().Kind == TokenLParen {
+			if err := p.parseParen(); err != nil {
+				return nil, err
+			}
+		} else {
+			p.next()
+		}
+		if p.peek().Is("WITH") && p.peekAt(1).Is("TIES") {
+			p.next()
+			p.next()
+		}
+	}
+
+	// 选择列表
+	if err := p.scanExpr(p.isClauseStart); err != nil {
+		return nil, err
+	}
+
+	for {
+		tok := p.peek()
+		var err error
+		switch {
+		case tok.Is("FROM"):
+			err = p.parseFrom()
+		case tok.Is("PREWHERE"), tok.Is("WHERE"), tok.Is("HAVING"), tok.Is("QUALIFY"), tok.Is("WINDOW"):
+			p.next()
+			err = p.scanExpr(p.isClauseStart)
+		case (tok.Is("GROUP") || tok.Is("ORDER")) && p.peekAt(1).Is("BY"):
+			p.next()
+			p.next()
+			err = p.scanExpr(p.isClauseStart)
+		case tok.Is("LIMIT"):
+			err = p.parseLimit(sel)
+		case tok.Is("OFFSET"):
+			err = p.parseOffset(sel)
+		case tok.Is("FETCH"):
+			sel.fetch = true
+			p.next()
+			err = p.scanExpr(p.isClauseStart)
+		case tok.Is("SETTINGS"):
+			return nil, p.errorf(tok, "不允许在查询中使用SETTINGS")
+		case tok.Is("FORMAT") && p.peekAt(1).Kind != TokenLParen:
+			return nil, p.errorf(tok, "不允许指定FORMAT，结果格式由服务端决定")
+		case tok.Is("INTO"):
+			return nil, p.errorf(tok, "不允许使用INTO OUTFILE")
+		default:
+			sel.end = p.tokens[p.pos-1].End
+			return sel, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseWith 解析WITH列表，name AS (subquery) 形式定义可在FROM中引用的子查询
+func (p *parser) parseWith() error {
+	for {
+		tok := p.peek()
+		if isName(tok) && p.peekAt(1).Is("AS") && p.peekAt(2).Kind == TokenLParen && isQueryStart(p.peekAt(3)) {
+			p.pos += 3
+			if _, err := p.parseUnion(); err != nil {
+				return err
+			}
+			if _, err := p.expect(TokenRParen, ")"); err != nil {
+				return err
+			}
+			scope := len(p.scopes) - 1
+			p.scopes[scope] = append(p.scopes[scope], tok.Text)
+		} else if err := p.scanExpr(func(i int) bool {
+			return p.tokens[i].Kind == TokenComma || p.tokens[i].Is("SELECT")
+		}); err != nil {
+			return err
+		}
+
+		if p.peek().Kind != TokenComma {
+			return nil
+		}
+		p.next()
+	}
+}
+
+// parseFrom 解析FROM子句中的表和JOIN
+func (p *parser) parseFrom() error {
+	p.next()
+	if err := p.parseTableExpr(); err != nil {
+		return err
+	}
+	for {
+		switch {
+		case p.peek().Kind == TokenComma:
+			p.next()
+			if err := p.parseTableExpr(); err != nil {
+				return err
+			}
+		case p.isJoinStart(p.pos):
+			if err := p.parseJoin(); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+func (p *parser) parseJoin() error {
+	array := false
+	for !p.peek().Is("JOIN") {
+		if p.peek().Is("ARRAY") {
+			array = true
+		}
+		p.next()
+	}
+	p.next()
+
+	if array {
+		return p.scanExpr(func(i int) bool { return p.isClauseStart(i) || p.isJoinStart(i) })
+	}
+	if err := p.parseTableExpr(); err != nil {
+		return err
+	}
+	switch {
+	case p.peek().Is("ON"):
+		p.next()
+		return p.scanExpr(p.isJoinBoundary)
+	case p.peek().Is("USING"):
+		p.next()
+		if p.peek().Kind == TokenLParen {
+			return p.parseParen()
+		}
+		return p.scanExpr(p.isJoinBoundary)
+	}
+	return nil
+}
+
+// parseTableExpr 解析表名、表函数或子查询，以及其后的FINAL、SAMPLE和别名
+func (p *parser) parseTableExpr() error {
+	tok := p.peek()
+	var table *TableExpr
+	switch {
+	case tok.Kind == TokenLParen && isQueryStart(p.peekAt(1)):
+		p.next()
+		if _, err := p.parseUnion(); err != nil {
+			return err
+		}
+		if _, err := p.expect(TokenRParen, ")"); err != nil {
+			return err
+		}
+		table = &TableExpr{Subquery: true, Pos: tok.Pos}
+		p.stmt.Tables = append(p.stmt.Tables, table)
+	case isName(tok) && p.peekAt(1).Kind == TokenLParen:
+		p.next()
+		table = &TableExpr{Function: tok.Text, Pos: tok.Pos}
+		p.stmt.Tables = append(p.stmt.Tables, table)
+		if err := p.parseParen(); err != nil {
+			return err
+		}
+	case isName(tok):
+		var err error
+		if table, err = p.parseTableName(); err != nil {
+			return err
+		}
+	default:
+		return p.errorf(tok, "缺少表名或子查询，遇到%s", describeToken(tok))
+	}
+
+	for {
+		tok := p.peek()
+		switch {
+		case tok.Is("FINAL"):
+			p.next()
+		case tok.Is("SAMPLE"):
+			p.next()
+			if err := p.scanExpr(p.isJoinBoundary); err != nil {
+				return err
+			}
+			// SAMPLE k OFFSET m 中的OFFSET属于SAMPLE
+			if p.peek().Is("OFFSET") {
+				p.next()
+				if err := p.scanExpr(p.isJoinBoundary); err != nil {
+					return err
+				}
+			}
+		case tok.Is("AS") && isName(p.peekAt(1)):
+			p.next()
+			table.Alias = p.next().Text
+		case table.Alias == "" && p.isAlias(p.pos):
+			table.Alias = p.next().Text
+		default:
+			return nil
+		}
+	}
+}
+
+// parseTableName 解析 [db.]table 并记录到语句的表引用中
+func (p *parser) parseTableName() (*TableExpr, error) {
+	first := p.next()
+	table := &TableExpr{Name: first.Text, Pos: first.Pos}
+	if p.peek().Kind == TokenDot {
+		p.next()
+		tok := p.next()
+		if !isName(tok) {
+			return nil, p.errorf(tok, "缺少表名，遇到%s", describeToken(tok))
+		}
+		table.Database, table.Name = first.Text, tok.Text
+	} else {
+		table.CTE = p.isCTE(first.Text)
+	}
+	p.stmt.Tables = append(p.stmt.Tables, table)
+	return table, nil
+}
+
+// parseLimit 解析LIMIT子句，LIMIT n BY 只限制每组的行数，不作为分页处理
+func (p *parser) parseLimit(sel *selectQuery) error {
+	start := p.next()
+	if p.isLimitBy(p.pos) {
+		if err := p.scanExpr(p.isClauseStart); err != nil {
+			return err
+		}
+		if p.peek().Is("OFFSET") {
+			p.next()
+			return p.scanExpr(p.isClauseStart)
+		}
+		return nil
+	}
+
+	if sel.limit != nil {
+		return p.errorf(start, "重复的LIMIT子句")
+	}
+	from := p.pos
+	if err := p.scanExpr(p.isClauseStart); err != nil {
+		return err
+	}
+	if p.pos == from {
+		return p.errorf(p.peek(), "LIMIT缺少行数")
+	}
+
+	clause := &limitClause{start: start.Pos, end: p.tokens[p.pos-1].End}
+	toks := p.tokens[from:p.pos]
+	switch {
+	case len(toks) == 1:
+		clause.count, clause.literal = parseUint(toks[0])
+	case len(toks) == 3 && toks[1].Kind == TokenComma:
+		var ok bool
+		if clause.offset, ok = parseUint(toks[0]); ok {
+			clause.count, clause.literal = parseUint(toks[2])
+		}
+	}
+	sel.limit = clause
+	return nil
+}
+
+// parseOffset 解析 OFFSET m [ROW|ROWS]
+func (p *parser) parseOffset(sel *selectQuery) error {
+	start := p.next()
+	if sel.offset != nil {
+		return p.errorf(start, "重复的OFFSET子句")
+	}
+	from := p.pos
+	if err := p.scanExpr(p.isClauseStart); err != nil {
+		return err
+	}
+	if p.pos == from {
+		return p.errorf(p.peek(), "OFFSET缺少行数")
+	}
+
+	// 删除时连同前面的空白一起删除
+	clause := &offsetClause{start: p.tokens[from-2].End, end: p.tokens[p.pos-1].End}
+	toks := p.tokens[from:p.pos]
+	if len(toks) == 1 || (len(toks) == 2 && (toks[1].Is("ROW") || toks[1].Is("ROWS"))) {
+		clause.value, clause.literal = parseUint(toks[0])
+	}
+	sel.offset = clause
+	return nil
+}
+
+func (p *parser) parseShow() error {
+	p.next()
+	for p.peek().Is("FULL") || p.peek().Is("TEMPORARY") || p.peek().Is("EXTENDED") {
+		p.next()
+	}
+
+	tok := p.next()
+	switch {
+	case tok.Is("TABLES"), tok.Is("DATABASES"):
+	case tok.Is("CREATE"):
+		if p.peek().Is("DATABASE") {
+			p.next()
+			if !isName(p.next()) {
+				return p.errorf(tok, "缺少数据库名")
+			}
+			break
+		}
+		if p.peek().Is("TEMPORARY") && p.peekAt(1).Is("TABLE") {
+			p.next()
+		}
+		switch next := p.peek(); {
+		case next.Is("TABLE") || next.Is("VIEW") || next.Is("DICTIONARY"):
+			p.next()
+		case next.Kind == TokenIdent && showCreateKinds[strings.ToUpper(next.Text)]:
+			// SHOW CREATE USER/ROLE/QUOTA等返回账号和权限的定义，不能当作表名
+			return p.errorf(next, "只支持SHOW CREATE TABLE、VIEW、DICTIONARY和DATABASE")
+		}
+		if !isName(p.peek()) {
+			return p.errorf(p.peek(), "缺少表名，遇到%s", describeToken(p.peek()))
+		}
+		if _, err := p.parseTableName(); err != nil {
+			return err
+		}
+	case tok.Is("COLUMNS"), tok.Is("FIELDS"):
+		if !p.peek().Is("FROM") && !p.peek().Is("IN") {
+			return p.errorf(p.peek(), "缺少FROM")
+		}
+		p.next()
+		if !isName(p.peek()) {
+			return p.errorf(p.peek(), "缺少表名，遇到%s", describeToken(p.peek()))
+		}
+		table, err := p.parseTableName()
+		if err != nil {
+			return err
+		}
+		// SHOW COLUMNS FROM table FROM db
+		if (p.peek().Is("FROM") || p.peek().Is("IN")) && isName(p.peekAt(1)) {
+			p.next()
+			table.Database = p.next().Text
+			table.CTE = false
+		}
+	default:
+		return p.errorf(tok, "只支持SHOW TABLES、SHOW DATABASES、SHOW CREATE TABLE和SHOW COLUMNS")
+	}
+
+	// 其余只能是LIKE、FROM、LIMIT等由名称和字面量组成的简单子句
+	for tok := p.peek(); tok.Kind != TokenEOF && tok.Kind != TokenSemicolon; tok = p.peek() {
+		switch tok.Kind {
+		case TokenIdent, TokenQuotedIdent, TokenString, TokenNumber, TokenDot:
+			p.next()
+		default:
+			return p.errorf(tok, "SHOW语句中不支持%s", describeToken(tok))
+		}
+	}
+	return nil
+}
+
+// scanExpr 跳过一个表达式直到stop返回true，途中解析子查询并记录函数调用和IN右侧的表
+// 遇到未配对的右括号、分号或结尾时停止
+func (p *parser) scanExpr(stop func(int) bool) error {
+	for {
+		tok := p.peek()
+		if tok.Kind == TokenEOF || tok.Kind == TokenRParen || tok.Kind == TokenSemicolon {
+			return nil
+		}
+		if stop != nil && stop(p.pos) {
+			return nil
+		}
+
+		switch {
+		case tok.Kind == TokenLParen:
+			if err := p.parseParen(); err != nil {
+				return err
+			}
+		case isName(tok) && p.peekAt(1).Kind == TokenLParen:
+			p.stmt.Functions = append(p.stmt.Functions, FunctionCall{Name: tok.Text, Pos: tok.Pos})
+			p.next()
+		case isParamStart(p.tokens[p.pos:]):
+			if err := p.parseParam(); err != nil {
+				return err
+			}
+		case tok.Is("IN") && isName(p.peekAt(1)) && p.peekAt(2).Kind != TokenLParen:
+			// x IN table
+			p.next()
+			if _, err := p.parseTableName(); err != nil {
+				return err
+			}
+		default:
+			p.next()
+		}
+	}
+}
+
+// parseParam 解析查询参数 {name} 或 {name:Type}
+// Identifier类型的参数会在服务端替换为表名或列名，绕过表的访问策略，因此拒绝
+func (p *parser) parseParam() error {
+	start := p.next()
+	name := p.next()
+	param := Param{Name: name.Text, Pos: start.Pos}
+
+	if tok := p.peek(); tok.Kind == TokenOperator && tok.Text == ":" {
+		p.next()
+		from := p.peek()
+		for {
+			tok := p.peek()
+			if tok.Kind == TokenEOF || tok.Kind == TokenSemicolon {
+				return p.errorf(start, "查询参数缺少结束的}")
+			}
+			if tok.Kind == TokenOperator && tok.Text == "}" {
+				break
+			}
+			p.next()
+		}
+		if p.tokens[p.pos-1].End <= from.Pos {
+			return p.errorf(from, "查询参数%s缺少类型", name.Text)
+		}
+		param.Type = p.src[from.Pos:p.tokens[p.pos-1].End]
+		if strings.Contains(strings.ToLower(param.Type), "identifier") {
+			return p.errorf(from, "不支持Identifier类型的查询参数")
+		}
+	}
+
+	end := p.next()
+	param.end = end.End
+	p.stmt.Params = append(p.stmt.Params, param)
+	return nil
+}
+
+// parseParen 解析括号，括号内以SELECT或WITH开头时作为子查询解析
+func (p *parser) parseParen() error {
+	if err := p.enter(); err != nil {
+		return err
+	}
+	defer p.leave()
+
+	p.next()
+	if isQueryStart(p.peek()) {
+		if _, err := p.parseUnion(); err != nil {
+			return err
+		}
+	} else if err := p.scanExpr(nil); err != nil {
+		return err
+	}
+	_, err := p.expect(TokenRParen, ")")
+	return err
+}
+
+// isClauseStart 位置i是否为子句的开始或表达式的结束
+func (p *parser) isClauseStart(i int) bool {
+	tok := p.tokens[i]
+	switch tok.Kind {
+	case TokenEOF, TokenSemicolon, TokenRParen:
+		return true
+	case TokenIdent:
+	default:
+		return false
+	}
+
+	next := p.tokens[min(i+1, len(p.tokens)-1)]
+	keyword := strings.ToUpper(tok.Text)
+	switch {
+	case clauseKeywords[keyword]:
+		return true
+	case keyword == "GROUP" || keyword == "ORDER":
+		return next.Is("BY")
+	case keyword == "FORMAT":
+		// format(...) 是函数
+		return next.Kind != TokenLParen
+	}
+	return p.isSetOperator(i)
+}
+
+// isSetOperator 位置i是否为UNION、EXCEPT或INTERSECT
+// SELECT * EXCEPT (col) 中的EXCEPT是列转换器，只有后面跟查询时才视为集合运算
+func (p *parser) isSetOperator(i int) bool {
+	tok := p.tokens[i]
+	if !tok.Is("UNION") && !tok.Is("EXCEPT") && !tok.Is("INTERSECT") {
+		return false
+	}
+	next := p.tokens[min(i+1, len(p.tokens)-1)]
+	if next.Is("ALL") || next.Is("DISTINCT") || next.Is("SELECT") || next.Is("WITH") {
+		return true
+	}
+	return next.Kind == TokenLParen && isQueryStart(p.tokens[min(i+2, len(p.tokens)-1)])
+}
+
+// isJoinStart 位置i是否为 [修饰词...] JOIN
+func (p *parser) isJoinStart(i int) bool {
+	for ; i < len(p.tokens); i++ {
+		tok := p.tokens[i]
+		if tok.Is("JOIN") {
+			return true
+		}
+		if tok.Kind != TokenIdent || !joinModifiers[strings.ToUpper(tok.Text)] {
+			return false
+		}
+	}
+	return false
+}
+
+// isJoinBoundary 位置i是否结束ON条件等JOIN内的表达式
+func (p *parser) isJoinBoundary(i int) bool {
+	return p.tokens[i].Kind == TokenComma || p.isClauseStart(i) || p.isJoinStart(i)
+}
+
+// isAlias 位置i的名称能否作为省略AS的别名
+func (p *parser) isAlias(i int) bool {
+	tok := p.tokens[i]
+	if tok.Kind == TokenQuotedIdent {
+		return true
+	}
+	if tok.Kind != TokenIdent || p.isJoinBoundary(i) {
+		return false
+	}
+	switch strings.ToUpper(tok.Text) {
+	case "ON", "USING", "FINAL", "SAMPLE", "AS":
+		return false
+	}
+	return true
+}
+
+// isLimitBy 从位置i开始到下一个子句之前是否有顶层的BY，用于识别 LIMIT n [OFFSET m] BY
+func (p *parser) isLimitBy(i int) bool {
+	depth := 0
+	offsetSeen := false
+	for ; i < len(p.tokens); i++ {
+		tok := p.tokens[i]
+		switch {
+		case tok.Kind == TokenEOF:
+			return false
+		case tok.Kind == TokenLParen:
+			depth++
+		case tok.Kind == TokenRParen:
+			if depth == 0 {
+				return false
+			}
+			depth--
+		case depth > 0:
+		case tok.Is("BY"):
+			return true
+		case tok.Is("OFFSET") && !offsetSeen:
+			offsetSeen = true
+		case p.isClauseStart(i):
+			return false
+		}
+	}
+	return false
+}
+
+// isCTE 名称是否为当前作用域中WITH定义的子查询
+func (p *parser) isCTE(name string) bool {
+	for _, scope := range p.scopes {
+		for _, cte := range scope {
+			if cte == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isName(tok Token) bool {
+	return tok.Kind == TokenIdent || tok.Kind == TokenQuotedIdent
+}
+
+// isParamStart 是否为查询参数的开始，即 { 后跟名称和 : 或 }
+// Map字面量的键是字面量，不会与参数混淆
+func isParamStart(tokens []Token) bool {
+	if len(tokens) < 3 || tokens[0].Kind != TokenOperator || tokens[0].Text != "{" || tokens[1].Kind != TokenIdent {
+		return false
+	}
+	return tokens[2].Kind == TokenOperator && (tokens[2].Text == ":" || tokens[2].Text == "}")
+}
+
+func isQueryStart(tok Token) bool {
+	return tok.Is("SELECT") || tok.Is("WITH")
+}
+
+// parseUint 解析非负整数字面量
+func parseUint(tok Token) (uint64, bool) {
+	if tok.Kind != TokenNumber {
+		return 0, false
+	}
+	base := 10
+	if len(tok.Text) > 2 && tok.Text[0] == '0' && strings.ContainsRune("xXbB", rune(tok.Text[1])) {
+		base = 0
+	}
+	n, err := strconv.ParseUint(tok.Text, base, 64)
+	return n, err == nil
+}
+
+func describeToken(tok Token) string {
+	switch tok.Kind {
+	case TokenEOF:
+		return "语句结尾"
+	case TokenString:
+		return "字符串"
+	}
+	return "\"" + tok.Text + "\""
+}
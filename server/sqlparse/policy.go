@@ -0,0 +1,106 @@
+package sqlparse
+
+import "strings"
+
+// DefaultSystemTables 默认允许查询的系统表，只包含表结构等元数据，不包含查询日志、用户和权限
+var DefaultSystemTables = []string{
+	"system.tables",
+	"system.columns",
+	"system.databases",
+	"system.functions",
+	"system.data_type_families",
+	"information_schema.tables",
+	"information_schema.columns",
+	"information_schema.schemata",
+}
+
+// DefaultTableFunctions 默认允许的表函数，只生成数据，不访问外部资源或其他表
+var DefaultTableFunctions = []string{"numbers", "numbers_mt", "zeros", "zeros_mt", "values", "null"}
+
+// externalFunctions 读取文件、网络或其他服务器的函数，作为表函数和普通函数都不允许调用
+// file() 同时也是读取服务器文件的普通函数
+var externalFunctions = map[string]bool{
+	"file": true, "filecluster": true, "url": true, "urlcluster": true,
+	"remote": true, "remotesecure": true, "cluster": true, "clusterallreplicas": true,
+	"s3": true, "s3cluster": true, "gcs": true, "oss": true, "cosn": true,
+	"azureblobstorage": true, "azureblobstoragecluster": true, "hdfs": true, "hdfscluster": true,
+	"mysql": true, "postgresql": true, "mongodb": true, "redis": true, "sqlite": true,
+	"jdbc": true, "odbc": true, "input": true, "merge": true, "executable": true,
+	"dictionary": true, "view": true, "viewifpermitted": true, "viewexplain": true, "loop": true,
+	"iceberg": true, "icebergs3": true, "deltalake": true, "hudi": true, "hive": true,
+	"mergetreeindex": true,
+}
+
+// systemDatabases 需要通过白名单访问的数据库
+var systemDatabases = map[string]bool{"system": true, "information_schema": true}
+
+// Policy SQL控制台的访问策略
+type Policy struct {
+	systemTables   map[string]bool
+	tableFunctions map[string]bool
+}
+
+// NewPolicy 创建访问策略，systemTables为允许的系统表(db.table)，tableFunctions为允许的表函数
+func NewPolicy(systemTables, tableFunctions []string) *Policy {
+	p := &Policy{
+		systemTables:   make(map[string]bool, len(systemTables)),
+		tableFunctions: make(map[string]bool, len(tableFunctions)),
+	}
+	for _, name := range systemTables {
+		p.systemTables[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	for _, name := range tableFunctions {
+		p.tableFunctions[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	return p
+}
+
+// DefaultPolicy 使用默认白名单的访问策略
+func DefaultPolicy() *Policy {
+	return NewPolicy(DefaultSystemTables, DefaultTableFunctions)
+}
+
+// Check 检查语句引用的表和函数，返回第一个违反策略的位置
+func (p *Policy) Check(stmt *Statement) error {
+	for _, table := range stmt.Tables {
+		if err := p.checkTable(stmt.src, table); err != nil {
+			return err
+		}
+	}
+
+	for _, fn := range stmt.Functions {
+		name := strings.ToLower(fn.Name)
+		if externalFunctions[name] && !p.tableFunctions[name] {
+			return newError(stmt.src, fn.Pos, "不允许调用函数%s", fn.Name)
+		}
+	}
+	return nil
+}
+
+// CheckTableName 解析 [db.]table 形式的表名并检查访问策略，用于/api/query等直接指定表名的接口
+func (p *Policy) CheckTableName(name string) (*TableExpr, error) {
+	table, err := ParseTableName(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkTable(name, table); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+func (p *Policy) checkTable(src string, table *TableExpr) error {
+	switch {
+	case table.Function != "":
+		if !p.tableFunctions[strings.ToLower(table.Function)] {
+			return newError(src, table.Pos, "不允许使用表函数%s", table.Function)
+		}
+	case table.Subquery || table.CTE:
+	case systemDatabases[strings.ToLower(table.Database)]:
+		name := strings.ToLower(table.Database + "." + table.Name)
+		if !p.systemTables[name] {
+			return newError(src, table.Pos, "不允许查询系统表%s.%s", table.Database, table.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package sqlparse
+
+import "testing"
+
+// check 解析并按默认策略检查语句
+func check(src string) error {
+	stmt, err := Parse(src)
+	if err != nil {
+		return err
+	}
+	return DefaultPolicy().Check(stmt)
+}
+
+func TestPolicyAllows(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+	}{
+		{"普通查询", "SELECT * FROM kv_7"},
+		{"带数据库的表", "SELECT d1 FROM test_db.kv_7 WHERE d1 = 'x' LIMIT 10"},
+		{"结尾分号", "SELECT 1;"},
+		{"WITH子查询", "WITH t AS (SELECT 1 AS x) SELECT x FROM t"},
+		{"白名单系统表", "SELECT name FROM system.tables"},
+		{"白名单系统表大小写", "SELECT name FROM SYSTEM.Columns"},
+		{"information_schema", "SELECT * FROM information_schema.columns"},
+		{"白名单表函数", "SELECT number FROM numbers(10)"},
+		{"白名单表函数大小写", "SELECT * FROM Zeros(3)"},
+		{"子查询", "SELECT * FROM (SELECT d1 FROM kv_7) WHERE d1 != ''"},
+		{"IN子查询", "SELECT * FROM kv_7 WHERE d1 IN (SELECT name FROM system.tables)"},
+		{"SHOW TABLES", "SHOW TABLES FROM test_db LIKE 'kv%'"},
+		{"SHOW DATABASES", "SHOW DATABASES"},
+		{"SHOW CREATE TABLE", "SHOW CREATE TABLE test_db.kv_7"},
+		{"SHOW CREATE TEMPORARY TABLE", "SHOW CREATE TEMPORARY TABLE tmp"},
+		{"SHOW CREATE不带类型", "SHOW CREATE kv_7"},
+		{"SHOW CREATE带引号的表名", "SHOW CREATE `user`"},
+		{"SHOW CREATE VIEW", "SHOW CREATE VIEW test_db.v"},
+		{"SHOW CREATE DICTIONARY", "SHOW CREATE DICTIONARY d"},
+		{"SHOW CREATE DATABASE", "SHOW CREATE DATABASE test_db"},
+		{"SHOW COLUMNS", "SHOW COLUMNS FROM kv_7 FROM test_db"},
+		{"DESCRIBE", "DESCRIBE TABLE kv_7"},
+		{"DESC白名单系统表", "DESC system.columns"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := check(tt.sql); err != nil {
+				t.Errorf("%q 应当允许执行，实际返回 %v", tt.sql, err)
+			}
+		})
+	}
+}
+
+func TestPolicyRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+	}{
+		{"空语句", "  ;"},
+		{"写入语句", "INSERT INTO kv_7 VALUES (1)"},
+		{"DDL", "DROP TABLE kv_7"},
+		{"修改设置", "SET max_memory_usage = 0"},
+		{"多条语句", "SELECT 1; SELECT 2"},
+		{"多条语句带DDL", "SELECT * FROM kv_7; DROP TABLE kv_7"},
+		{"注释后的第二条语句", "SELECT 1; -- x\nDROP TABLE kv_7"},
+
+		{"系统表users", "SELECT * FROM system.users"},
+		{"系统表query_log", "SELECT query FROM system.query_log"},
+		{"系统表带引号", "SELECT * FROM `system`.`users`"},
+		{"子查询中的系统表", "SELECT * FROM (SELECT * FROM system.users)"},
+		{"IN子查询中的系统表", "SELECT * FROM kv_7 WHERE d1 IN (SELECT name FROM system.users)"},
+		{"IN右侧的系统表", "SELECT * FROM kv_7 WHERE d1 IN system.users"},
+		{"JOIN系统表", "SELECT * FROM kv_7 AS a JOIN system.grants AS b ON a.d1 = b.user_name"},
+		{"UNION中的系统表", "SELECT name FROM system.tables UNION ALL SELECT name FROM system.users"},
+		{"WITH中的系统表", "WITH u AS (SELECT * FROM system.users) SELECT * FROM u"},
+
+		{"表函数url", "SELECT * FROM url('http://example.com/a.csv', CSV, 'a String')"},
+		{"表函数remote", "SELECT * FROM remote('10.0.0.1', system.one)"},
+		{"表函数s3", "SELECT * FROM s3('https://bucket/a.parquet')"},
+		{"表函数file", "SELECT * FROM file('/etc/passwd', 'LineAsString')"},
+		{"表函数mysql", "SELECT * FROM mysql('host:3306', 'db', 't', 'u', 'p')"},
+		{"表函数merge", "SELECT * FROM merge('system', '^users$')"},
+		{"未列入白名单的表函数", "SELECT * FROM generateRandom('a UInt8')"},
+		{"普通函数file", "SELECT file('/etc/passwd')"},
+		{"WHERE中的url", "SELECT 1 WHERE 1 IN (SELECT * FROM url('http://x', CSV, 'a UInt8'))"},
+
+		{"SHOW CREATE USER", "SHOW CREATE USER default"},
+		{"SHOW CREATE USER小写", "show create user default"},
+		{"SHOW CREATE ROLE", "SHOW CREATE ROLE admin"},
+		{"SHOW CREATE QUOTA", "SHOW CREATE QUOTA default"},
+		{"SHOW CREATE ROW POLICY", "SHOW CREATE ROW POLICY p ON test_db.kv_7"},
+		{"SHOW CREATE SETTINGS PROFILE", "SHOW CREATE SETTINGS PROFILE default"},
+		{"SHOW CREATE PROFILE", "SHOW CREATE PROFILE default"},
+		{"SHOW CREATE系统表", "SHOW CREATE TABLE system.users"},
+		{"SHOW CREATE缺少表名", "SHOW CREATE"},
+		{"SHOW GRANTS", "SHOW GRANTS"},
+		{"SHOW USERS", "SHOW USERS"},
+		{"SHOW PROCESSLIST", "SHOW PROCESSLIST"},
+		{"SHOW ACCESS", "SHOW ACCESS"},
+		{"DESCRIBE系统表", "DESCRIBE system.users"},
+		{"DESCRIBE表函数", "DESCRIBE url('http://x', CSV, 'a String')"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := check(tt.sql); err == nil {
+				t.Errorf("%q 应当被拒绝", tt.sql)
+			}
+		})
+	}
+}
+
+func TestPolicyCustomAllowlist(t *testing.T) {
+	policy := NewPolicy([]string{" System.Query_Log "}, []string{"URL"})
+	tests := []struct {
+		sql     string
+		allowed bool
+	}{
+		{"SELECT * FROM system.query_log", true},
+		{"SELECT * FROM system.tables", false},
+		{"SELECT * FROM url('http://x', CSV, 'a String')", true},
+		{"SELECT * FROM numbers(10)", false},
+		{"SELECT * FROM remote('h', system.one)", false},
+	}
+	for _, tt := range tests {
+		stmt, err := Parse(tt.sql)
+		if err != nil {
+			t.Fatalf("%q 解析失败: %v", tt.sql, err)
+		}
+		if err := policy.Check(stmt); (err == nil) != tt.allowed {
+			t.Errorf("%q allowed=%v，实际返回 %v", tt.sql, tt.allowed, err)
+		}
+	}
+}
+
+func TestErrorPosition(t *testing.T) {
+	tests := []struct {
+		sql string
+		pos int
+	}{
+		{"SELECT 1; SELECT 2", 10},
+		{"SELECT * FROM system.users", 14},
+		{"SELECT * FROM url('http://x')", 14},
+		{"SHOW CREATE USER default", 12},
+	}
+	for _, tt := range tests {
+		err := check(tt.sql)
+		sqlErr, ok := err.(*Error)
+		if !ok {
+			t.Errorf("%q 应返回*Error，实际返回 %v", tt.sql, err)
+			continue
+		}
+		if sqlErr.Pos != tt.pos {
+			t.Errorf("%q 错误位置为%d，期望%d", tt.sql, sqlErr.Pos, tt.pos)
+		}
+	}
+}
+
+func TestCheckTableName(t *testing.T) {
+	tests := []struct {
+		name   string
+		quoted string
+	}{
+		{"kv_7", "`kv_7`"},
+		{"test_db.kv_7", "`test_db`.`kv_7`"},
+		{"`my table`", "`my table`"},
+		{"system.tables", "`system`.`tables`"},
+		{"`a``b`", "`a\\`b`"},
+	}
+	for _, tt := range tests {
+		table, err := DefaultPolicy().CheckTableName(tt.name)
+		if err != nil {
+			t.Errorf("%q 应当允许，实际返回 %v", tt.name, err)
+			continue
+		}
+		if got := table.QuotedName(); got != tt.quoted {
+			t.Errorf("%q 引用后为%s，期望%s", tt.name, got, tt.quoted)
+		}
+	}
+
+	for _, name := range []string{
+		"",
+		"system.users",
+		"`system`.users",
+		"url('http://example.com/a.csv', CSV, 'a String')",
+		"remote('10.0.0.1', system.one)",
+		"numbers(10)",
+		"(SELECT * FROM system.users)",
+		"kv_7 LIMIT 1",
+		"kv_7; DROP TABLE kv_7",
+		"kv_7 UNION ALL SELECT * FROM system.users",
+	} {
+		if _, err := DefaultPolicy().CheckTableName(name); err == nil {
+			t.Errorf("%q 应当被拒绝", name)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package sqlparse
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Paginate 返回注入分页后的SQL，limit为每页行数，offset为跳过的行数
+// 查询自身的LIMIT和OFFSET作为结果范围保留，分页在这个范围内进行；
+// 能直接改写时替换最外层查询的LIMIT子句，否则（UNION、TOP、FETCH、非字面量的LIMIT）包装为子查询
+// SHOW和DESCRIBE语句不支持OFFSET，原样返回
+func (s *Statement) Paginate(limit, offset uint64) string {
+	if s.Kind != StatementSelect {
+		return s.SQL()
+	}
+
+	sel := s.query.rewritable()
+	if sel == nil {
+		// 换行避免原语句中的行注释吞掉外层的括号
+		return fmt.Sprintf("SELECT * FROM (\n%s\n) LIMIT %d OFFSET %d", s.SQL(), limit, offset)
+	}
+
+	var baseOffset uint64
+	count := limit
+	if sel.limit != nil {
+		baseOffset = sel.limit.offset
+		switch {
+		case sel.limit.count <= offset:
+			count = 0
+		case sel.limit.count-offset < limit:
+			count = sel.limit.count - offset
+		}
+	}
+	if sel.offset != nil {
+		baseOffset = sel.offset.value
+	}
+	clause := fmt.Sprintf("LIMIT %d OFFSET %d", count, addSaturating(baseOffset, offset))
+
+	var edits []edit
+	if sel.limit != nil {
+		edits = append(edits, edit{sel.limit.start, sel.limit.end, clause})
+	} else {
+		edits = append(edits, edit{sel.end, sel.end, " " + clause})
+	}
+	if sel.offset != nil {
+		edits = append(edits, edit{sel.offset.start, sel.offset.end, ""})
+	}
+	return s.apply(edits)
+}
+
+// CountSQL 返回统计结果总行数的SQL，即 SELECT count() FROM (<原查询>)
+// 原查询不含分页注入的LIMIT，查询自身的LIMIT属于结果范围，保留在子查询中
+func (s *Statement) CountSQL() string {
+	return fmt.Sprintf("SELECT count() FROM (\n%s\n)", s.SQL())
+}
+
+// Limit 返回最外层查询自身的LIMIT行数，没有LIMIT或无法确定时返回false
+func (s *Statement) Limit() (uint64, bool) {
+	if s.Kind != StatementSelect {
+		return 0, false
+	}
+	sel := s.query.rewritable()
+	if sel == nil || sel.limit == nil {
+		return 0, false
+	}
+	return sel.limit.count, true
+}
+
+// BindTypes 为语句中未声明类型的参数补上types中的类型，types中没有的参数使用String
+// 返回改写后的SQL，参数值在执行时由ClickHouse按类型解析，不会拼接到SQL中
+func (s *Statement) BindTypes(types map[string]string) string {
+	var edits []edit
+	for _, p := range s.Params {
+		if p.Type != "" {
+			continue
+		}
+		typ := types[p.Name]
+		if typ == "" {
+			typ = "String"
+		}
+		edits = append(edits, edit{p.Pos, p.end, fmt.Sprintf("{%s:%s}", p.Name, typ)})
+	}
+	return s.apply(edits)
+}
+
+// rewritable 返回可以直接改写LIMIT的最外层查询，不能改写时返回nil
+func (u *selectUnion) rewritable() *selectQuery {
+	if len(u.selects) != 1 {
+		return nil
+	}
+	sel := u.selects[0]
+	if sel.inner != nil || sel.top || sel.fetch {
+		return nil
+	}
+	if (sel.limit != nil && !sel.limit.literal) || (sel.offset != nil && !sel.offset.literal) {
+		return nil
+	}
+	return sel
+}
+
+// edit 把语句中[start, end)的内容替换为text
+type edit struct {
+	start, end int
+	text       string
+}
+
+func (s *Statement) apply(edits []edit) string {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+
+	out := make([]byte, 0, s.end-s.start+32)
+	pos := s.start
+	for _, e := range edits {
+		out = append(out, s.src[pos:e.start]...)
+		out = append(out, e.text...)
+		pos = e.end
+	}
+	out = append(out, s.src[pos:s.end]...)
+	return string(out)
+}
+
+func addSaturating(a, b uint64) uint64 {
+	if a > math.MaxUint64-b {
+		return math.MaxUint64
+	}
+	return a + b
+}
@@ -0,0 +1,265 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Response 所有JSON接口的统一响应结构
+// 成功时data为结果，失败时error为错误对象；meta在两种情况下都存在
+type Response struct {
+	// Columns 表格结果的列信息，与流式输出的columns相同
+	Columns    interface{} `json:"columns,omitempty"`
+	Data       interface{} `json:"data,omitempty"`
+	Pagination *Pagination `json:"pagination,omitempty"`
+	Meta       Meta        `json:"meta"`
+	Error      *Error      `json:"error,omitempty"`
+}
+
+// Pagination 分页信息
+type Pagination struct {
+	// Total 符合条件的总条数，未统计时省略
+	Total *int64 `json:"total,omitempty"`
+	// TotalExact 为false时total是下限，如SQL计数查询超时
+	TotalExact *bool `json:"total_exact,omitempty"`
+	// NextCursor 下一页的游标，作为cursor参数传入，没有下一页时省略
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Meta 响应的元信息，由RequestMeta中间件在请求过程中收集
+type Meta struct {
+	// QueryID 请求执行的第一条ClickHouse查询的query_id，可用于 DELETE /api/queries/{id}
+	QueryID string `json:"query_id,omitempty"`
+	// QueryIDs 请求执行了多条查询时的全部query_id
+	QueryIDs  []string `json:"query_ids,omitempty"`
+	ElapsedMs int64    `json:"elapsed_ms"`
+	RowsRead  int64    `json:"rows_read"`
+	Source    string   `json:"source"`
+	// TableRows 表中不带筛选条件的总行数，只在请求了count时返回
+	TableRows *int64 `json:"table_rows,omitempty"`
+	// Warning 结果可用但需要注意的情况，如预估读取行数超过预算
+	Warning string `json:"warning,omitempty"`
+}
+
+// Int64 返回n的指针，用于Pagination.Total等可省略的字段
+func Int64(n int) *int64 {
+	v := int64(n)
+	return &v
+}
+
+// NewPagination 由总条数、当前页的偏移和条数生成分页信息，后面还有数据时带有下一页的游标
+// total<0表示未统计总条数，此时按当前页是否取满判断是否有下一页
+func NewPagination(total, offset, limit, count int) *Pagination {
+	page := &Pagination{}
+	next := offset + count
+	switch {
+	case total >= 0:
+		page.Total = Int64(total)
+		if next < total && count > 0 {
+			page.NextCursor = EncodeCursor(next)
+		}
+	case limit > 0 && count >= limit:
+		page.NextCursor = EncodeCursor(next)
+	}
+	return page
+}
+
+// cursorPrefix 游标编码前的前缀，用于识别版本
+const cursorPrefix = "o1:"
+
+// EncodeCursor 把偏移量编码为不透明的分页游标
+func EncodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(offset)))
+}
+
+// DecodeCursor 解析分页游标，返回偏移量
+func DecodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, fmt.Errorf("分页游标无效")
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(raw), cursorPrefix))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("分页游标无效")
+	}
+	return offset, nil
+}
+
+// ErrorCode 错误码，取值稳定，客户端应按错误码而不是错误信息判断错误类型
+type ErrorCode string
+
+const (
+	CodeBadRequest           ErrorCode = "bad_request"
+	CodeInvalidParams        ErrorCode = "invalid_params"
+	CodeInvalidQuery         ErrorCode = "invalid_query"
+	CodeRowBudgetExceeded    ErrorCode = "row_budget_exceeded"
+	CodeUnauthorized         ErrorCode = "unauthorized"
+	CodeForbidden            ErrorCode = "forbidden"
+	CodeNotFound             ErrorCode = "not_found"
+	CodeMethodNotAllowed     ErrorCode = "method_not_allowed"
+	CodeConflict             ErrorCode = "conflict"
+	CodePayloadTooLarge      ErrorCode = "payload_too_large"
+	CodeUnsupportedMediaType ErrorCode = "unsupported_media_type"
+	CodeInternal             ErrorCode = "internal"
+	CodeNotImplemented       ErrorCode = "not_implemented"
+	CodeUpstream             ErrorCode = "upstream_error"
+	CodeUnavailable          ErrorCode = "unavailable"
+	CodeTimeout              ErrorCode = "timeout"
+)
+
+// Error 错误对象
+// message是错误码对应的固定说明，按Accept-Language本地化；detail是本次错误的具体原因，details为结构化的补充信息
+type Error struct {
+	Code    ErrorCode   `json:"code"`
+	Message string      `json:"message"`
+	Detail  string      `json:"detail,omitempty"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// 支持的语言，默认中文
+const (
+	LangZH = "zh"
+	LangEN = "en"
+)
+
+// errorMessages 各错误码的说明
+var errorMessages = map[ErrorCode]map[string]string{
+	CodeBadRequest:           {LangZH: "请求无效", LangEN: "Bad request"},
+	CodeInvalidParams:        {LangZH: "请求参数无效", LangEN: "Invalid request parameters"},
+	CodeInvalidQuery:         {LangZH: "查询语句无效", LangEN: "Invalid query"},
+	CodeRowBudgetExceeded:    {LangZH: "预估读取行数超过限制", LangEN: "Estimated rows to read exceed the budget"},
+	CodeUnauthorized:         {LangZH: "未授权", LangEN: "Unauthorized"},
+	CodeForbidden:            {LangZH: "无权访问", LangEN: "Forbidden"},
+	CodeNotFound:             {LangZH: "资源不存在", LangEN: "Not found"},
+	CodeMethodNotAllowed:     {LangZH: "不支持的请求方法", LangEN: "Method not allowed"},
+	CodeConflict:             {LangZH: "资源状态冲突", LangEN: "Conflict"},
+	CodePayloadTooLarge:      {LangZH: "请求体过大", LangEN: "Payload too large"},
+	CodeUnsupportedMediaType: {LangZH: "不支持的请求格式", LangEN: "Unsupported media type"},
+	CodeInternal:             {LangZH: "服务器内部错误", LangEN: "Internal server error"},
+	CodeNotImplemented:       {LangZH: "当前数据源不支持该操作", LangEN: "Not supported by the current data source"},
+	CodeUpstream:             {LangZH: "数据库返回错误", LangEN: "Database returned an error"},
+	CodeUnavailable:          {LangZH: "数据源不可用", LangEN: "Data source unavailable"},
+	CodeTimeout:              {LangZH: "查询超时", LangEN: "Query timed out"},
+}
+
+// statusCodes 状态码对应的默认错误码
+var statusCodes = map[int]ErrorCode{
+	http.StatusBadRequest:            CodeBadRequest,
+	http.StatusUnauthorized:          CodeUnauthorized,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusMethodNotAllowed:      CodeMethodNotAllowed,
+	http.StatusConflict:              CodeConflict,
+	http.StatusRequestEntityTooLarge: CodePayloadTooLarge,
+	http.StatusUnsupportedMediaType:  CodeUnsupportedMediaType,
+	http.StatusInternalServerError:   CodeInternal,
+	http.StatusNotImplemented:        CodeNotImplemented,
+	http.StatusBadGateway:            CodeUpstream,
+	http.StatusServiceUnavailable:    CodeUnavailable,
+	http.StatusGatewayTimeout:        CodeTimeout,
+}
+
+// CodeForStatus 返回状态码对应的默认错误码，未列出的4xx为bad_request，其他为internal
+func CodeForStatus(status int) ErrorCode {
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	if status >= 400 && status < 500 {
+		return CodeBadRequest
+	}
+	return CodeInternal
+}
+
+// ErrorMessage 返回错误码在指定语言下的说明，未知语言使用中文
+func ErrorMessage(code ErrorCode, lang string) string {
+	messages, ok := errorMessages[code]
+	if !ok {
+		messages = errorMessages[CodeInternal]
+	}
+	if m, ok := messages[lang]; ok {
+		return m
+	}
+	return messages[LangZH]
+}
+
+// ErrorCatalogEntry 错误码列表中的一项
+type ErrorCatalogEntry struct {
+	Code     ErrorCode         `json:"code"`
+	Messages map[string]string `json:"messages"`
+}
+
+// ErrorCatalog 返回所有错误码及各语言的说明，按错误码排序，客户端可据此自行本地化
+func ErrorCatalog() []ErrorCatalogEntry {
+	entries := make([]ErrorCatalogEntry, 0, len(errorMessages))
+	for code, messages := range errorMessages {
+		entries = append(entries, ErrorCatalogEntry{Code: code, Messages: messages})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Code < entries[j].Code })
+	return entries
+}
+
+// NewError 创建错误对象，message按请求的语言填写
+func NewError(w http.ResponseWriter, code ErrorCode, detail string, details interface{}) *Error {
+	lang := LangZH
+	if state := stateOf(w); state != nil {
+		lang = state.lang
+	}
+	return &Error{Code: code, Message: ErrorMessage(code, lang), Detail: detail, Details: details}
+}
+
+// RespondWithJSON 响应成功，data放在统一响应结构的data字段中
+func RespondWithJSON(w http.ResponseWriter, code int, data interface{}) {
+	Respond(w, code, Response{Data: data})
+}
+
+// RespondWithPage 响应分页数据
+func RespondWithPage(w http.ResponseWriter, code int, data interface{}, page *Pagination) {
+	Respond(w, code, Response{Data: data, Pagination: page})
+}
+
+// RespondWithError 响应错误，错误码由状态码决定，message为错误的具体原因
+func RespondWithError(w http.ResponseWriter, code int, message string) {
+	RespondWithErrorCode(w, code, CodeForStatus(code), message, nil)
+}
+
+// RespondWithErrorCode 响应指定错误码的错误，details为结构化的补充信息，如出错位置
+func RespondWithErrorCode(w http.ResponseWriter, status int, code ErrorCode, detail string, details interface{}) {
+	Respond(w, status, Response{Error: NewError(w, code, detail, details)})
+}
+
+// Respond 写入统一结构的响应，meta中由请求过程收集的字段在这里补充
+func Respond(w http.ResponseWriter, code int, response Response) {
+	response.Meta = ResponseMeta(w, response.Meta)
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		data, _ = json.Marshal(Response{
+			Meta:  response.Meta,
+			Error: NewError(w, CodeInternal, "无法序列化响应", nil),
+		})
+		code = http.StatusInternalServerError
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data)
+}
+
+// Trailer 返回流式JSON结果写在data之后的字段，与Response的pagination、meta和error相同
+// err不为nil时表示读取中途出错，status决定错误码
+func Trailer(w http.ResponseWriter, page *Pagination, meta Meta, status int, err error) map[string]interface{} {
+	fields := map[string]interface{}{"meta": ResponseMeta(w, meta)}
+	if err != nil {
+		fields["error"] = NewError(w, CodeForStatus(status), err.Error(), nil)
+		return fields
+	}
+	if page != nil {
+		fields["pagination"] = page
+	}
+	return fields
+}
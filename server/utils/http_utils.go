@@ -1,30 +1,26 @@
 package utils
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 )
 
-// RespondWithError 响应错误信息
-func RespondWithError(w http.ResponseWriter, code int, message string) {
-	RespondWithJSON(w, code, map[string]string{"error": message})
-}
+// DataSourceHeader 响应头中的数据源
+const DataSourceHeader = "X-Data-Source"
 
-// RespondWithJSON 响应JSON数据
-func RespondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
-	response, err := json.Marshal(payload)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(`{"error":"无法序列化响应"}`))
-		return
-	}
+// dataSource 当前数据源，写入每个JSON响应的meta.source字段
+var dataSource string
+
+// SetDataSource 设置响应中的数据源，启动时调用
+func SetDataSource(source string) {
+	dataSource = source
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	w.Write(response)
+// DataSource 返回响应中的数据源
+func DataSource() string {
+	return dataSource
 }
 
 // GenerateToken 生成简单的令牌
@@ -49,3 +45,18 @@ func GetUserFromRequest(r *http.Request) (string, error) {
 func ParseInt(s string) (int, error) {
 	return strconv.Atoi(s)
 }
+
+// TimeLayouts 时间参数接受的格式，依次尝试
+var TimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// ParseTime 解析时间参数，接受RFC3339、"2006-01-02 15:04:05"、"2006-01-02 15:04"和"2006-01-02"，不带时区时按UTC处理
+func ParseTime(s string) (time.Time, error) {
+	var err error
+	for _, layout := range TimeLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法解析时间%q，应为RFC3339或YYYY-MM-DD HH:MM格式", s)
+}
@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requestState 一个请求处理过程中收集的元信息，写入响应的meta
+type requestState struct {
+	start time.Time
+	lang  string
+
+	mu       sync.Mutex
+	queryIDs []string
+	rowsRead int64
+}
+
+type requestStateKey struct{}
+
+// metaWriter 携带请求元信息的ResponseWriter，响应函数通过它找到当前请求的元信息
+type metaWriter struct {
+	http.ResponseWriter
+	state *requestState
+}
+
+// Unwrap 供http.ResponseController访问原始的ResponseWriter
+func (w *metaWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush 实时追踪(SSE)和流式导出需要逐段推送
+func (w *metaWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack WebSocket升级需要接管连接
+func (w *metaWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, errors.New("ResponseWriter不支持Hijack")
+}
+
+// RequestMeta 记录请求的开始时间和语言，并在context中准备收集query_id和读取行数，供响应的meta使用
+func RequestMeta(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := &requestState{start: time.Now(), lang: negotiateLang(r.Header.Get("Accept-Language"))}
+		ctx := context.WithValue(r.Context(), requestStateKey{}, state)
+		next.ServeHTTP(&metaWriter{ResponseWriter: w, state: state}, r.WithContext(ctx))
+	})
+}
+
+// RecordQuery 记录请求执行的ClickHouse查询，在database登记查询时调用
+func RecordQuery(ctx context.Context, queryID string) {
+	state, _ := ctx.Value(requestStateKey{}).(*requestState)
+	if state == nil {
+		return
+	}
+	state.mu.Lock()
+	state.queryIDs = append(state.queryIDs, queryID)
+	state.mu.Unlock()
+}
+
+// AddRowsRead 累加请求读取的行数，来自ClickHouse的查询进度或内存存储的扫描
+func AddRowsRead(ctx context.Context, rows int64) {
+	state, _ := ctx.Value(requestStateKey{}).(*requestState)
+	if state == nil {
+		return
+	}
+	state.mu.Lock()
+	state.rowsRead += rows
+	state.mu.Unlock()
+}
+
+// ResponseMeta 在meta的基础上补充请求的query_id、耗时、读取行数和数据源
+func ResponseMeta(w http.ResponseWriter, meta Meta) Meta {
+	meta.Source = dataSource
+	state := stateOf(w)
+	if state == nil {
+		return meta
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	meta.ElapsedMs = time.Since(state.start).Milliseconds()
+	meta.RowsRead = state.rowsRead
+	if len(state.queryIDs) > 0 {
+		meta.QueryID = state.queryIDs[0]
+	}
+	if len(state.queryIDs) > 1 {
+		meta.QueryIDs = append([]string(nil), state.queryIDs...)
+	}
+	return meta
+}
+
+// stateOf 沿Unwrap链查找请求的元信息，没有经过RequestMeta时返回nil
+func stateOf(w http.ResponseWriter) *requestState {
+	for w != nil {
+		if mw, ok := w.(*metaWriter); ok {
+			return mw.state
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil
+		}
+		w = unwrapper.Unwrap()
+	}
+	return nil
+}
+
+// negotiateLang 按Accept-Language选择错误信息的语言，依次取第一个支持的语言，默认中文
+func negotiateLang(header string) string {
+	for _, part := range strings.Split(header, ",") {
+		tag := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		switch {
+		case strings.HasPrefix(tag, LangZH):
+			return LangZH
+		case strings.HasPrefix(tag, LangEN):
+			return LangEN
+		}
+	}
+	return LangZH
+}
@@ -33,9 +33,9 @@ const KV7LogViewer: React.FC = () => {
         
         console.log('成功获取KV7日志数据:', response);
         
-        // 检查返回的数据是否为数组
-        if (Array.isArray(response.data)) {
-          setLogs(response.data);
+        // 检查返回的data是否为数组
+        if (Array.isArray(response.data.data)) {
+          setLogs(response.data.data);
           setError(null);
         } else {
           console.error('API返回的数据不是数组:', response.data);
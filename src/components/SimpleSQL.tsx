@@ -84,9 +84,9 @@ const SimpleSQL: React.FC = () => {
       console.log('正在获取默认数据...');
       // 直接使用正确的API URL
       const response = await axios.get('http://localhost:8888/api/query?table=kv_7&limit=50');
-      if (Array.isArray(response.data)) {
-        setResults(response.data || []);
-        setTotalResults(response.data.length || 0);
+      if (Array.isArray(response.data.data)) {
+        setResults(response.data.data);
+        setTotalResults(response.data.data.length);
         setShowStructure(false);
         // 更新查询文本为对应的SELECT语句
         setSqlQuery(`-- 默认显示kv_7表的50条数据\nSELECT * FROM kv_7 LIMIT 50;`);
@@ -160,10 +160,10 @@ const SimpleSQL: React.FC = () => {
           // 直接获取KV7表数据
           const response = await axios.get('http://localhost:8888/api/query?table=kv_7&limit=50');
           
-          if (Array.isArray(response.data)) {
+          if (Array.isArray(response.data.data)) {
             console.log('查询结果:', response.data);
-            setResults(response.data || []);
-            setTotalResults(response.data.length || 0);
+            setResults(response.data.data);
+            setTotalResults(response.data.data.length);
             setShowStructure(false);
             setResultMessage('查询成功，使用直接API获取数据');
           } else {
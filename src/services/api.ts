@@ -13,6 +13,63 @@ const apiClient = axios.create({
   }
 });
 
+// 后端接口的统一响应结构，成功时有data，失败时有error
+export interface ApiEnvelope {
+  columns?: { name: string; type: string }[];
+  data?: any;
+  pagination?: { total?: number; total_exact?: boolean; next_cursor?: string };
+  meta?: {
+    query_id?: string;
+    query_ids?: string[];
+    elapsed_ms: number;
+    rows_read: number;
+    source: string;
+    table_rows?: number;
+    warning?: string;
+  };
+  error?: { code: string; message: string; detail?: string; details?: any };
+}
+
+// 把统一响应结构转换为组件使用的格式：success、data、total、dbTotalCount和字符串error
+// data为对象时其字段同时展开到顶层；不是统一响应结构的内容(如导出的文件)原样返回
+export const fromEnvelope = (body: any) => {
+  if (!body || typeof body !== 'object' || !('meta' in body)) {
+    return body;
+  }
+  const { columns, data, pagination, meta, error } = body as ApiEnvelope;
+  const spread = data && typeof data === 'object' && !Array.isArray(data) ? data : {};
+  return {
+    ...spread,
+    success: !error,
+    columns,
+    data,
+    total: pagination?.total,
+    totalExact: pagination?.total_exact,
+    nextCursor: pagination?.next_cursor,
+    dbTotalCount: meta?.table_rows,
+    warning: meta?.warning,
+    source: meta?.source,
+    meta,
+    code: error?.code,
+    error: error ? (error.detail ? `${error.message}: ${error.detail}` : error.message) : undefined,
+    errors: error?.details
+  };
+};
+
+// 转换成功和失败响应中的统一响应结构，组件中直接使用axios的请求也一并转换
+const unwrapResponse = (response: any) => {
+  response.data = fromEnvelope(response.data);
+  return response;
+};
+const unwrapError = (error: any) => {
+  if (error.response) {
+    error.response.data = fromEnvelope(error.response.data);
+  }
+  return Promise.reject(error);
+};
+axios.interceptors.response.use(unwrapResponse, unwrapError);
+apiClient.interceptors.response.use(unwrapResponse, unwrapError);
+
 // 添加响应拦截器
 apiClient.interceptors.response.use(
   response => response,
@@ -634,7 +691,8 @@ export const getLogFields = async () => {
   try {
     console.log('获取日志字段列表');
     const response = await apiClient.get('/logs/fields');
-    return response.data;
+    // data中fields为字段列表
+    return { ...response.data, data: response.data.fields };
   } catch (error) {
     console.error('获取日志字段列表失败', error);
     // 返回默认字段
@@ -685,7 +743,8 @@ export const getLogTypes = async () => {
   try {
     console.log('获取日志类型列表');
     const response = await apiClient.get('/logs/types');
-    return response.data;
+    // data中levels为日志级别，categories为日志类别
+    return { ...response.data, data: response.data.levels };
   } catch (error) {
     console.error('获取日志类型列表失败', error);
     // 返回默认日志类型
@@ -874,13 +933,13 @@ export const testDatabaseConnection = async () => {
       timeout: 5000 
     });
     
-    // 如果能正常获取数据（返回数组），则说明数据库连接正常
-    if (Array.isArray(response.data)) {
+    // 如果能正常获取数据（data为数组），则说明数据库连接正常
+    if (Array.isArray(response.data.data)) {
       console.log('数据库连接测试成功：已成功查询到数据');
       return {
         status: 'connected',
         message: '数据库连接正常，已成功查询到数据',
-        details: { success: true, count: response.data.length }
+        details: { success: true, count: response.data.data.length }
       };
     }
     
@@ -2,7 +2,7 @@
 echo 正在启动服务...
 
 :: 设置环境变量
-set USE_REAL_DATABASE=true
+set DATA_SOURCE=clickhouse
 for /f "tokens=*" %%a in (.env) do set "%%a"
 
 :: 检查Docker是否运行
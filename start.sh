@@ -2,7 +2,7 @@
 echo "正在启动所有服务..."
 
 # 加载环境变量
-export USE_REAL_DATABASE=true
+export DATA_SOURCE=clickhouse
 if [ -f .env ]; then
   export $(grep -v '^#' .env | xargs)
 fi